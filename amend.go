@@ -0,0 +1,77 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Amendment is a planned change to one punch's note.
+type Amendment struct {
+	Punch *CardSchema // as it is now
+	Note  string      // to replace Punch's note with; empty to delete it
+}
+
+func (a *Amendment) IsDeletion() bool { return len(a.Note) < 1 }
+
+// PlanAmendment finds client's punch at target, whose note is to be replaced
+// with note, or deleted if note is empty. An empty client matches a punch of
+// any client.
+func (c *Card) PlanAmendment(client string, target time.Time, note string) (*Amendment, error) {
+	cards, e := c.punchesAt(client, target)
+	if e != nil {
+		return nil, fmt.Errorf("querying TARGET_STAMP punch: %s", e)
+	}
+	card, e := onePunch(cards, "TARGET_STAMP")
+	if e != nil {
+		return nil, e
+	}
+	return &Amendment{Punch: card, Note: note}, nil
+}
+
+// AmendNote plans then carries out an amendment, per PlanAmendment.
+func (c *Card) AmendNote(client string, target time.Time, note string) error {
+	return c.Transact(func() error {
+		a, e := c.PlanAmendment(client, target, note)
+		if e != nil {
+			return e
+		}
+		return c.Amend(a)
+	})
+}
+
+// Amend carries out a plan from PlanAmendment.
+func (c *Card) Amend(a *Amendment) error {
+	noteAction := "update"
+	if a.IsDeletion() {
+		noteAction = "delete"
+	}
+
+	card := a.Punch
+	summary := fmt.Sprintf("amend '%s' note", card.Project)
+	return c.journaled(summary, []rowKey{punchKey(card)}, func(tx *sql.Tx) error {
+		stmt, e := tx.Prepare(`
+			UPDATE punchcard
+			SET note = ?
+			WHERE punch IS ?
+			AND project IS ?
+		;`)
+		if e != nil {
+			return fmt.Errorf("preparing db modification: %s", e)
+		}
+
+		r, e := stmt.Exec(toNullString(a.Note), card.Punch.Unix(), card.Project)
+		if e != nil {
+			return fmt.Errorf("trying to %s note: %s", noteAction, e)
+		}
+		affected, e := r.RowsAffected()
+		if e != nil {
+			return fmt.Errorf("trying to parse results of %s: %s", noteAction, e)
+		}
+
+		if affected != 1 {
+			return fmt.Errorf("expected 1 punch record affected, but got %d", affected)
+		}
+		return nil
+	})
+}
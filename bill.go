@@ -0,0 +1,374 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bills lists pay periods, oldest first, for every client in clients (or for all
+// clients if none are given).
+func (c *Card) Bills(clients ...string) ([]*BillSchema, error) {
+	query := "SELECT * FROM paychecks\n"
+	var args []interface{}
+	if len(clients) > 0 {
+		var placeholders []string
+		for _, client := range clients {
+			client = strings.TrimSpace(client)
+			if !IsValidClient(client) {
+				return nil, fmt.Errorf("invalid client: '%s'", client)
+			}
+			placeholders = append(placeholders, "?")
+			args = append(args, client)
+		}
+		query += fmt.Sprintf(
+			"WHERE project IN (%s)\n", strings.Join(placeholders, ", "))
+	}
+	query += "ORDER BY endclusive ASC, project ASC;"
+
+	return c.queryBills(query, args...)
+}
+
+// BillReport is the work logged on a pay period's client, and any of its
+// sub-clients, within the period.
+type BillReport struct {
+	Bill *BillSchema
+
+	// Sessions overlapping the pay period, clipped to its bounds.
+	Sessions []*Session
+
+	// Non-nil if a still-open session (the first, of several sub-clients')
+	// started before the period ended; none of its time is counted in Sessions.
+	Open *CardSchema
+
+	// Time in Sessions, rounded per the client's rates, and what it's worth;
+	// Amount is nil if no rate was in effect for any of Sessions.
+	Billable time.Duration
+	Amount   *Money
+	Rates    []*RateSchema // those Sessions were priced at, oldest first
+
+	// Time in Sessions that started before the client had any rate.
+	Unpriced time.Duration
+}
+
+// Total is the duration worked across Sessions.
+func (r *BillReport) Total() time.Duration {
+	var total time.Duration
+	for _, s := range r.Sessions {
+		total += s.Duration
+	}
+	return total
+}
+
+// ReportBills totals, and prices, the work logged within each of bills. Work on
+// a bill's sub-clients is priced at the bill's client's rates.
+func (c *Card) ReportBills(bills []*BillSchema) ([]*BillReport, error) {
+	treeReports := make(map[string][]*ClientReport)
+	clientRates := make(map[string][]*RateSchema)
+	var reports []*BillReport
+	for _, bill := range bills {
+		clientReports, ok := treeReports[bill.Project]
+		if !ok {
+			var e error
+			if clientReports, e = c.ReportTree(bill.Project, time.Time{}); e != nil {
+				return nil, e
+			}
+			treeReports[bill.Project] = clientReports
+			if clientRates[bill.Project], e = c.inheritedRates(bill.Project); e != nil {
+				return nil, fmt.Errorf("finding '%s' rates: %s", bill.Project, e)
+			}
+		}
+
+		report := &BillReport{Bill: bill}
+		for _, clientReport := range clientReports {
+			for _, session := range clientReport.Sessions {
+				if clipped := session.Clip(bill.Startclusive, bill.Endclusive); clipped != nil {
+					report.Sessions = append(report.Sessions, clipped)
+				}
+			}
+			if open := clientReport.Open; open != nil && report.Open == nil &&
+				!open.Punch.After(bill.Endclusive) {
+				report.Open = open
+			}
+		}
+		sort.SliceStable(report.Sessions, func(i, j int) bool {
+			return report.Sessions[i].StartAt.Before(report.Sessions[j].StartAt)
+		})
+		if e := report.price(clientRates[bill.Project]); e != nil {
+			return nil, e
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// Unbilled is the work logged on a client, and its sub-clients, since the
+// most recent pay period billing each.
+type Unbilled struct {
+	Client string
+
+	// End of the most recent pay period billing the client (whether on it, or
+	// a client it's within); zero if it's never been billed.
+	Since time.Time
+
+	// Sessions since the last pay period, clipped to start no earlier than it.
+	Sessions []*Session
+
+	// Non-nil if the client (or, failing that, a sub-client) is still punched
+	// in.
+	Open       *CardSchema
+	OpenBreaks []*BreakSchema
+
+	// The time Open's session is counted up to.
+	AsOf time.Time
+
+	// Every session still open, across the client and its sub-clients, as of
+	// AsOf, clipped like Sessions.
+	open []*Session
+}
+
+// Total is the duration worked across Sessions and, so far, in any session
+// still open.
+func (u *Unbilled) Total() time.Duration {
+	var total time.Duration
+	for _, s := range append(u.Sessions, u.open...) {
+		total += s.Duration
+	}
+	return total
+}
+
+// Unbilled reports on client's work since its last pay period ended, and on
+// each of its sub-clients' since theirs did.
+func (c *Card) Unbilled(client string) (*Unbilled, error) {
+	unbilled := &Unbilled{Client: client, AsOf: c.Clock.Now()}
+	var e error
+	if unbilled.Since, e = c.billedUntil(client); e != nil {
+		return nil, e
+	}
+
+	reports, e := c.ReportTree(client, time.Time{})
+	if e != nil {
+		return nil, e
+	}
+	for _, report := range reports {
+		since := unbilled.Since
+		if report.Client != client {
+			if since, e = c.billedUntil(report.Client); e != nil {
+				return nil, e
+			}
+		}
+		for _, session := range report.Sessions {
+			if clipped := session.Clip(since, session.StopAt); clipped != nil {
+				unbilled.Sessions = append(unbilled.Sessions, clipped)
+			}
+		}
+		if report.Open == nil {
+			continue
+		}
+		if unbilled.Open == nil {
+			unbilled.Open, unbilled.OpenBreaks = report.Open, report.OpenBreaks
+		}
+		soFar := SoFar(report.Open, report.OpenBreaks, unbilled.AsOf)
+		if clipped := soFar.Clip(since, unbilled.AsOf); clipped != nil {
+			unbilled.open = append(unbilled.open, clipped)
+		}
+	}
+	return unbilled, nil
+}
+
+// The end of the most recent pay period billing client's time, whether on
+// client or any client it's within; zero if none has.
+func (c *Card) billedUntil(client string) (time.Time, error) {
+	var until time.Time
+	for _, billed := range append(ClientAncestors(client), client) {
+		last, e := c.LastBill(billed)
+		if e != nil {
+			return until, fmt.Errorf("finding last '%s' pay period: %s", billed, e)
+		}
+		if last != nil && last.Endclusive.After(until) {
+			until = last.Endclusive
+		}
+	}
+	return until, nil
+}
+
+// The rates client's work is priced at: its own, or else those of the nearest
+// client it's within that has any.
+func (c *Card) inheritedRates(client string) ([]*RateSchema, error) {
+	for ; len(client) > 0; client = ClientParent(client) {
+		rates, e := c.Rates(client)
+		if e != nil || len(rates) > 0 {
+			return rates, e
+		}
+	}
+	return nil, nil
+}
+
+// LastBill returns client's most recent pay period, or nil if it has none.
+func (c *Card) LastBill(client string) (*BillSchema, error) {
+	bills, e := c.queryBills(`
+		SELECT * FROM paychecks
+		WHERE project IS ?
+		ORDER BY endclusive DESC
+		LIMIT 1;
+	`, client)
+	if e != nil {
+		return nil, e
+	}
+	if len(bills) == 0 {
+		return nil, nil
+	}
+	return bills[0], nil
+}
+
+// FindBill returns client's pay period starting exactly at start.
+func (c *Card) FindBill(client string, start time.Time) (*BillSchema, error) {
+	bills, e := c.queryBills(`
+		SELECT * FROM paychecks
+		WHERE project IS ?
+		AND startclusive IS ?
+		;`, client, start.Unix())
+	if e != nil {
+		return nil, fmt.Errorf("querying DB: %s", e)
+	}
+
+	if len(bills) > 1 {
+		return nil, fmt.Errorf("malformed data: found TWO payperiods sharing start time")
+	}
+	if len(bills) == 0 {
+		return nil, fmt.Errorf(
+			"no '%s' payperiods start at %s",
+			client, start.Format(FormatDateTime))
+	}
+	return bills[0], nil
+}
+
+// ImpliedBillEnd is the most recent punch-out on client, or any of its
+// sub-clients.
+func (c *Card) ImpliedBillEnd(client string) (time.Time, error) {
+	cards, e := c.queryCards(fmt.Sprintf(`
+		SELECT * FROM punchcard
+		WHERE %s
+		AND status IS 0
+		ORDER BY punch DESC
+		LIMIT 1;
+	`, withinClause), withinArgs(client)...)
+	if e != nil {
+		return time.Time{}, e
+	}
+
+	if len(cards) > 0 {
+		return cards[0].Punch, nil
+	}
+
+	return time.Time{}, fmt.Errorf(
+		"implied TO stamp, but no full '%s' work records found", client)
+}
+
+// ImpliedBillStart is the end of client's previous pay period (or that of a
+// client it's within), or if there's none, the beginning of client's and its
+// sub-clients' work history.
+func (c *Card) ImpliedBillStart(client string) (time.Time, error) {
+	until, e := c.billedUntil(client)
+	if e != nil {
+		return time.Time{}, e
+	}
+	if !until.IsZero() {
+		return until, nil
+	}
+
+	// If here, then no previous paycheck, so *all* of history is implied
+	// beginning of paycheck...
+
+	cards, e := c.queryCards(fmt.Sprintf(`
+		SELECT * FROM punchcard
+		WHERE %s
+		ORDER BY punch ASC
+		LIMIT 1;
+	`, withinClause), withinArgs(client)...)
+	if e != nil {
+		return time.Time{}, e
+	}
+	if len(cards) > 0 {
+		return cards[0].Punch, nil
+	}
+
+	return time.Time{}, fmt.Errorf(
+		"implied '%s' FROM impossible without work or payperiod history", client)
+}
+
+// CreateBill records a new pay period, so long as it doesn't overlap one on a
+// client it's within, nor on a sub-client, which would bill the same time twice.
+func (c *Card) CreateBill(bill *BillSchema) error {
+	if !bill.Startclusive.Before(bill.Endclusive) {
+		return fmt.Errorf("expected FROM to be older stamp than TO")
+	}
+	return c.Transact(func() error {
+		if e := c.checkBillLineage(bill); e != nil {
+			return e
+		}
+		return c.createBill(bill)
+	})
+}
+
+// Fails if bill overlaps a pay period on a client it's within, or a sub-client.
+func (c *Card) checkBillLineage(bill *BillSchema) error {
+	bills, e := c.Bills()
+	if e != nil {
+		return e
+	}
+	for _, other := range bills {
+		if other.Project == bill.Project ||
+			!(IsWithinClient(other.Project, bill.Project) ||
+				IsWithinClient(bill.Project, other.Project)) {
+			continue
+		}
+		if other.Startclusive.Before(bill.Endclusive) &&
+			bill.Startclusive.Before(other.Endclusive) {
+			return fmt.Errorf(
+				"pay period overlaps '%s' pay period from %s to %s, so would bill the same time twice",
+				other.Project,
+				other.Startclusive.Format(FormatDateTime),
+				other.Endclusive.Format(FormatDateTime))
+		}
+	}
+	return nil
+}
+
+func (c *Card) createBill(bill *BillSchema) error {
+	b := bill.ToSQL()
+	summary := fmt.Sprintf("bill '%s'", bill.Project)
+	return c.journaled(summary, []rowKey{billKey(bill)}, func(tx *sql.Tx) error {
+		stmt, e := tx.Prepare(`
+			INSERT INTO
+			paychecks(endclusive, startclusive, project, note)
+			VALUES (?, ?, ?, ?)
+		`)
+		if e != nil {
+			return e
+		}
+
+		_, e = stmt.Exec(b.Endclusive, b.Startclusive, b.Project, b.Note)
+
+		return e
+	})
+}
+
+// DeleteBill removes a pay period, as found by FindBill.
+func (c *Card) DeleteBill(bill *BillSchema) error {
+	summary := fmt.Sprintf("delete '%s' pay period", bill.Project)
+	return c.journaled(summary, []rowKey{billKey(bill)}, func(tx *sql.Tx) error {
+		stmt, e := tx.Prepare(`
+			DELETE FROM paychecks
+			WHERE project IS ?
+			AND startclusive IS ?
+			;`)
+		if e != nil {
+			return fmt.Errorf("preparing SQL for deletion: %s", e)
+		}
+		_, e = stmt.Exec(bill.Project, bill.Startclusive.Unix())
+		return e
+	})
+}
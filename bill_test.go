@@ -0,0 +1,127 @@
+package punch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReportBills(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	c, e := Open(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+	c.Clock = FixedClock(time.Unix(1491970000, 0))
+
+	// Still open, and within the second bill below
+	if _, e := c.PunchAt("golangpunch", "", time.Unix(1491969000, 0)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+
+	bills := []*BillSchema{
+		// Clips the 21:31:38 to 02:22:37 (UTC) session down to its last 37s
+		{
+			Startclusive: time.Unix(1491963720, 0),
+			Endclusive:   time.Unix(1491964000, 0),
+			Project:      "spaceship",
+		},
+		{
+			Startclusive: time.Unix(1491960000, 0),
+			Endclusive:   time.Unix(1491970000, 0),
+			Project:      "golangpunch",
+		},
+		// Entirely within the 13:08:14 to 14:14:58 session (UTC)
+		{
+			Startclusive: time.Unix(1491917000, 0),
+			Endclusive:   time.Unix(1491918000, 0),
+			Project:      "spaceship",
+		},
+	}
+	reports, e := c.ReportBills(bills)
+	if e != nil {
+		t.Fatalf("reporting bills: %s", e)
+	}
+	if len(reports) != len(bills) {
+		t.Fatalf("expected a report per bill, got %d", len(reports))
+	}
+
+	for i, expected := range []struct {
+		sessions int
+		total    time.Duration
+		isOpen   bool
+	}{
+		{1, time.Second * 37, false},
+		{0, 0, true},
+		{1, time.Second * 1000, false},
+	} {
+		r := reports[i]
+		if r.Bill != bills[i] {
+			t.Errorf("report %d: reports on the wrong bill", i)
+		}
+		if len(r.Sessions) != expected.sessions || r.Total() != expected.total {
+			t.Errorf("report %d: got %d sessions totaling %s, expected %d totaling %s",
+				i, len(r.Sessions), r.Total(), expected.sessions, expected.total)
+		}
+		if (r.Open != nil) != expected.isOpen {
+			t.Errorf("report %d: got open session %v", i, r.Open)
+		}
+	}
+}
+
+func TestUnbilled(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	c, e := Open(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+	now := time.Unix(1491970000, 0)
+	c.Clock = FixedClock(now)
+
+	// Bill ends mid-way through the 21:31:38 to 02:22:37 (UTC) session
+	if e := c.CreateBill(&BillSchema{
+		Startclusive: time.Unix(1491917000, 0),
+		Endclusive:   time.Unix(1491963000, 0),
+		Project:      "spaceship",
+	}); e != nil {
+		t.Fatalf("billing: %s", e)
+	}
+	spaceship, e := c.Unbilled("spaceship")
+	if e != nil {
+		t.Fatalf("reporting unbilled: %s", e)
+	}
+	if !spaceship.Since.Equal(time.Unix(1491963000, 0)) ||
+		len(spaceship.Sessions) != 1 ||
+		spaceship.Total() != time.Second*757 ||
+		spaceship.Open != nil {
+		t.Errorf("spaceship: got %d sessions totaling %s since %s (open: %v)",
+			len(spaceship.Sessions), spaceship.Total(), spaceship.Since, spaceship.Open)
+	}
+
+	// Open session started before the last bill ended only counts after it
+	if _, e := c.PunchAt("golangpunch", "", time.Unix(1491840000, 0)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+	golang, e := c.Unbilled("golangpunch")
+	if e != nil {
+		t.Fatalf("reporting unbilled: %s", e)
+	}
+	if !golang.Since.Equal(time.Unix(1491853152, 0)) ||
+		len(golang.Sessions) != 0 ||
+		golang.Open == nil ||
+		golang.Total() != now.Sub(golang.Since) {
+		t.Errorf("golangpunch: got %d sessions totaling %s since %s (open: %v)",
+			len(golang.Sessions), golang.Total(), golang.Since, golang.Open)
+	}
+
+	never, e := c.Unbilled("nobody")
+	if e != nil {
+		t.Fatalf("reporting unbilled: %s", e)
+	}
+	if !never.Since.IsZero() || never.Total() != 0 {
+		t.Errorf("nobody: got %s since %s", never.Total(), never.Since)
+	}
+}
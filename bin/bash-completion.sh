@@ -2,10 +2,10 @@
 
 __punchClientCompletion() {
   local subcmds
-  declare -r subcmds='punch bill query delete amend seek help'
+  declare -r subcmds='punch pause resume bill invoice rate query delete amend seek undo redo log fsck migrate help'
 
   if (( COMP_CWORD == 1 ));then
-    COMPREPLY=( $(compgen -W "-h $subcmds" -- "${COMP_WORDS[$COMP_CWORD]}") )
+    COMPREPLY=( $(compgen -W "-h --format --tz --debug $subcmds" -- "${COMP_WORDS[$COMP_CWORD]}") )
     return
   fi
 
@@ -15,8 +15,14 @@ __punchClientCompletion() {
   [[ "$subcmds" = "${subcmds/$subCmd/}" ]] &&
       return # bail; not autocompleting args to any valid subcommand
 
+  if [[ "$subCmd" = fsck ]];then
+    (( COMP_CWORD == 2 )) &&
+        COMPREPLY=( $(compgen -W '--repair' -- "${COMP_WORDS[$COMP_CWORD]}") )
+    return
+  fi
+
   case "$subCmd" in
-    p|punch|bill|d|delete|h|help) ;;
+    p|punch|pause|resume|bill|invoice|rate|d|delete|h|help) ;;
     *) return ;; # currently only implement autocompletion of CLIENT args
   esac
 
@@ -35,10 +41,13 @@ __punchClientCompletion() {
         fi
       done
 
-      if ! (( hasNoteArg ));then nextArgs+=' -n '; fi
+      if ! (( hasNoteArg ));then nextArgs+=' -n --at -t '; fi
+
+      [[ "$prevArg" = --at ]] && return # waiting on TIME argument
+      [[ "$prevArg" = -t ]] && return # waiting on TAG argument
 
       if (( COMP_CWORD == 3 )) && ! (( hasNoteArg ));then
-        COMPREPLY=( $(compgen -W ' -n ' -- "${COMP_WORDS[$COMP_CWORD]}") )
+        COMPREPLY=( $(compgen -W ' -n --at -t ' -- "${COMP_WORDS[$COMP_CWORD]}") )
         return
       elif (( COMP_CWORD > 3 )); then
         return
@@ -51,7 +60,7 @@ __punchClientCompletion() {
       case $COMP_CWORD in
         2) ;; # continue on w/normal CLIENT completion
         3)
-          COMPREPLY=( $(compgen -W "${billFlags} -d" -- "${COMP_WORDS[$COMP_CWORD]}") )
+          COMPREPLY=( $(compgen -W "${billFlags} -d -y" -- "${COMP_WORDS[$COMP_CWORD]}") )
           return
           ;;
         4|5|6|7|8)
@@ -72,12 +81,19 @@ __punchClientCompletion() {
         *) return ;; # we've a full commandline for `bill`
       esac
       ;;
+    invoice|rate)
+      (( COMP_CWORD == 2 )) || return # only CLIENT is completable
+      ;;
+    pause|resume)
+      (( COMP_CWORD == 2 )) || return # only CLIENT is completable
+      nextArgs+=' --at '
+      ;;
     d|delete)
       if (( COMP_CWORD == 2 ));then
         COMPREPLY=( $(compgen -W 'bill punch' -- "${COMP_WORDS[$COMP_CWORD]}") )
         return
       elif (( COMP_CWORD == 4 ));then
-        COMPREPLY=( $(compgen -W ' -d ' -- "${COMP_WORDS[$COMP_CWORD]}") )
+        COMPREPLY=( $(compgen -W ' -d -y ' -- "${COMP_WORDS[$COMP_CWORD]}") )
         return
       elif (( COMP_CWORD > 4 ));then
         return # we've a full commandline (might still be waiting for AT timestamp arg)
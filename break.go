@@ -0,0 +1,273 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func insertBreak(tx *sql.Tx, b *BreakSchemaSQL) error {
+	_, e := tx.Exec(`
+		INSERT INTO
+		breaks(punch, project, start, stop)
+		VALUES (?, ?, ?, ?)
+	`, b.Punch, b.Project, b.Start, b.Stop)
+	return e
+}
+
+// Ends the break b, still paused, at `at`.
+func resumeBreak(tx *sql.Tx, b *BreakSchema, at time.Time) error {
+	_, e := tx.Exec(fmt.Sprintf(
+		"UPDATE breaks SET stop = ? WHERE %s AND start IS ?;", rowKeyWhere["breaks"]),
+		at.Unix(), b.Punch.Unix(), b.Project, b.Start.Unix())
+	return e
+}
+
+// Deletes every break within the session punchIn opened.
+func deleteBreaks(tx *sql.Tx, punchIn *CardSchema) error {
+	if _, e := tx.Exec(fmt.Sprintf(
+		"DELETE FROM breaks WHERE %s;", rowKeyWhere["breaks"]),
+		punchIn.Punch.Unix(), punchIn.Project); e != nil {
+		return fmt.Errorf("deleting session's breaks: %s", e)
+	}
+	return nil
+}
+
+// SessionBreaks is the breaks within each session, by its punch-in.
+type SessionBreaks map[sessionKey][]*BreakSchema
+
+// Of lists the breaks, by start, within the session punchIn opened.
+func (b SessionBreaks) Of(punchIn *CardSchema) []*BreakSchema {
+	return b[sessionKey{punchIn.Punch.Unix(), punchIn.Project}]
+}
+
+// Take sets the Breaks of each of client's sessions, deducting them from its
+// Duration.
+func (b SessionBreaks) Take(client string, sessions []*Session) {
+	for _, s := range sessions {
+		s.Breaks = b[sessionKey{s.StartAt.Unix(), client}]
+		s.Duration -= s.BreakTime()
+	}
+}
+
+// PausedSince is when the session with breaks was paused, or the zero value if
+// it's not paused.
+func PausedSince(breaks []*BreakSchema) time.Time {
+	if len(breaks) == 0 || !breaks[len(breaks)-1].IsPaused() {
+		return time.Time{}
+	}
+	return breaks[len(breaks)-1].Start
+}
+
+// SoFar is the session punchIn opened, with breaks, as of now: as if it had
+// been punched out of then.
+func SoFar(punchIn *CardSchema, breaks []*BreakSchema, now time.Time) *Session {
+	s := &Session{
+		Client:    punchIn.Project,
+		StartAt:   punchIn.Punch,
+		StopAt:    now,
+		NoteStart: punchIn.Note,
+		Breaks:    breaks,
+	}
+	s.Duration = s.Gross() - s.BreakTime()
+	return s
+}
+
+// AllBreaks reads the breaks within every session of the card.
+func (c *Card) AllBreaks() (SessionBreaks, error) {
+	return c.clientBreaks("")
+}
+
+// Reads the breaks within each of client's sessions, or every client's, if
+// empty.
+func (c *Card) clientBreaks(client string) (SessionBreaks, error) {
+	rows, e := c.conn().Query(`
+		SELECT * FROM breaks
+		WHERE (? IS '' OR project IS ?)
+		ORDER BY punch ASC, project ASC, start ASC;
+	`, client, client)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	breaks := make(SessionBreaks)
+	for rows.Next() {
+		raw := &BreakSchemaSQL{}
+		if e := rows.Scan(&raw.Punch, &raw.Project, &raw.Start, &raw.Stop); e != nil {
+			return nil, e
+		}
+		key := sessionKey{int64(raw.Punch), raw.Project}
+		breaks[key] = append(breaks[key], raw.ToBreak())
+	}
+	return breaks, rows.Err()
+}
+
+// Gross is the time from s's start to its stop, breaks and all.
+func (s *Session) Gross() time.Duration { return s.StopAt.Sub(s.StartAt) }
+
+// BreakTime is the time s spent paused, between its start and stop.
+func (s *Session) BreakTime() time.Duration {
+	var paused time.Duration
+	for _, b := range s.Breaks {
+		start, stop := b.Start, b.Stop
+		if b.IsPaused() || stop.After(s.StopAt) {
+			stop = s.StopAt
+		}
+		if start.Before(s.StartAt) {
+			start = s.StartAt
+		}
+		if stop.After(start) {
+			paused += stop.Sub(start)
+		}
+	}
+	return paused
+}
+
+// Pause starts a break in client's open session, or in the one client's on the
+// clock, if client is empty.
+func (c *Card) Pause(client string) (*BreakSchema, error) {
+	return c.PauseAt(client, time.Time{})
+}
+
+// PauseAt is Pause, but stamped `at` rather than now, if `at` is not the zero
+// value, as for PunchAt.
+func (c *Card) PauseAt(client string, at time.Time) (*BreakSchema, error) {
+	var b *BreakSchema
+	e := c.Transact(func() error {
+		punchIn, last, e := c.openBreaks(client)
+		if e != nil {
+			return e
+		}
+		if at, e = c.punchStamp(at); e != nil {
+			return e
+		}
+		if last != nil && last.IsPaused() {
+			return fmt.Errorf("'%s' already paused since %s",
+				punchIn.Project, last.Start.Format(FormatDateTime))
+		}
+		after := fmt.Sprintf("'%s' punch-in at %s",
+			punchIn.Project, punchIn.Punch.Format(FormatDateTime))
+		lastStamp := punchIn.Punch
+		if last != nil {
+			after = fmt.Sprintf("'%s' resume at %s",
+				punchIn.Project, last.Stop.Format(FormatDateTime))
+			lastStamp = last.Stop
+		}
+		if !lastStamp.Before(at) {
+			return fmt.Errorf(
+				"pause at %s must come after %s", at.Format(FormatDateTime), after)
+		}
+
+		b = &BreakSchema{Punch: punchIn.Punch, Project: punchIn.Project, Start: at}
+		return c.journaled(
+			fmt.Sprintf("pause '%s'", punchIn.Project),
+			[]rowKey{breaksKey(punchIn)},
+			func(tx *sql.Tx) error { return insertBreak(tx, b.ToSQL()) })
+	})
+	return b, e
+}
+
+// Resume ends the break client's open session is paused on, or the one
+// client's on the clock, if client is empty.
+func (c *Card) Resume(client string) (*BreakSchema, error) {
+	return c.ResumeAt(client, time.Time{})
+}
+
+// ResumeAt is Resume, but stamped `at` rather than now, if `at` is not the zero
+// value, as for PunchAt.
+func (c *Card) ResumeAt(client string, at time.Time) (*BreakSchema, error) {
+	var b *BreakSchema
+	e := c.Transact(func() error {
+		punchIn, last, e := c.openBreaks(client)
+		if e != nil {
+			return e
+		}
+		if at, e = c.punchStamp(at); e != nil {
+			return e
+		}
+		if last == nil || !last.IsPaused() {
+			return fmt.Errorf("'%s' isn't paused", punchIn.Project)
+		}
+		if !last.Start.Before(at) {
+			return fmt.Errorf("resume at %s must come after '%s' pause at %s",
+				at.Format(FormatDateTime), punchIn.Project, last.Start.Format(FormatDateTime))
+		}
+		if e := c.checkExclusive(punchIn.Project, at, time.Time{}, time.Time{}); e != nil {
+			return e
+		}
+
+		b = last
+		return c.journaled(
+			fmt.Sprintf("resume '%s'", punchIn.Project),
+			[]rowKey{breaksKey(punchIn)},
+			func(tx *sql.Tx) error {
+				if e := resumeBreak(tx, last, at); e != nil {
+					return e
+				}
+				b.Stop = at
+				return nil
+			})
+	})
+	return b, e
+}
+
+// Finds the punch-in of client's open session (or of the one client on the
+// clock, if client is empty), and the last break within it, if any.
+func (c *Card) openBreaks(client string) (*CardSchema, *BreakSchema, error) {
+	if len(client) == 0 {
+		implied, e := c.ImpliedClient()
+		if e != nil {
+			return nil, nil, e
+		}
+		client = implied
+	}
+	last, e := c.LastPunch(client)
+	if e != nil {
+		return nil, nil, e
+	}
+	if last == nil || !last.IsStart {
+		return nil, nil, fmt.Errorf("'%s' isn't punched in", client)
+	}
+	lastBreak, e := c.lastBreak(last)
+	return last, lastBreak, e
+}
+
+// The breaks, by start, within the session punchIn opened.
+func (c *Card) breaksOf(punchIn *CardSchema) ([]*BreakSchema, error) {
+	breaks, e := c.clientBreaks(punchIn.Project)
+	if e != nil {
+		return nil, e
+	}
+	return breaks.Of(punchIn), nil
+}
+
+// The latest break within the session punchIn opened, or nil if it has none.
+func (c *Card) lastBreak(punchIn *CardSchema) (*BreakSchema, error) {
+	breaks, e := c.breaksOf(punchIn)
+	if e != nil || len(breaks) == 0 {
+		return nil, e
+	}
+	return breaks[len(breaks)-1], nil
+}
+
+// Fails unless every one of breaks, within client's session about to close at
+// stop, ends by then, returning the one still paused, if any, for the close to
+// end. what names the close in errors, eg: "punch-out".
+func endingBreaks(
+	client, what string, breaks []*BreakSchema, stop time.Time) (*BreakSchema, error) {
+	var paused *BreakSchema
+	for _, b := range breaks {
+		if b.IsPaused() {
+			if !b.Start.Before(stop) {
+				return nil, fmt.Errorf("%s at %s must come after '%s' pause at %s",
+					what, stop.Format(FormatDateTime), client, b.Start.Format(FormatDateTime))
+			}
+			paused = b
+		} else if b.Stop.After(stop) {
+			return nil, fmt.Errorf("%s at %s must come after '%s' resume at %s",
+				what, stop.Format(FormatDateTime), client, b.Stop.Format(FormatDateTime))
+		}
+	}
+	return paused, nil
+}
@@ -0,0 +1,126 @@
+package punch
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPauseResume(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	at := func(minutes int) time.Time { return epoch.Add(time.Minute * time.Duration(minutes)) }
+
+	if _, e := c.PauseAt("acme", at(170)); e == nil ||
+		!strings.Contains(e.Error(), "isn't punched in") {
+		t.Errorf("expected pause while punched out refused, got: %v", e)
+	}
+	if _, e := c.PunchAt("acme", "", at(180)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+	if _, e := c.PauseAt("acme", at(180)); e == nil ||
+		!strings.Contains(e.Error(), "must come after 'acme' punch-in") {
+		t.Errorf("expected pause at punch-in refused, got: %v", e)
+	}
+	if _, e := c.ResumeAt("acme", at(185)); e == nil ||
+		!strings.Contains(e.Error(), "isn't paused") {
+		t.Errorf("expected resume while working refused, got: %v", e)
+	}
+	if _, e := c.PauseAt("", at(190)); e != nil {
+		t.Fatalf("pausing: %s", e)
+	}
+	if _, e := c.PauseAt("acme", at(195)); e == nil ||
+		!strings.Contains(e.Error(), "already paused") {
+		t.Errorf("expected second pause refused, got: %v", e)
+	}
+	if _, e := c.ResumeAt("acme", at(190)); e == nil ||
+		!strings.Contains(e.Error(), "must come after 'acme' pause") {
+		t.Errorf("expected resume at pause refused, got: %v", e)
+	}
+	if _, e := c.ResumeAt("acme", at(210)); e != nil {
+		t.Fatalf("resuming: %s", e)
+	}
+	if _, e := c.PauseAt("acme", at(220)); e != nil {
+		t.Fatalf("pausing: %s", e)
+	}
+	if _, e := c.PunchAt("acme", "", at(240)); e != nil {
+		t.Fatalf("punching out while paused: %s", e)
+	}
+
+	report, e := c.Report("acme", time.Time{})
+	if e != nil {
+		t.Fatalf("reporting: %s", e)
+	}
+	last := report.Sessions[len(report.Sessions)-1]
+	if len(last.Breaks) != 2 || last.Breaks[1].IsPaused() {
+		t.Fatalf("expected punch-out to close the second break, got %d breaks", len(last.Breaks))
+	}
+	if last.Gross() != time.Hour || last.BreakTime() != time.Minute*40 ||
+		last.Duration != time.Minute*20 {
+		t.Errorf("got %s gross, %s paused, %s net; expected 1h, 40m, 20m",
+			last.Gross(), last.BreakTime(), last.Duration)
+	}
+	if total := report.Total(); total != time.Minute*(10+20+50+20) {
+		t.Errorf("got total %s, expected breaks left out", total)
+	}
+
+	if _, e := c.Undo(); e != nil {
+		t.Fatalf("undoing punch-out: %s", e)
+	}
+	if report, e = c.Report("acme", time.Time{}); e != nil {
+		t.Fatalf("reporting: %s", e)
+	}
+	if since := PausedSince(report.OpenBreaks); !since.Equal(at(220)) {
+		t.Errorf("expected undo to leave acme paused since %s, got %s", at(220), since)
+	}
+}
+
+func TestSeekBreaks(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	at := func(minutes int) time.Time { return epoch.Add(time.Minute * time.Duration(minutes)) }
+
+	if _, e := c.PunchAt("acme", "", at(180)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+	if _, e := c.PauseAt("acme", at(190)); e != nil {
+		t.Fatalf("pausing: %s", e)
+	}
+	if _, e := c.PlanSeekClose("acme", at(180), at(185)); e == nil ||
+		!strings.Contains(e.Error(), "must come after 'acme' pause") {
+		t.Errorf("expected closing before the pause refused, got: %v", e)
+	}
+	plan, e := c.PlanSeekClose("acme", at(180), at(210))
+	if e != nil {
+		t.Fatalf("planning close: %s", e)
+	}
+	if d := plan.Session().Duration; d != time.Minute*10 {
+		t.Errorf("expected planned session to leave out its break, got %s", d)
+	}
+	if e := c.Seek(plan); e != nil {
+		t.Fatalf("closing while paused: %s", e)
+	}
+
+	report, e := c.Report("acme", time.Time{})
+	if e != nil {
+		t.Fatalf("reporting: %s", e)
+	}
+	last := report.Sessions[len(report.Sessions)-1]
+	if len(last.Breaks) != 1 || !last.Breaks[0].Stop.Equal(at(210)) {
+		t.Errorf("expected close to end the break, got breaks %v", last.Breaks)
+	}
+	if _, e := c.PlanSeekPunchOut("acme", at(210), at(200)); e == nil ||
+		!strings.Contains(e.Error(), "must come after 'acme' resume") {
+		t.Errorf("expected rewinding into the break refused, got: %v", e)
+	}
+
+	if _, e := c.Undo(); e != nil {
+		t.Fatalf("undoing close: %s", e)
+	}
+	if report, e = c.Report("acme", time.Time{}); e != nil {
+		t.Fatalf("reporting: %s", e)
+	}
+	if since := PausedSince(report.OpenBreaks); !since.Equal(at(190)) {
+		t.Errorf("expected undo to leave acme paused since %s, got %s", at(190), since)
+	}
+}
@@ -0,0 +1,259 @@
+// Package punch manages an sqlite3 time-tracking database, a "punch card", that
+// records sessions of work on any number of clients and the pay periods billed
+// against them.
+//
+// The `punch` command-line tool, under ./cmd/punch, is a thin client of this
+// package.
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// BusyTimeout is how long a change to the card waits on another punch process
+// that's in the middle of changing it, before giving up. Cards already open keep
+// the timeout they were opened with.
+var BusyTimeout time.Duration = time.Second * 5
+
+// Card is an open punch card database.
+type Card struct {
+	db   *sql.DB
+	path string
+
+	// Non-nil while within Transact; every query is run on it, when it is.
+	tx *sql.Tx
+
+	// Source of "now" for any operations relative to the current time; defaults
+	// to SystemClock.
+	Clock Clock
+
+	// Set if Open had to migrate the card to the latest schema.
+	Upgrade *Upgrade
+
+	// Recorded in the journal with each change made, eg: the command-line that
+	// made it; defaults to a summary of the change.
+	Command string
+
+	// Policy on different clients' sessions overlapping; defaults to
+	// ConcurrencyAllow.
+	Concurrency Concurrency
+}
+
+// Open expects dbPath to be an existing punch card, see Create otherwise. Cards
+// of an older schema are first backed up, then migrated to the latest.
+func Open(dbPath string) (*Card, error) {
+	c, e := OpenUnmigrated(dbPath)
+	if e != nil {
+		return nil, e
+	}
+	if c.Upgrade, e = c.upgrade(); e != nil {
+		c.Close()
+		return nil, fmt.Errorf("upgrading card schema: %s", e)
+	}
+	return c, nil
+}
+
+// OpenUnmigrated is Open, but leaves the card at whatever schema version it's
+// found, eg: to inspect its PendingMigrations before calling Migrate.
+func OpenUnmigrated(dbPath string) (*Card, error) {
+	// Every transaction is IMMEDIATE, claiming the card for writing from the
+	// start, so what a change reads can't be changed by another process before
+	// it writes.
+	driverName := "sqlite3"
+	if Trace != nil {
+		driverName = traceDriverName
+	}
+	db, e := sql.Open(driverName, fmt.Sprintf(
+		"%s?_txlock=immediate&_busy_timeout=%d",
+		dbPath, int64(BusyTimeout/time.Millisecond)))
+	if e != nil {
+		return nil, fmt.Errorf("opening sqlite3: %s", e)
+	}
+	return &Card{db: db, path: dbPath, Clock: SystemClock}, nil
+}
+
+// Create starts a new, empty punch card at dbPath.
+func Create(dbPath string) (*Card, error) {
+	c, e := OpenUnmigrated(dbPath)
+	if e != nil {
+		return nil, e
+	}
+	if _, e := c.Migrate(); e != nil {
+		c.Close()
+		return nil, fmt.Errorf("creating tables: %s", e)
+	}
+	return c, nil
+}
+
+func (c *Card) Close() error { return c.db.Close() }
+
+// Either of sql.DB or sql.Tx.
+type dbConn interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// What to run queries on: the current transaction, if there is one.
+func (c *Card) conn() dbConn {
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.db
+}
+
+// Transact runs fn within one transaction on the card, so that nothing fn reads
+// can be changed by another process before fn's own changes are committed. The
+// transaction is rolled back if fn fails. Transact within fn just runs within
+// the same transaction.
+func (c *Card) Transact(fn func() error) error {
+	if c.tx != nil {
+		return fn()
+	}
+
+	tx, e := c.db.Begin()
+	if e != nil {
+		return lockedOr(e, "starting transaction")
+	}
+	c.tx = tx
+	defer func() { c.tx = nil }()
+
+	if e := fn(); e != nil {
+		tx.Rollback()
+		return e
+	}
+	if e := tx.Commit(); e != nil {
+		return lockedOr(e, "committing transaction")
+	}
+	return nil
+}
+
+// Explains e if it's sqlite giving up on waiting for the card to be unlocked,
+// or else wraps it as an error while doing `what`.
+func lockedOr(e error, what string) error {
+	if se, ok := e.(sqlite3.Error); ok &&
+		(se.Code == sqlite3.ErrBusy || se.Code == sqlite3.ErrLocked) {
+		return fmt.Errorf(
+			"punch card is locked, still being changed by another punch after %s; try again",
+			BusyTimeout)
+	}
+	return fmt.Errorf("%s: %s", what, e)
+}
+
+func scanToCard(rows *sql.Rows) (*CardSchema, error) {
+	raw := &CardSchemaSQL{}
+	if e := rows.Scan(&raw.Punch, &raw.Status, &raw.Project, &raw.Note); e != nil {
+		return nil, e
+	}
+	return raw.ToCard(), nil
+}
+
+func scanToBill(rows *sql.Rows) (*BillSchema, error) {
+	raw := &BillSchemaSQL{}
+	e := rows.Scan(&raw.Endclusive, &raw.Startclusive, &raw.Project, &raw.Note)
+	if e != nil {
+		return nil, e
+	}
+	return raw.ToBill(), nil
+}
+
+func scanToRate(rows *sql.Rows) (*RateSchema, error) {
+	raw := &RateSchemaSQL{}
+	e := rows.Scan(
+		&raw.Project, &raw.Effective, &raw.HourlyCents,
+		&raw.Currency, &raw.RoundMinutes, &raw.PerSession)
+	if e != nil {
+		return nil, e
+	}
+	return raw.ToRate(), nil
+}
+
+func scanToJournalEntry(rows *sql.Rows) (*JournalEntry, error) {
+	raw := &JournalEntrySQL{}
+	e := rows.Scan(
+		&raw.ID, &raw.At, &raw.Command, &raw.Before, &raw.After, &raw.Undone)
+	if e != nil {
+		return nil, e
+	}
+	return raw.ToEntry()
+}
+
+// Runs `query` and scans every resulting row as a punchcard record.
+func (c *Card) queryCards(query string, args ...interface{}) ([]*CardSchema, error) {
+	rows, e := c.conn().Query(query, args...)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var cards []*CardSchema
+	for rows.Next() {
+		card, e := scanToCard(rows)
+		if e != nil {
+			return nil, e
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+// Runs `query` and scans every resulting row as a paychecks record.
+func (c *Card) queryBills(query string, args ...interface{}) ([]*BillSchema, error) {
+	rows, e := c.conn().Query(query, args...)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var bills []*BillSchema
+	for rows.Next() {
+		b, e := scanToBill(rows)
+		if e != nil {
+			return nil, e
+		}
+		bills = append(bills, b)
+	}
+	return bills, rows.Err()
+}
+
+// Runs `query` and scans every resulting row as a rates record.
+func (c *Card) queryRates(query string, args ...interface{}) ([]*RateSchema, error) {
+	rows, e := c.conn().Query(query, args...)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var rates []*RateSchema
+	for rows.Next() {
+		r, e := scanToRate(rows)
+		if e != nil {
+			return nil, e
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+// Runs `query` and scans every resulting row as a journal record.
+func (c *Card) queryJournal(query string, args ...interface{}) ([]*JournalEntry, error) {
+	rows, e := c.conn().Query(query, args...)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var entries []*JournalEntry
+	for rows.Next() {
+		entry, e := scanToJournalEntry(rows)
+		if e != nil {
+			return nil, e
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,62 @@
+package punch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransact(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	last := func() time.Time {
+		card, e := c.LastPunch("acme")
+		if e != nil {
+			t.Fatalf("reading last punch: %s", e)
+		}
+		return card.Punch
+	}
+	lastBefore := last()
+
+	// A failure part way through undoes what came before it
+	failure := errors.New("failed on purpose")
+	if e := c.Transact(func() error {
+		if _, e := c.PunchAt("acme", "", epoch.Add(time.Hour*3)); e != nil {
+			t.Fatalf("punching within transaction: %s", e)
+		}
+		if !last().Equal(epoch.Add(time.Hour * 3)) {
+			t.Errorf("expected punch to be visible within its own transaction")
+		}
+		return failure
+	}); e != failure {
+		t.Fatalf("expected transaction's own failure, got %v", e)
+	}
+	if got := last(); !got.Equal(lastBefore) {
+		t.Errorf("expected punch to be rolled back, but last punch is at %s", got)
+	}
+
+	defer func(timeout time.Duration) { BusyTimeout = timeout }(BusyTimeout)
+	BusyTimeout = time.Millisecond * 50
+	other, e := Open(c.path)
+	if e != nil {
+		t.Fatalf("opening card a second time: %s", e)
+	}
+	defer other.Close()
+	other.Clock = c.Clock
+
+	// Another process can't change the card while this one is
+	if e := c.Transact(func() error {
+		_, e := other.PunchAt("acme", "", epoch.Add(time.Hour*3))
+		if e == nil || !strings.Contains(e.Error(), "locked") {
+			t.Errorf("expected card to be locked to other processes, got %v", e)
+		}
+		return nil
+	}); e != nil {
+		t.Fatalf("holding transaction: %s", e)
+	}
+	if _, e := other.PunchAt("acme", "", epoch.Add(time.Hour*3)); e != nil {
+		t.Errorf("expected card to be unlocked again, got %s", e)
+	}
+}
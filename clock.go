@@ -0,0 +1,22 @@
+package punch
+
+import (
+	"time"
+)
+
+// Clock tells the time to everything on a Card that needs to know "now".
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock is the real, wall-clock time; the default for any Card.
+var SystemClock Clock = systemClock{}
+
+// FixedClock is always the same time, eg: for tests.
+type FixedClock time.Time
+
+func (f FixedClock) Now() time.Time { return time.Time(f) }
@@ -1,79 +1,96 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
-	"strconv"
+	"github.com/jzacsh/punch"
 	"strings"
 	"time"
 )
 
-// TARGET_STAMP, [NOTE], error
-func parseAmendCli(args []string) (time.Time, string, error) {
-	var target time.Time
+type AmendCmd struct {
+	Client string // empty to match a punch of any client
+	Target time.Time
+	Note   string // empty to delete the punch's note
+	IsYes  bool   // skips confirmation
+}
+
+func parseAmendCli(args []string, now time.Time) (*AmendCmd, error) {
+	cmd := &AmendCmd{}
+	for len(args) > 0 && (args[0] == "--client" || isYesFlag(args[0])) {
+		if isYesFlag(args[0]) {
+			cmd.IsYes = true
+			args = args[1:]
+			continue
+		}
+		if len(args) < 2 {
+			return nil, fmt.Errorf("--client passed, but no CLIENT found")
+		}
+		cmd.Client = strings.TrimSpace(args[1])
+		if !punch.IsValidClient(cmd.Client) {
+			return nil, fmt.Errorf("invalid CLIENT, '%s'", cmd.Client)
+		}
+		args = args[2:]
+	}
+
 	if len(args) < 1 {
-		return target, "", fmt.Errorf("argument TARGET_STAMP is required")
+		return nil, fmt.Errorf("argument TARGET_STAMP is required")
 	}
 
-	targetStamp, e := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+	target, e := parseStampCommand(args[0], now)
 	if e != nil {
-		return target, "", fmt.Errorf("parsing unix timestamp, TARGET_STAMP ('%s'), %s", args[0], e)
+		return nil, fmt.Errorf("parsing TARGET_STAMP ('%s'), %s", args[0], e)
 	}
+	cmd.Target = target
 
-	target = time.Unix(targetStamp, 0 /*nanoseconds*/)
-
-	var replacement string
 	if len(args) > 1 {
-		replacement = strings.TrimSpace(strings.Join(args[1:], " "))
+		cmd.Note = strings.TrimSpace(strings.Join(args[1:], " "))
 	}
 
-	return target, replacement, nil
+	return cmd, nil
 }
 
-func subCmdAmend(dbPath string, args []string) error {
-	target, note, e := parseAmendCli(args)
+func subCmdAmend(clock punch.Clock, dbPath string, args []string) error {
+	cmd, e := parseAmendCli(args, clock.Now())
 	if e != nil {
 		return e
 	}
-	isDeletion := len(note) < 1
 
-	db, e := sql.Open("sqlite3", dbPath)
+	card, e := openCard(clock, dbPath)
 	if e != nil {
 		return fmt.Errorf("punch cards: %s", e)
 	}
-	defer db.Close()
+	defer card.Close()
 
 	noteAction := "update"
-	if isDeletion {
+	if len(cmd.Note) < 1 {
 		noteAction = "delete"
 	}
 
-	stmt, e := db.Prepare(`
-		UPDATE punchcard
-		SET note = ?
-		WHERE punch = ?
-	;`)
-	if e != nil {
-		return fmt.Errorf("preparing db modification: %s", e)
-	}
-
-	// TODO make this interactive (with a -q(uiet) flag to not ask)
-	r, e := stmt.Exec(note, target.Unix())
-	if e != nil {
-		return fmt.Errorf("trying to %s note: %s", noteAction, e)
-	}
-	a, e := r.RowsAffected()
-	if e != nil {
-		return fmt.Errorf("trying to parse results of %s: %s", noteAction, e)
-	}
-
-	if a != 1 {
-		return fmt.Errorf("expected 1 punch record affected, but got %d", a)
+	var amendment *punch.Amendment
+	isApplied, e := previewThenApply(card, func() (proceed, error) {
+		var e error
+		amendment, e = card.PlanAmendment(cmd.Client, cmd.Target, cmd.Note)
+		if e != nil {
+			return proceedNever, e
+		}
+		target := amendment.Punch
+		fmt.Printf("Will %s note on '%s' punch-%s at %s [%s]:\n\tfrom: '%s'\n",
+			noteAction, target.Project, punch.FromStatus(target.IsStart),
+			target.Punch.Format(punch.FormatDateTime),
+			getTZContext(clock.Now(), target.Punch), punch.FromNote(target.Note))
+		if !amendment.IsDeletion() {
+			fmt.Printf("\tto:   '%s'\n", amendment.Note)
+		}
+		return proceedFor(false /*isDryRun*/, cmd.IsYes), nil
+	}, func() error {
+		return card.Amend(amendment)
+	})
+	if e != nil || !isApplied {
+		return e
 	}
 
 	fmt.Printf(
 		"Done: successfully %sd note on %s punch\n",
-		noteAction, target.Format(format_dateTime))
+		noteAction, cmd.Target.Format(punch.FormatDateTime))
 	return nil
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAmendCli(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args   []string
+		client string
+		target time.Time
+		note   string
+	}{
+		{[]string{"@1491963757"}, "", time.Unix(1491963757, 0), ""},
+		{[]string{"1491963757", "all", "done"}, "", time.Unix(1491963757, 0), "all done"},
+		{[]string{"-15m", "  padded "}, "", now.Add(-time.Minute * 15), "padded"},
+		{
+			[]string{"--client", "acme", "@1491963757", "--client"},
+			"acme", time.Unix(1491963757, 0), "--client",
+		},
+		{
+			[]string{"-y", "--client", "acme", "@1491963757", "-y"},
+			"acme", time.Unix(1491963757, 0), "-y",
+		},
+	} {
+		cmd, e := parseAmendCli(tt.args, now)
+		if e != nil {
+			t.Errorf("parseAmendCli(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if cmd.Client != tt.client || !cmd.Target.Equal(tt.target) || cmd.Note != tt.note {
+			t.Errorf(
+				"parseAmendCli(%q): got ('%s', %s, '%s'), expected ('%s', %s, '%s')",
+				tt.args, cmd.Client, cmd.Target, cmd.Note, tt.client, tt.target, tt.note)
+		}
+		if isYes := tt.args[0] == "-y"; cmd.IsYes != isYes {
+			t.Errorf("parseAmendCli(%q): got yes %t", tt.args, cmd.IsYes)
+		}
+	}
+}
+
+func TestParseAmendCliErrors(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{nil, "TARGET_STAMP is required"},
+		{[]string{"whenever", "note"}, "parsing TARGET_STAMP ('whenever')"},
+		{[]string{"--client"}, "no CLIENT found"},
+		{[]string{"--client", "ac me", "@1491963757"}, "invalid CLIENT"},
+		{[]string{"--client", "acme"}, "TARGET_STAMP is required"},
+	} {
+		_, e := parseAmendCli(tt.args, now)
+		if e == nil {
+			t.Errorf("parseAmendCli(%q): expected error, got none", tt.args)
+			continue
+		}
+		if !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf(
+				"parseAmendCli(%q): expected error containing '%s', got: %s",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestAmendE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "amend_note",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("a", "-y", "@1491963757", "shipped", "v1"),
+				step("a", "-y", "@1491915956"),
+				step("q", "report", "spaceship", "@1491915900"),
+				step("a", "-y", "@1491963758", "nope"),
+			},
+		},
+		{
+			name:    "amend_shared_stamp",
+			fixture: sharedStampFixture,
+			steps: []e2eStep{
+				step("a", "-y", "-1h", "whose note?"),
+				step("a", "-y", "--client", "spaceship", "-1h", "spaceship's note"),
+				step("a", "-y", "--client", "nobody", "-1h", "nobody's note"),
+				step("q", "report", "spaceship", "-2h"),
+			},
+		},
+		{
+			name:    "amend_confirmation",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("a", "@1491963757", "shipped"),
+				stepWithInput("n\n", "a", "@1491963757", "shipped"),
+				stepWithInput("y\n", "a", "@1491963757", "shipped"),
+				stepWithInput("y\n", "a", "@1491963757"),
+			},
+		},
+		{
+			name:    "amend_empty_card",
+			fixture: emptyFixture,
+			steps:   []e2eStep{step("a", "@1491963757", "nope")},
+		},
+	})
+}
@@ -1,98 +1,25 @@
 package main
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/jzacsh/punch"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
 
-func getImpliedToStamp(db *sql.DB, client string) (int64, error) {
-	rows, e := db.Query(`
-		SELECT * FROM punchcard
-		WHERE project IS ?
-		ORDER BY punch DESC
-		LIMIT 2;
-	`, client)
-	if e != nil {
-		return 0, e
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		c, e := scanToCard(rows)
-		if e != nil {
-			return 0, e
-		}
-		if c.IsStart {
-			continue
-		}
-
-		return c.Punch.Unix(), nil
-	}
-
-	return 0, fmt.Errorf(
-		"implied TO stamp, but no full work records found", client)
-}
+// isDryRun, isYes (skipping confirmation), bill, error
+func parsePayPeriodArgs(card *punch.Card, args []string) (bool, bool, *punch.BillSchema, error) {
+	isDryRun, isYes := false, false
 
-func getImpliedFromStamp(db *sql.DB, client string) (int64, error) {
-	rows, e := db.Query(`
-		SELECT * FROM paychecks
-		WHERE project IS ?
-		ORDER BY endclusive DESC
-		LIMIT 1;
-	`, client)
-	if e != nil {
-		return 0, e
+	if len(args) < 1 {
+		return isDryRun, isYes, nil, errors.New("CLIENT is required")
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		b, e := scanToBill(rows)
-		if e != nil {
-			return 0, e
-		}
-
-		return b.Endclusive.Unix(), nil
-	}
-
-	// If here, then no previous paycheck, so *all* of history is implied
-	// beginning of paycheck...
-
-	rows, e = db.Query(`
-		SELECT * FROM punchcard
-		WHERE project IS ?
-		ORDER BY punch ASC
-		LIMIT 1;
-	`, client)
-	if e != nil {
-		return 0, e
-	}
-	defer rows.Close() // TODO funky to have two rows.Close() deferals?
-
-	for rows.Next() {
-		c, e := scanToCard(rows)
-		if e != nil {
-			return 0, e
-		}
-
-		return c.Punch.Unix(), nil
-	}
-
-	return 0, fmt.Errorf(
-		"implied '%s' FROM impossible without work or payperiod history", client)
-}
-
-func parsePayPeriodArgs(db *sql.DB, args []string) (bool, *BillSchema, error) {
-	isDryRun := false
 
 	client := strings.TrimSpace(args[0])
-	if !isValidClient(client) {
-		return isDryRun, nil, fmt.Errorf("invalid CLIENT: '%s'", client)
+	if !punch.IsValidClient(client) {
+		return isDryRun, isYes, nil, fmt.Errorf("invalid CLIENT: '%s'", client)
 	}
 
 	isImpliedFrom := true
@@ -100,7 +27,7 @@ func parsePayPeriodArgs(db *sql.DB, args []string) (bool, *BillSchema, error) {
 
 	var e error
 	var note string
-	var fromStamp, toStamp int64
+	var from, to time.Time
 	if len(args) > 1 {
 		for i := 1; i < len(args); i++ {
 			switch args[i] {
@@ -108,91 +35,107 @@ func parsePayPeriodArgs(db *sql.DB, args []string) (bool, *BillSchema, error) {
 				noteStartIdx := i + 1
 				note = strings.TrimSpace(strings.Join(args[noteStartIdx:], " "))
 				if len(note) < 1 {
-					return isDryRun, nil, errors.New("-n passed, but no NOTE found.")
+					return isDryRun, isYes, nil, errors.New("-n passed, but no NOTE found.")
 				}
 				i = len(args) // end for loop
 
 			case "-d":
 				isDryRun = true
 
+			case "-y", "-q":
+				isYes = true
+
 			case "-f":
-				fromStamp, e = strconv.ParseInt(strings.TrimSpace(args[i+1]), 10, 64)
+				if i+1 >= len(args) {
+					return isDryRun, isYes, nil, errors.New("-f passed, but no FROM stamp found")
+				}
+				from, e = parseStampCommand(args[i+1], card.Clock.Now())
 				if e != nil {
-					return isDryRun, nil, fmt.Errorf(
-						"bad FROM timestamp, '%s'", args[i+1])
+					return isDryRun, isYes, nil, fmt.Errorf(
+						"bad FROM timestamp, '%s': %s", args[i+1], e)
 				}
 				isImpliedFrom = false
 				i++ // skip FROM stamp
 
 			case "-t":
-				toStamp, e = strconv.ParseInt(strings.TrimSpace(args[i+1]), 10, 64)
+				if i+1 >= len(args) {
+					return isDryRun, isYes, nil, errors.New("-t passed, but no TO stamp found")
+				}
+				to, e = parseStampCommand(args[i+1], card.Clock.Now())
 				if e != nil {
-					return isDryRun, nil, fmt.Errorf(
-						"bad TO timestamp, '%s'", args[i+1])
+					return isDryRun, isYes, nil, fmt.Errorf(
+						"bad TO timestamp, '%s': %s", args[i+1], e)
 				}
 				isImpliedTo = false
 				i++ // skip TO stamp
 
 			default:
-				return isDryRun, nil, fmt.Errorf(
+				return isDryRun, isYes, nil, fmt.Errorf(
 					"unrecognized commandline at '%s'", args[i:])
 			}
 		}
 	}
 
 	if isImpliedFrom {
-		fromStamp, e = getImpliedFromStamp(db, client)
+		from, e = card.ImpliedBillStart(client)
 		if e != nil {
-			return isDryRun, nil, e
+			return isDryRun, isYes, nil, e
 		}
 	}
 
 	if isImpliedTo {
-		toStamp, e = getImpliedToStamp(db, client)
+		to, e = card.ImpliedBillEnd(client)
 		if e != nil {
-			return isDryRun, nil, e
+			return isDryRun, isYes, nil, e
 		}
 	}
 
-	if fromStamp >= toStamp {
-		return isDryRun, nil, errors.New("expected FROM to be older stamp than TO")
+	if !from.Before(to) {
+		return isDryRun, isYes, nil, errors.New("expected FROM to be older stamp than TO")
 	}
 
-	return isDryRun, &BillSchema{
-		Endclusive:   time.Unix(toStamp, 0 /*nanoseconds*/),
-		Startclusive: time.Unix(fromStamp, 0 /*nanoseconds*/),
+	return isDryRun, isYes, &punch.BillSchema{
+		Endclusive:   to,
+		Startclusive: from,
 		Project:      client,
 		Note:         note,
 	}, nil
 }
 
-func commitPayPeriod(db *sql.DB, b *BillSchemaSQL) error {
-	stmt, e := db.Prepare(`
-		INSERT INTO
-		paychecks(endclusive, startclusive, project, note)
-		VALUES (?, ?, ?, ?)
-	`)
+func subCmdBill(clock punch.Clock, dbPath string, args []string) error {
+	card, e := openCard(clock, dbPath)
 	if e != nil {
-		return e
+		return fmt.Errorf("bill sql: %s", e)
 	}
+	defer card.Close()
 
-	// TODO(zacsh) expose result val here via debug flags on cli
-	_, e = stmt.Exec(b.Endclusive, b.Startclusive, b.Project, b.Note)
+	var bill *punch.BillSchema
+	isApplied, e := previewThenApply(card, func() (proceed, error) {
+		var e error
+		var isDryRun, isYes bool
+		if isDryRun, isYes, bill, e = parsePayPeriodArgs(card, args); e != nil {
+			return proceedNever, fmt.Errorf("parse args: %s", e)
+		}
+		if e := previewBill(card, bill); e != nil {
+			return proceedNever, e
+		}
+		if isDryRun {
+			fmt.Fprintf(os.Stderr, "\n[-d]ry-run mode; NOT writing any changes\n")
+		}
+		return proceedFor(isDryRun, isYes), nil
+	}, func() error {
+		return card.CreateBill(bill)
+	})
+	if e != nil || !isApplied {
+		return e
+	}
 
-	return e
+	fmt.Fprintf(os.Stderr, "Done.\n")
+	return nil
 }
 
-func subCmdBill(dbPath string, args []string) error {
-	db, e := sql.Open("sqlite3", dbPath)
-	if e != nil {
-		return fmt.Errorf("bill sql: %s", e)
-	}
-	defer db.Close()
-
-	isDryRun, bill, e := parsePayPeriodArgs(db, args)
-	if e != nil {
-		return fmt.Errorf("parse args: %s", e)
-	}
+// Prints the pay period bill will create, and what it's worth.
+func previewBill(card *punch.Card, bill *punch.BillSchema) error {
 
 	var note string
 	if len(bill.Note) > 0 {
@@ -200,24 +143,25 @@ func subCmdBill(dbPath string, args []string) error {
     %s`, bill.Note)
 	}
 
+	reports, e := card.ReportBills([]*punch.BillSchema{bill})
+	if e != nil {
+		return e
+	}
+	var worth string
+	if amount := reports[0].Amount; amount != nil {
+		worth = fmt.Sprintf("      worth %s (%s billable)\n",
+			amount, punch.DurationToStr(reports[0].Billable))
+	}
+
 	fmt.Fprintf(os.Stderr, `    Will create bill for '%s':
       from '%s'
       to   '%s'
-    %s%s`,
+%s    %s%s`,
 		bill.Project,
 		bill.Startclusive,
 		bill.Endclusive,
+		worth,
 		note,
 		"\n")
-
-	if isDryRun {
-		fmt.Fprintf(os.Stderr, "\n[-d]ry-run mode; NOT writing any changes\n")
-		return nil
-	}
-
-	e = commitPayPeriod(db, bill.toSQL())
-	if e == nil {
-		fmt.Fprintf(os.Stderr, "Done.\n")
-	}
-	return e
+	return nil
 }
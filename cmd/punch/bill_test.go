@@ -0,0 +1,175 @@
+package main
+
+import (
+	"github.com/jzacsh/punch"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePayPeriodArgs(t *testing.T) {
+	dbPath, cleanup := sampleFixture(t)
+	defer cleanup()
+	card, e := openCard(punch.FixedClock(sampleNow), dbPath)
+	if e != nil {
+		t.Fatalf("opening fixture: %s", e)
+	}
+	defer card.Close()
+
+	for _, tt := range []struct {
+		args     []string
+		isDryRun bool
+		expected punch.BillSchema
+	}{
+		{
+			[]string{"spaceship"},
+			false,
+			punch.BillSchema{
+				Startclusive: time.Unix(1491920098, 0), // end of last bill
+				Endclusive:   time.Unix(1491963757, 0), // last punch-out
+				Project:      "spaceship",
+			},
+		},
+		{
+			[]string{"spaceship", "-d", "-n", "final", "invoice"},
+			true,
+			punch.BillSchema{
+				Startclusive: time.Unix(1491920098, 0),
+				Endclusive:   time.Unix(1491963757, 0),
+				Project:      "spaceship",
+				Note:         "final invoice",
+			},
+		},
+		{
+			[]string{"golangpunch", "-f", "@1491600000", "-t", "2017-04-09T00:00:00Z"},
+			false,
+			punch.BillSchema{
+				Startclusive: time.Unix(1491600000, 0),
+				Endclusive:   time.Unix(1491696000, 0),
+				Project:      "golangpunch",
+			},
+		},
+		{
+			[]string{"golangpunch", "-t", "-1h", "-d"},
+			true,
+			punch.BillSchema{
+				Startclusive: time.Unix(1491853152, 0),
+				Endclusive:   sampleNow.Add(-time.Hour),
+				Project:      "golangpunch",
+			},
+		},
+		{
+			[]string{"newclient", "-f", "@1491600000", "-t", "@1491700000"},
+			false,
+			punch.BillSchema{
+				Startclusive: time.Unix(1491600000, 0),
+				Endclusive:   time.Unix(1491700000, 0),
+				Project:      "newclient",
+			},
+		},
+	} {
+		isDryRun, _, bill, e := parsePayPeriodArgs(card, tt.args)
+		if e != nil {
+			t.Errorf("parsePayPeriodArgs(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if isDryRun != tt.isDryRun {
+			t.Errorf("parsePayPeriodArgs(%q): got dry-run %t", tt.args, isDryRun)
+		}
+		if !bill.Startclusive.Equal(tt.expected.Startclusive) ||
+			!bill.Endclusive.Equal(tt.expected.Endclusive) ||
+			bill.Project != tt.expected.Project ||
+			bill.Note != tt.expected.Note {
+			t.Errorf(
+				"parsePayPeriodArgs(%q): got %s, expected %s",
+				tt.args, bill.String(true), tt.expected.String(true))
+		}
+	}
+
+	if _, isYes, _, e := parsePayPeriodArgs(card, []string{"spaceship", "-y"}); e != nil || !isYes {
+		t.Errorf("parsePayPeriodArgs(-y): got yes %t (error: %v)", isYes, e)
+	}
+}
+
+func TestParsePayPeriodArgsErrors(t *testing.T) {
+	dbPath, cleanup := sampleFixture(t)
+	defer cleanup()
+	card, e := openCard(punch.FixedClock(sampleNow), dbPath)
+	if e != nil {
+		t.Fatalf("opening fixture: %s", e)
+	}
+	defer card.Close()
+
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{nil, "CLIENT is required"},
+		{[]string{"bad client"}, "invalid CLIENT"},
+		{[]string{"golangpunch"}, "expected FROM to be older stamp than TO"},
+		{[]string{"spaceship", "-n"}, "no NOTE found"},
+		{[]string{"spaceship", "-f"}, "no FROM stamp found"},
+		{[]string{"spaceship", "-t"}, "no TO stamp found"},
+		{[]string{"spaceship", "-f", "whenever"}, "bad FROM timestamp"},
+		{[]string{"spaceship", "-t", "whenever"}, "bad TO timestamp"},
+		{[]string{"spaceship", "-x"}, "unrecognized commandline"},
+		{[]string{"newclient"}, "impossible without work or payperiod history"},
+		{[]string{"newclient", "-f", "@1491600000"}, "no full 'newclient' work records"},
+	} {
+		_, _, _, e := parsePayPeriodArgs(card, tt.args)
+		if e == nil {
+			t.Errorf("parsePayPeriodArgs(%q): expected error, got none", tt.args)
+			continue
+		}
+		if !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf(
+				"parsePayPeriodArgs(%q): expected error containing '%s', got: %s",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestBillE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "bill_implied",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("bill", "spaceship", "-d"),
+				step("bill", "spaceship", "-y", "-n", "april", "invoice"),
+				step("q", "bills", "spaceship"),
+				step("q", "bills", "-last", "spaceship"),
+				step("bill", "spaceship", "-y"),
+			},
+		},
+		{
+			name:    "bill_explicit",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("bill", "golangpunch", "-y", "-f", "2017-04-10", "-t", "2017-04-11"),
+				step("bill", "golangpunch", "-y", "-f", "2017-04-11", "-t", "2017-04-10"),
+				step("q", "bills"),
+			},
+		},
+		{
+			name:    "bill_totals",
+			fixture: openFixture,
+			steps: []e2eStep{
+				// clips the session from 21:31:38 to 02:22:37 at either end
+				step("bill", "spaceship", "-y", "-f", "2017-04-11 22:00", "-t", "2017-04-12 01:00"),
+				step("bill", "golangpunch", "-y", "-f", "2017-04-12", "-t", "-1m"),
+				step("q", "bills"),
+				step("--format", "csv", "q", "bills"),
+			},
+		},
+		{
+			name:    "bill_empty_card",
+			fixture: emptyFixture,
+			steps: []e2eStep{
+				step("bill", "acme", "-y"),
+				step("bill"),
+				step("q", "bills"),
+			},
+		},
+	})
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"github.com/jzacsh/punch"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 )
 
 var helpRegexp *regexp.Regexp = regexp.MustCompile("(\b|^)(help|h)(\b|$)")
@@ -33,83 +35,241 @@ func isDbReadableNonemptyFile() (string, os.FileInfo, error) {
 	return p, f, nil
 }
 
-func maybeHandleHelpCli() {
-	firstArgChars := strings.Replace(os.Args[1], "-", "", -1)
+func maybeHandleHelpCli(args []string) bool {
+	firstArgChars := strings.Replace(args[1], "-", "", -1)
 	if !helpRegexp.MatchString(firstArgChars) {
-		return
+		return false
 	}
-	subCmdHelp(firstArgChars, os.Args[1:])
-	os.Exit(0)
+	subCmdHelp(firstArgChars, args[1:])
+	return true
 }
 
+// The command-line punch was run with, less the program name and any global
+// flags; journaled with each change it makes to the card.
+var commandLine string
+
+// Opens the punch card at dbPath, per the time reported by clock, noting any
+// schema upgrade that opening it required.
+func openCard(clock punch.Clock, dbPath string) (*punch.Card, error) {
+	card, e := punch.Open(dbPath)
+	if e != nil {
+		return nil, e
+	}
+	card.Clock = clock
+	card.Command = commandLine
+
+	if card.Concurrency, e = loadConcurrency(); e != nil {
+		card.Close()
+		return nil, e
+	}
+	if up := card.Upgrade; up != nil {
+		fmt.Fprintf(os.Stderr,
+			"Upgraded punch card from schema v%d to v%d; backup of v%d kept at: %s\n",
+			up.From, up.To, up.From, up.BackupPath)
+	}
+	return card, nil
+}
+
+// Environment variable that, if non-empty, acts as --debug.
+const debugEnvVar string = "PUNCH_DEBUG"
+
+// Options passed before any sub-command.
+type globalFlags struct {
+	format   outputFormat
+	location *time.Location // nil unless --tz was passed
+	isDebug  bool           // to trace SQL to stderr
+}
+
+// Strips any global flags from the front of `args` (as os.Args would be),
+// returning the remaining args, still led by the program name.
+func parseGlobalFlags(args []string) (globalFlags, []string, error) {
+	flags := globalFlags{format: formatText}
+	for len(args) > 1 && (args[1] == "--format" || args[1] == "--tz" || args[1] == "--debug") {
+		if args[1] == "--debug" {
+			flags.isDebug = true
+			args = append([]string{args[0]}, args[2:]...)
+			continue
+		}
+		if len(args) < 3 {
+			what := "FORMAT"
+			if args[1] == "--tz" {
+				what = "ZONE"
+			}
+			return flags, args, fmt.Errorf("%s passed, but no %s found", args[1], what)
+		}
+		var e error
+		if args[1] == "--format" {
+			flags.format, e = parseOutputFormat(args[2])
+		} else {
+			flags.location, e = parseZone(args[2])
+		}
+		if e != nil {
+			return flags, args, e
+		}
+		args = append([]string{args[0]}, args[3:]...)
+	}
+	return flags, args, nil
+}
+
+// Reads ZONE, an IANA timezone name like "America/New_York", or "UTC" or
+// "Local".
+func parseZone(zone string) (*time.Location, error) {
+	loc, e := time.LoadLocation(strings.TrimSpace(zone))
+	if e != nil || len(strings.TrimSpace(zone)) == 0 {
+		return nil, fmt.Errorf(
+			"expected ZONE like America/New_York or UTC, but got '%s'", zone)
+	}
+	return loc, nil
+}
+
+// Tells the time of clock, in loc.
+type zonedClock struct {
+	punch.Clock
+	loc *time.Location
+}
+
+func (z zonedClock) Now() time.Time { return z.Clock.Now().In(z.loc) }
+
+func main() {
+	os.Exit(run(punch.SystemClock, os.Args))
+}
+
+// Runs the `punch` commandline, `args` (as os.Args would be), returning the
+// process's exit code. All commands consider `clock` the current time.
+//
 // TODO(zacsh) allow for global flag to indicate punch in/out renderings should
 // be in their original unix timestamp (rather than time.Unix().String()
 // rendering)
-func main() {
-	if len(os.Args) > 1 {
-		maybeHandleHelpCli()
+func run(clock punch.Clock, args []string) int {
+	flags, args, e := parseGlobalFlags(args)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "usage error (see -h): %s\n", e)
+		return 1
+	}
+	format := flags.format
+	if flags.location != nil {
+		// As if $TZ were ZONE: stamps are read and rendered in it, and days (eg:
+		// of a timesheet) start at its midnight
+		time.Local = flags.location
+		clock = zonedClock{clock, flags.location}
+	}
+
+	if flags.isDebug || len(os.Getenv(debugEnvVar)) > 0 {
+		punch.Trace = os.Stderr
+	}
+
+	commandLine = strings.Join(args[1:], " ")
+
+	if len(args) > 1 && maybeHandleHelpCli(args) {
+		return 0
 	}
 
-	isCmdDefault := len(os.Args) < 2
+	isCmdDefault := len(args) < 2
 
 	// TODO(zacsh) nit: consider deleting `dbInfo` codepaths
 	dbPath, dbInfo, e := isDbReadableNonemptyFile()
 	if e != nil {
 		if isCmdDefault && len(dbPath) > 0 {
-			exitCode := 0
 			if e := subCmdCreate(dbPath); e != nil {
 				fmt.Fprintf(os.Stderr, "need sqlite3 db: %s\n", e)
-				exitCode = 1
+				return 1
 			}
-			os.Exit(exitCode)
+			return 0
 		} else {
 			fmt.Fprintf(os.Stderr, "Error checking database (see -h): %s\n", e)
-			os.Exit(1)
+			return 1
 		}
 	}
 
+	if format != formatText && !isCmdDefault && args[1] != "q" && args[1] != "query" {
+		fmt.Fprintf(os.Stderr,
+			"usage error (see -h): --format only applies to queries, not '%s'\n", args[1])
+		return 1
+	}
+
 	if isCmdDefault {
-		if e := subCmdQuery(dbInfo, dbPath, []string{queryDefaultCmd}); e != nil {
+		if e := subCmdQuery(clock, format, dbInfo, dbPath, []string{queryDefaultCmd}); e != nil {
 			fmt.Fprintf(os.Stderr, "status check: %s\n", e)
-			os.Exit(1)
+			return 1
 		}
-		return
+		return 0
 	}
 
-	switch os.Args[1] {
+	switch args[1] {
 	case "p", "punch":
-		if e := subCmdPunch(dbPath, os.Args[2:]); e != nil {
+		if e := subCmdPunch(clock, dbPath, args[2:]); e != nil {
 			fmt.Fprintf(os.Stderr, "punch failed: %s\n", e)
-			os.Exit(1)
+			return 1
+		}
+	case "sw", "switch":
+		if e := subCmdSwitch(clock, dbPath, args[2:]); e != nil {
+			fmt.Fprintf(os.Stderr, "switch failed: %s\n", e)
+			return 1
+		}
+	case "pause", "resume":
+		if e := subCmdPause(clock, dbPath, args[2:], args[1] == "resume"); e != nil {
+			fmt.Fprintf(os.Stderr, "%s failed: %s\n", args[1], e)
+			return 1
 		}
 	case "bill":
-		if e := subCmdBill(dbPath, os.Args[2:]); e != nil {
+		if e := subCmdBill(clock, dbPath, args[2:]); e != nil {
 			fmt.Fprintf(os.Stderr, "bill failed: %s\n", e)
-			os.Exit(1)
+			return 1
 		}
 	case "q", "query":
-		if e := subCmdQuery(dbInfo, dbPath, os.Args[2:]); e != nil {
+		if e := subCmdQuery(clock, format, dbInfo, dbPath, args[2:]); e != nil {
 			fmt.Fprintf(os.Stderr, "query failed: %s\n", e)
-			os.Exit(1)
+			return 1
 		}
 	case "d", "delete":
-		if e := subCmdDelete(dbPath, os.Args[2:]); e != nil {
+		if e := subCmdDelete(clock, dbPath, args[2:]); e != nil {
 			fmt.Fprintf(os.Stderr, "delete failed: %s\n", e)
-			os.Exit(1)
+			return 1
 		}
 	case "a", "amend":
-		if e := subCmdAmend(dbPath, os.Args[2:]); e != nil {
+		if e := subCmdAmend(clock, dbPath, args[2:]); e != nil {
 			fmt.Fprintf(os.Stderr, "amend failed: %s\n", e)
-			os.Exit(1)
+			return 1
 		}
 	case "s", "seek":
-		if e := subCmdSeek(dbPath, os.Args[2:]); e != nil {
+		if e := subCmdSeek(clock, dbPath, args[2:]); e != nil {
 			fmt.Fprintf(os.Stderr, "seek failed: %s\n", e)
-			os.Exit(1)
+			return 1
+		}
+	case "invoice":
+		if e := subCmdInvoice(clock, dbPath, args[2:]); e != nil {
+			fmt.Fprintf(os.Stderr, "invoice failed: %s\n", e)
+			return 1
+		}
+	case "rate":
+		if e := subCmdRate(clock, dbPath, args[2:]); e != nil {
+			fmt.Fprintf(os.Stderr, "rate failed: %s\n", e)
+			return 1
+		}
+	case "undo", "redo":
+		if e := subCmdUndo(clock, dbPath, args[2:], args[1] == "redo"); e != nil {
+			fmt.Fprintf(os.Stderr, "%s failed: %s\n", args[1], e)
+			return 1
+		}
+	case "log":
+		if e := subCmdLog(clock, dbPath, args[2:]); e != nil {
+			fmt.Fprintf(os.Stderr, "log failed: %s\n", e)
+			return 1
+		}
+	case "fsck":
+		if e := subCmdFsck(clock, dbPath, args[2:]); e != nil {
+			fmt.Fprintf(os.Stderr, "fsck failed: %s\n", e)
+			return 1
+		}
+	case "migrate":
+		if e := subCmdMigrate(clock, dbPath, args[2:]); e != nil {
+			fmt.Fprintf(os.Stderr, "migrate failed: %s\n", e)
+			return 1
 		}
 	default:
 		fmt.Fprintf(os.Stderr,
-			"valid sub-command required (ie: not '%s'); try --h for usage\n", os.Args[1])
-		os.Exit(1)
+			"valid sub-command required (ie: not '%s'); try --h for usage\n", args[1])
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/jzacsh/punch"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool(
+	"update", false, "rewrite testdata/*.golden with actual e2e output")
+
+// Environment variable under which a re-executed test binary (see runPunch)
+// finds the unix timestamp its mock clock should report as "now".
+const testClockEnvVar string = "PUNCH_TEST_NOW"
+
+// Environment variable telling a re-executed test binary to treat its stdin as
+// a terminal, so it can be prompted for confirmations.
+const testTerminalEnvVar string = "PUNCH_TEST_TERMINAL"
+
+const sampleCardPath string = "../../testdata/sample.card"
+
+// Config every e2e run reads, rather than the developer's own.
+const testConfigPath string = "testdata/config.json"
+
+// Just after the last punch in sampleCardPath, when nothing is on the clock.
+var sampleNow time.Time = time.Unix(1491970000, 0 /*nanoseconds*/)
+
+// Doubles as the `punch` binary itself when re-executed by runPunch, so tests
+// can observe exactly what a user would: stdout, stderr and exit code.
+func TestMain(m *testing.M) {
+	if stamp := os.Getenv(testClockEnvVar); len(stamp) > 0 {
+		now, e := strconv.ParseInt(stamp, 10, 64)
+		if e != nil {
+			fmt.Fprintf(os.Stderr, "test harness: bad $%s: %s\n", testClockEnvVar, e)
+			os.Exit(2)
+		}
+		if len(os.Getenv(testTerminalEnvVar)) > 0 {
+			isStdinTerminal = func() bool { return true }
+		}
+		os.Exit(run(punch.FixedClock(time.Unix(now, 0 /*nanoseconds*/)), os.Args))
+	}
+	os.Exit(m.Run())
+}
+
+type punchResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Runs `punch args...` against the card at dbPath, as if the time were `now`.
+// Output is rendered in UTC.
+func runPunch(t *testing.T, dbPath string, now time.Time, args ...string) *punchResult {
+	return runPunchWithInput(t, dbPath, now, nil /*stdin*/, args...)
+}
+
+// runPunch, with stdin (if non-nil) connected to the process.
+func runPunchWithInput(
+	t *testing.T, dbPath string, now time.Time, stdin io.Reader, args ...string) *punchResult {
+	return runPunchWithConfig(t, testConfigPath, dbPath, now, stdin, args...)
+}
+
+// runPunchWithInput, reading the config at configPath.
+func runPunchWithConfig(
+	t *testing.T, configPath string, dbPath string, now time.Time, stdin io.Reader,
+	args ...string) *punchResult {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(),
+		"TZ=UTC",
+		"PAGER=",
+		fmt.Sprintf("%s=%s", configEnvVar, configPath),
+		fmt.Sprintf("%s=%s", dbEnvVar, dbPath),
+		fmt.Sprintf("%s=%d", testClockEnvVar, now.Unix()))
+	if stdin != nil {
+		// Stands in for a user at a terminal
+		cmd.Env = append(cmd.Env, testTerminalEnvVar+"=1")
+	}
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	result := &punchResult{}
+	if e := cmd.Run(); e != nil {
+		exitErr, ok := e.(*exec.ExitError)
+		if !ok {
+			t.Fatalf("running punch %q: %s", args, e)
+		}
+		result.ExitCode = exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	return result
+}
+
+// Asserts `r` is exactly the expected output and exit code.
+func (r *punchResult) expect(t *testing.T, stdout, stderr string, exitCode int) {
+	t.Helper()
+	if r.Stdout != stdout {
+		t.Errorf("stdout: got:\n%s\nexpected:\n%s", r.Stdout, stdout)
+	}
+	if r.Stderr != stderr {
+		t.Errorf("stderr: got:\n%s\nexpected:\n%s", r.Stderr, stderr)
+	}
+	if r.ExitCode != exitCode {
+		t.Errorf("exit code: got %d, expected %d", r.ExitCode, exitCode)
+	}
+}
+
+// Builds a temporary punch card, as a copy of the card at `src`, or empty if src
+// is empty. Call the returned func when done with the card.
+func newTestCard(t *testing.T, src string) (string, func()) {
+	dir, e := ioutil.TempDir("", "punch-test")
+	if e != nil {
+		t.Fatalf("creating temp dir: %s", e)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	dbPath := filepath.Join(dir, "punchcard")
+
+	if len(src) == 0 {
+		card, e := punch.Create(dbPath)
+		if e != nil {
+			cleanup()
+			t.Fatalf("creating empty card: %s", e)
+		}
+		card.Close()
+		return dbPath, cleanup
+	}
+
+	contents, e := ioutil.ReadFile(src)
+	if e != nil {
+		cleanup()
+		t.Fatalf("reading card to copy: %s", e)
+	}
+	if e := ioutil.WriteFile(dbPath, contents, 0600); e != nil {
+		cleanup()
+		t.Fatalf("copying card: %s", e)
+	}
+	return dbPath, cleanup
+}
+
+// Inserts `cards` into the card at dbPath as-is, bypassing any of punch's own
+// validation, eg: to build malformed fixtures.
+func insertCards(t *testing.T, dbPath string, cards ...punch.CardSchema) {
+	db, e := sql.Open("sqlite3", dbPath)
+	if e != nil {
+		t.Fatalf("opening fixture: %s", e)
+	}
+	defer db.Close()
+
+	for _, c := range cards {
+		raw := c.ToSQL()
+		if _, e := db.Exec(`
+			INSERT INTO punchcard(punch, status, project, note)
+			VALUES (?, ?, ?, ?)
+		`, raw.Punch, raw.Status, raw.Project, raw.Note); e != nil {
+			t.Fatalf("inserting fixture card %v: %s", c, e)
+		}
+	}
+}
+
+// Runs query against the card at dbPath as-is, eg: to write records punch's own
+// validation (or insertCards' trimming of notes) wouldn't allow.
+func execFixture(t *testing.T, dbPath string, query string, args ...interface{}) {
+	db, e := sql.Open("sqlite3", dbPath)
+	if e != nil {
+		t.Fatalf("opening fixture: %s", e)
+	}
+	defer db.Close()
+	if _, e := db.Exec(query, args...); e != nil {
+		t.Fatalf("writing fixture %q: %s", query, e)
+	}
+}
+
+func emptyFixture(t *testing.T) (string, func()) { return newTestCard(t, "" /*src*/) }
+
+// The sample card exactly as checked in, at the schema it was created with.
+func legacyFixture(t *testing.T) (string, func()) { return newTestCard(t, sampleCardPath) }
+
+// The sample card, migrated to the latest schema.
+func sampleFixture(t *testing.T) (string, func()) {
+	dbPath, cleanup := legacyFixture(t)
+	card, e := punch.Open(dbPath)
+	if e != nil {
+		cleanup()
+		t.Fatalf("upgrading sample card: %s", e)
+	}
+	card.Close()
+	return dbPath, cleanup
+}
+
+// sampleFixture, but punched into golangpunch
+func openFixture(t *testing.T) (string, func()) {
+	dbPath, cleanup := sampleFixture(t)
+	insertCards(t, dbPath, punch.CardSchema{
+		Punch:   sampleNow.Add(-time.Minute * 45),
+		IsStart: true,
+		Project: "golangpunch",
+		Note:    "night owl",
+	})
+	return dbPath, cleanup
+}
+
+// sampleFixture, but punched into both golangpunch and spaceship
+func twoOpenFixture(t *testing.T) (string, func()) {
+	dbPath, cleanup := openFixture(t)
+	insertCards(t, dbPath, punch.CardSchema{
+		Punch:   sampleNow.Add(-time.Minute * 30),
+		IsStart: true,
+		Project: "spaceship",
+	})
+	return dbPath, cleanup
+}
+
+// sampleFixture, plus golangpunch and spaceship each having punched in (90m
+// ago), out (1h ago), then back in (30m ago) at the same seconds as each other
+func sharedStampFixture(t *testing.T) (string, func()) {
+	dbPath, cleanup := sampleFixture(t)
+	for _, client := range []string{"golangpunch", "spaceship"} {
+		insertCards(t, dbPath,
+			punch.CardSchema{Punch: sampleNow.Add(-time.Minute * 90), IsStart: true, Project: client},
+			punch.CardSchema{Punch: sampleNow.Add(-time.Hour), IsStart: false, Project: client},
+			punch.CardSchema{Punch: sampleNow.Add(-time.Minute * 30), IsStart: true, Project: client})
+	}
+	return dbPath, cleanup
+}
+
+// sampleFixture, plus a "strays" client whose history starts with a punch-out
+// and later has two punch-outs in a row
+func strayFixture(t *testing.T) (string, func()) {
+	dbPath, cleanup := sampleFixture(t)
+	insertCards(t, dbPath,
+		punch.CardSchema{
+			Punch: sampleNow.Add(-time.Hour * 5), Project: "strays", Note: "orphan"},
+		punch.CardSchema{
+			Punch: sampleNow.Add(-time.Hour * 4), IsStart: true, Project: "strays"},
+		punch.CardSchema{
+			Punch: sampleNow.Add(-time.Hour * 3), Project: "strays"},
+		punch.CardSchema{
+			Punch: sampleNow.Add(-time.Hour * 2), Project: "strays", Note: "again"})
+	return dbPath, cleanup
+}
+
+// emptyFixture, plus an "acme" session spanning the night New York's clocks
+// sprang forward: 2017-03-11 22:00 EST to 2017-03-13 02:00 EDT
+func dstFixture(t *testing.T) (string, func()) {
+	dbPath, cleanup := emptyFixture(t)
+	insertCards(t, dbPath,
+		punch.CardSchema{
+			Punch: time.Date(2017, 3, 12, 3, 0, 0, 0, time.UTC), IsStart: true, Project: "acme"},
+		punch.CardSchema{
+			Punch: time.Date(2017, 3, 13, 6, 0, 0, 0, time.UTC), Project: "acme"})
+	return dbPath, cleanup
+}
+
+// One invocation of punch in an e2e transcript.
+type e2eStep struct {
+	now   time.Time
+	args  []string
+	input string // to stdin, if non-empty
+}
+
+type e2eCase struct {
+	name    string // golden file of the case's transcript is testdata/[name].golden
+	fixture func(t *testing.T) (string, func())
+	steps   []e2eStep
+	config  string // path read as $PUNCH_CONFIG, if not testConfigPath
+}
+
+// Shorthand for an e2eStep run at sampleNow.
+func step(args ...string) e2eStep { return e2eStep{now: sampleNow, args: args} }
+
+// step, answering any prompts with input.
+func stepWithInput(input string, args ...string) e2eStep {
+	return e2eStep{now: sampleNow, args: args, input: input}
+}
+
+// Renders `r`, the result of step `s`, for a golden transcript.
+func (r *punchResult) transcript(s e2eStep) string {
+	var input string
+	if len(s.input) > 0 {
+		input = fmt.Sprintf("--- stdin\n%s", s.input)
+	}
+	return fmt.Sprintf("$ %s\n%s--- stdout\n%s--- stderr\n%s--- exit %d\n\n",
+		strings.Join(append([]string{"punch"}, s.args...), " "),
+		input, r.Stdout, r.Stderr, r.ExitCode)
+}
+
+// Runs every step of each case against a fresh copy of its fixture, asserting
+// the resulting transcript matches the case's golden file. Pass -update to
+// rewrite golden files instead.
+func runE2E(t *testing.T, cases []e2eCase) {
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dbPath, cleanup := tt.fixture(t)
+			defer cleanup()
+
+			config := testConfigPath
+			if len(tt.config) > 0 {
+				config = tt.config
+			}
+
+			var transcript string
+			for _, s := range tt.steps {
+				var stdin io.Reader
+				if len(s.input) > 0 {
+					stdin = strings.NewReader(s.input)
+				}
+				transcript += runPunchWithConfig(t, config, dbPath, s.now, stdin, s.args...).transcript(s)
+			}
+			transcript = strings.Replace(transcript, dbPath, "$PUNCH_CARD", -1)
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *updateGolden {
+				if e := ioutil.WriteFile(golden, []byte(transcript), 0644); e != nil {
+					t.Fatalf("updating golden file: %s", e)
+				}
+				return
+			}
+
+			expected, e := ioutil.ReadFile(golden)
+			if e != nil {
+				t.Fatalf("reading golden file (see -update): %s", e)
+			}
+			if transcript != string(expected) {
+				t.Errorf("transcript differs from %s; got:\n%s", golden, transcript)
+			}
+		})
+	}
+}
+
+func TestRunAtFixedClock(t *testing.T) {
+	dbPath, cleanup := newTestCard(t, "" /*src*/)
+	defer cleanup()
+
+	start := time.Date(2017, 4, 11, 9, 0, 0, 0, time.UTC)
+
+	runPunch(t, dbPath, start, "p", "acme", "-n", "kickoff").expect(t, "", "", 0)
+
+	runPunch(t, dbPath, start.Add(time.Minute*90)).
+		expect(t, "acme: 01:30:00 so far (01:30:00 in all, never billed)\n", "", 0)
+
+	runPunch(t, dbPath, start.Add(time.Hour*2), "p").expect(t, "", "", 0)
+
+	runPunch(t, dbPath, start.Add(time.Hour*3)).
+		expect(t, "", "status check: not on the clock\n", 1)
+
+	runPunch(t, dbPath, start.Add(time.Hour*3), "q", "report", "acme").expect(t,
+		`Sessions on 'acme' (in +0000 UTC):
+            02:00:00 from 2017-04-11 09:00:00 to 11:00:00 kickoff
+Summary: Worked 2h0m0s over 1 sessions
+`, "", 0)
+}
+
+func TestDebugTracesSQL(t *testing.T) {
+	dbPath, cleanup := newTestCard(t, "" /*src*/)
+	defer cleanup()
+
+	r := runPunch(t, dbPath, sampleNow, "--debug", "p", "acme")
+	if r.ExitCode != 0 || len(r.Stdout) != 0 {
+		t.Fatalf("punching in: %+v", r)
+	}
+	for _, expected := range []string{
+		"sql: BEGIN IMMEDIATE [] (",
+		"sql: SELECT * FROM punchcard WHERE project IS ? ORDER BY punch DESC LIMIT 1; [\"acme\"] (",
+		"sql: INSERT INTO punchcard(punch, status, project, note) VALUES (?, ?, ?, ?) [1491970000, 1, \"acme\", <nil>] (",
+		"): 1 row(s) affected, last insert id 1\n",
+		"sql: COMMIT [] (",
+	} {
+		if !strings.Contains(r.Stderr, expected) {
+			t.Errorf("expected trace to contain %q, got:\n%s", expected, r.Stderr)
+		}
+	}
+
+	if r := runPunch(t, dbPath, sampleNow, "q", "list"); len(r.Stderr) != 0 {
+		t.Errorf("expected no trace without --debug, got:\n%s", r.Stderr)
+	}
+}
+
+func TestRunUnknownSubCommand(t *testing.T) {
+	dbPath, cleanup := newTestCard(t, sampleCardPath)
+	defer cleanup()
+
+	runPunch(t, dbPath, time.Unix(1491700957, 0), "frobnicate").expect(t,
+		"",
+		"valid sub-command required (ie: not 'frobnicate'); try --h for usage\n",
+		1)
+}
+
+func TestTimezoneE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "timezone_dst",
+			fixture: dstFixture,
+			steps: []e2eStep{
+				step("q", "report", "acme"),
+				step("--tz", "America/New_York", "q", "report", "acme"),
+				step("q", "range", "2017-03-11", "2017-03-14"),
+				step("--tz", "America/New_York", "q", "range", "2017-03-11", "2017-03-14"),
+				step("--tz", "America/New_York", "q", "timesheet", "--week", "2017-03-12"),
+				step("--tz", "America/New_York", "--format", "csv", "q", "range", "2017-03-11"),
+				step("--tz", "Mars/Olympus", "q"),
+				step("q", "--tz"),
+				step("--tz"),
+			},
+		},
+	})
+}
+
+func TestRunOffersCardCreation(t *testing.T) {
+	dir, e := ioutil.TempDir("", "punch-test")
+	if e != nil {
+		t.Fatalf("creating temp dir: %s", e)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := filepath.Join(dir, "punchcard")
+
+	runPunchWithInput(t, dbPath, time.Unix(1491700957, 0), bytes.NewBufferString("y\n")).
+		expect(t, fmt.Sprintf(`$PUNCH_CARD database not yet created
+	%s
+Should one be automatically started now? [y/N] Empty tables successfully created.
+
+  To start keep records try 'punch' and 'query' commands.
+	For reminders of their arguments, see '-h'.
+	For a listing of ALL commands and full docs, see 'help'
+	For a reminder of what one command does, see 'help [cmd]', eg: 'help punch'
+`, dbPath), "", 0)
+
+	if _, e := os.Stat(dbPath); e != nil {
+		t.Errorf("expected card to be created: %s", e)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jzacsh/punch"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const configEnvVar string = "PUNCH_CONFIG"
+
+// Who's on either end of an invoice.
+type contact struct {
+	Name    string   `json:"name"`
+	Email   string   `json:"email"`
+	Phone   string   `json:"phone"`
+	Address []string `json:"address"` // one line per element
+	Note    string   `json:"note"`    // free-form, eg: payment terms or a tax ID
+}
+
+// Settings read from the JSON file at $PUNCH_CONFIG; see helpCmdInvoice for an
+// example.
+type config struct {
+	Sender  contact            `json:"sender"`
+	Clients map[string]contact `json:"clients"` // by CLIENT
+	// Default invoice TEMPLATE; see helpCmdInvoice
+	Template string `json:"template"`
+	// Policy on clients' sessions overlapping, per punch.ParseConcurrency;
+	// empty for punch.ConcurrencyAllow
+	Concurrency string `json:"concurrency"`
+}
+
+// Where the config is read from when $PUNCH_CONFIG isn't set.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if len(dir) == 0 {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, AppName, "config.json")
+}
+
+// Reads the config at $PUNCH_CONFIG, or else defaultConfigPath, which is
+// optional: if it doesn't exist then an empty config is returned.
+func loadConfig() (*config, error) {
+	conf := &config{}
+	if e := readConfig(conf); e != nil {
+		return nil, e
+	}
+	return conf, nil
+}
+
+// Decodes the config, as for loadConfig, into `into`, left as is if there's
+// none.
+func readConfig(into interface{}) error {
+	path := os.Getenv(configEnvVar)
+	isExplicit := len(path) > 0
+	if !isExplicit {
+		path = defaultConfigPath()
+	}
+
+	contents, e := ioutil.ReadFile(path)
+	if e != nil {
+		if os.IsNotExist(e) && !isExplicit {
+			return nil
+		}
+		return fmt.Errorf("reading config: %s", e)
+	}
+	if e := json.Unmarshal(contents, into); e != nil {
+		return fmt.Errorf("parsing config '%s': %s", path, e)
+	}
+	return nil
+}
+
+// Reads just the concurrency policy from the config, which every command
+// needs, but few anything else in it. So any other trouble with the config
+// (eg: a malformed invoice field) is only warned about, taking the policy to be
+// punch.ConcurrencyAllow; only a bad policy fails.
+func loadConcurrency() (punch.Concurrency, error) {
+	var fields map[string]json.RawMessage
+	if e := readConfig(&fields); e != nil {
+		fmt.Fprintf(os.Stderr,
+			"WARNING: %s; taking concurrency policy to be \"%s\"\n", e, punch.ConcurrencyAllow)
+		return punch.ConcurrencyAllow, nil
+	}
+	conf := &config{}
+	if raw, ok := fields["concurrency"]; ok {
+		if e := json.Unmarshal(raw, &conf.Concurrency); e != nil {
+			return punch.ConcurrencyAllow, fmt.Errorf("config: concurrency: %s", e)
+		}
+	}
+	return conf.concurrency()
+}
+
+// The configured concurrency policy.
+func (c *config) concurrency() (punch.Concurrency, error) {
+	if len(c.Concurrency) == 0 {
+		return punch.ConcurrencyAllow, nil
+	}
+	policy, e := punch.ParseConcurrency(c.Concurrency)
+	if e != nil {
+		return policy, fmt.Errorf("config: %s", e)
+	}
+	return policy, nil
+}
+
+// The configured details of client, named after client if none are configured.
+func (c *config) client(client string) contact {
+	details := c.Clients[client]
+	if len(details.Name) == 0 {
+		details.Name = client
+	}
+	return details
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"github.com/jzacsh/punch"
+	"golang.org/x/crypto/ssh/terminal"
+	"os"
+)
+
+// Whether a user is at stdin to answer prompts; a var so tests can pretend.
+var isStdinTerminal = func() bool { return terminal.IsTerminal(int(os.Stdin.Fd())) }
+
+// Whether arg is the flag skipping confirmation of a change.
+func isYesFlag(arg string) bool { return arg == "-y" || arg == "-q" }
+
+// Asks the user to confirm the change just previewed, refusing it if there's
+// nobody at stdin to ask.
+func confirmChange() error {
+	if !isStdinTerminal() {
+		return errors.New(
+			"stdin is not a terminal to confirm changes on; pass -y to make them without asking")
+	}
+	isAccepted, e := askYesNo("Proceed?")
+	if e != nil {
+		return e
+	}
+	if !isAccepted {
+		return errors.New("not confirmed; NO changes written")
+	}
+	return nil
+}
+
+// How to proceed with a change, once it's been previewed.
+type proceed int
+
+const (
+	proceedNever       proceed = iota // a dry-run
+	proceedIfConfirmed                // by the user
+	proceedNow                        // per -y
+)
+
+func proceedFor(isDryRun, isYes bool) proceed {
+	switch {
+	case isDryRun:
+		return proceedNever
+	case isYes:
+		return proceedNow
+	default:
+		return proceedIfConfirmed
+	}
+}
+
+// Runs preview then, as it says to proceed, apply, reporting whether apply
+// succeeded. Applying at once happens within preview's transaction on card.
+// Otherwise the card isn't locked while awaiting the user's confirmation, so
+// apply is refused if the card changed meanwhile.
+func previewThenApply(
+	card *punch.Card, preview func() (proceed, error), apply func() error) (bool, error) {
+	var how proceed
+	var revision string
+	if e := card.Transact(func() error {
+		var e error
+		if how, e = preview(); e != nil {
+			return e
+		}
+		switch how {
+		case proceedNow:
+			return apply()
+		case proceedIfConfirmed:
+			revision, e = card.Revision()
+			return e
+		}
+		return nil
+	}); e != nil || how != proceedIfConfirmed {
+		return e == nil && how == proceedNow, e
+	}
+
+	if e := confirmChange(); e != nil {
+		return false, e
+	}
+
+	e := card.Transact(func() error {
+		latest, e := card.Revision()
+		if e != nil {
+			return e
+		}
+		if latest != revision {
+			return errors.New("card changed while awaiting confirmation; NO changes written")
+		}
+		return apply()
+	})
+	return e == nil, e
+}
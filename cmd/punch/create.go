@@ -1,8 +1,7 @@
 package main
 
 import (
-	"database/sql"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/jzacsh/punch"
 
 	"bufio"
 	"errors"
@@ -11,18 +10,29 @@ import (
 	"strings"
 )
 
+// Shared by every prompt, so input buffered for one isn't lost to the next.
+var stdinReader *bufio.Reader = bufio.NewReader(os.Stdin)
+
+// Prints question, then reads whether the user answered "y"es.
+func askYesNo(question string) (bool, error) {
+	fmt.Printf("%s [y/N] ", question)
+	nextLine, e := stdinReader.ReadString('\n')
+	if e != nil {
+		return false, fmt.Errorf("response parsing: %s", e)
+	}
+	response := strings.TrimSpace(nextLine)
+	return len(response) > 0 && strings.ToLower(string(response[0])) == "y", nil
+}
+
 func ensureUserWantsAutocreation(dbPath string) error {
 	fmt.Printf(
 		"$PUNCH_CARD database not yet created\n\t%s\n", dbPath)
-	fmt.Printf("Should one be automatically started now? [y/N] ")
 
-	reader := bufio.NewReader(os.Stdin)
-	nextLine, e := reader.ReadString('\n')
+	isAccepted, e := askYesNo("Should one be automatically started now?")
 	if e != nil {
-		return fmt.Errorf("response parsing: %s", e)
+		return e
 	}
-	response := strings.TrimSpace(nextLine)
-	if len(response) < 1 || strings.ToLower(string(response[0])) != "y" {
+	if !isAccepted {
 		return errors.New("auto-creation offer not accepted")
 	}
 	return nil
@@ -33,40 +43,11 @@ func subCmdCreate(dbPath string) error {
 		return e
 	}
 
-	db, e := sql.Open("sqlite3", dbPath)
+	card, e := punch.Create(dbPath)
 	if e != nil {
-		return fmt.Errorf("error opening sqlite3: %s", e)
-	}
-
-	stmt, e := db.Prepare(`
-CREATE TABLE punchcard (
-  punch       INTEGER NOT NULL PRIMARY KEY,
-  status      INTEGER NOT NULL,
-  project     TEXT NOT NULL,
-  note        TEXT
-);
-	`)
-	if e != nil {
-		return fmt.Errorf("preparing punchcard table: %s", e)
-	}
-	if _, e := stmt.Exec(); e != nil {
-		return fmt.Errorf("creating punchcard table: %s", e)
-	}
-
-	stmt, e = db.Prepare(`
-CREATE TABLE paychecks (
-  endclusive   INTEGER NOT NULL PRIMARY KEY,
-  startclusive INTEGER NOT NULL,
-  project      TEXT NOT NULL,
-  note         TEXT
-);
-	`)
-	if e != nil {
-		return fmt.Errorf("preparing paychecks table: %s", e)
-	}
-	if _, e := stmt.Exec(); e != nil {
-		return fmt.Errorf("creating paychecks table: %s", e)
+		return e
 	}
+	defer card.Close()
 
 	fmt.Print(`Empty tables successfully created.
 
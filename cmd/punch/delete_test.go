@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDeleteCmd(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args     []string
+		expected DeleteCmd
+	}{
+		{
+			[]string{"bill", "acme", "@1491920098"},
+			DeleteCmd{Target: "bill", Client: "acme", At: time.Unix(1491920098, 0)},
+		},
+		{
+			[]string{"punch", " acme ", "-d", "1491920098"},
+			DeleteCmd{
+				Target:   "punch",
+				Client:   "acme",
+				IsDryRun: true,
+				At:       time.Unix(1491920098, 0),
+			},
+		},
+		{
+			[]string{"punch", "acme", "-1h"},
+			DeleteCmd{Target: "punch", Client: "acme", At: now.Add(-time.Hour)},
+		},
+		{
+			[]string{"bill", "acme", "-y", "-d", "@1491920098"},
+			DeleteCmd{
+				Target:   "bill",
+				Client:   "acme",
+				IsDryRun: true,
+				IsYes:    true,
+				At:       time.Unix(1491920098, 0),
+			},
+		},
+	} {
+		cmd, e := parseDeleteCmd(tt.args, now)
+		if e != nil {
+			t.Errorf("parseDeleteCmd(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if cmd.Target != tt.expected.Target ||
+			cmd.Client != tt.expected.Client ||
+			cmd.IsDryRun != tt.expected.IsDryRun ||
+			cmd.IsYes != tt.expected.IsYes ||
+			!cmd.At.Equal(tt.expected.At) {
+			t.Errorf("parseDeleteCmd(%q): got %s, expected %s", tt.args, cmd, &tt.expected)
+		}
+	}
+}
+
+func TestParseDeleteCmdErrors(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{nil, "expected at least 3 args"},
+		{[]string{"punch", "acme"}, "expected at least 3 args"},
+		{[]string{"session", "acme", "@1491920098"}, "expected either 'bill' or 'punch'"},
+		{[]string{"punch", "ac me", "@1491920098"}, "invalid CLIENT"},
+		{[]string{"punch", "acme", "-x", "@1491920098"}, "unrecognized cmd at '-x @1491920098'"},
+		{[]string{"punch", "acme", "whenever"}, "parsing AT"},
+	} {
+		_, e := parseDeleteCmd(tt.args, now)
+		if e == nil {
+			t.Errorf("parseDeleteCmd(%q): expected error, got none", tt.args)
+			continue
+		}
+		if !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf(
+				"parseDeleteCmd(%q): expected error containing '%s', got: %s",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestDeleteE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "delete_session",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("d", "punch", "spaceship", "-d", "@1491946298"),
+				step("d", "punch", "spaceship", "-y", "@1491946298"),
+				step("q", "report", "spaceship", "@1491915000"),
+				step("d", "punch", "spaceship", "-y", "@1491946298"),
+			},
+		},
+		{
+			name:    "delete_reopens_session",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("d", "punch", "spaceship", "-y", "@1491963757"),
+				step(),
+				step("d", "punch", "spaceship", "-y", "@1491920098"),
+			},
+		},
+		{
+			name:    "delete_open_session",
+			fixture: openFixture,
+			steps: []e2eStep{
+				step("d", "punch", "golangpunch", "-y", "-45m"),
+				step(),
+			},
+		},
+		{
+			name:    "delete_bill",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("d", "bill", "golangpunch", "-d", "@1491632340"),
+				step("d", "bill", "golangpunch", "-y", "@1491632340"),
+				step("q", "bills", "golangpunch"),
+				step("d", "bill", "golangpunch", "-y", "@1491632340"),
+			},
+		},
+		{
+			name:    "delete_confirmation",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("d", "punch", "spaceship", "@1491946298"),
+				stepWithInput("n\n", "d", "punch", "spaceship", "@1491946298"),
+				stepWithInput("y\n", "d", "punch", "spaceship", "@1491946298"),
+				step("q", "report", "spaceship", "@1491915000"),
+			},
+		},
+		{
+			name:    "delete_empty_card",
+			fixture: emptyFixture,
+			steps: []e2eStep{
+				step("d", "punch", "acme", "-y", "@1491963757"),
+				step("d", "bill", "acme", "-y", "@1491963757"),
+			},
+		},
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jzacsh/punch"
+	"os"
+)
+
+// Whether --repair was passed.
+func parseFsckCli(args []string) (bool, error) {
+	isRepair := false
+	for _, arg := range args {
+		if arg != "--repair" {
+			return false, fmt.Errorf("expected only optional --repair flag, but got '%s'", arg)
+		}
+		isRepair = true
+	}
+	return isRepair, nil
+}
+
+func subCmdFsck(clock punch.Clock, dbPath string, args []string) error {
+	isRepair, e := parseFsckCli(args)
+	if e != nil {
+		return e
+	}
+
+	card, e := openCard(clock, dbPath)
+	if e != nil {
+		return fmt.Errorf("punch cards: %s", e)
+	}
+	defer card.Close()
+
+	problems, e := card.Check()
+	if e != nil {
+		return e
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+
+	remaining := 0
+	for _, problem := range problems {
+		fmt.Println(problem)
+		plan := problem.RepairPlan()
+		if len(plan) == 0 {
+			fmt.Println("  must be fixed by hand")
+			remaining++
+			continue
+		}
+		if !isRepair {
+			fmt.Printf("  --repair would %s\n", plan)
+			remaining++
+			continue
+		}
+
+		isAccepted, e := askYesNo(fmt.Sprintf("  Repair, to %s?", plan))
+		if e != nil {
+			return e
+		}
+		if !isAccepted {
+			remaining++
+			continue
+		}
+		if e := card.Repair(problem); e != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", e)
+			remaining++
+			continue
+		}
+		fmt.Println("  Repaired.")
+	}
+
+	if remaining > 0 {
+		return fmt.Errorf("%d of %d problem(s) remain", remaining, len(problems))
+	}
+	fmt.Println("Done.")
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFsckCli(t *testing.T) {
+	if isRepair, e := parseFsckCli(nil); e != nil || isRepair {
+		t.Errorf("got repair=%t (error: %v) without args", isRepair, e)
+	}
+	if isRepair, e := parseFsckCli([]string{"--repair"}); e != nil || !isRepair {
+		t.Errorf("got repair=%t (error: %v) with --repair", isRepair, e)
+	}
+	if _, e := parseFsckCli([]string{"-r"}); e == nil {
+		t.Errorf("expected unknown flag to be an error")
+	}
+}
+
+// strayFixture, plus one of every other problem fsck finds
+func fsckFixture(t *testing.T) (string, func()) {
+	dbPath, cleanup := strayFixture(t)
+	at := func(d time.Duration) int64 { return sampleNow.Add(d).Unix() }
+	execFixture(t, dbPath, `INSERT INTO punchcard VALUES (?, 1, 'bad client', NULL)`,
+		at(-time.Hour*6))
+	execFixture(t, dbPath, `INSERT INTO punchcard VALUES (?, 1, 'golangpunch', '  trailing  ')`,
+		at(-time.Hour*6))
+	execFixture(t, dbPath, `INSERT INTO punchcard VALUES (?, 1, 'golangpunch', NULL)`,
+		at(-time.Hour*5))
+	execFixture(t, dbPath, `INSERT INTO paychecks VALUES (?, ?, 'spaceship', 'oops ')`,
+		at(-time.Hour*7), at(-time.Hour*6))
+	execFixture(t, dbPath, `INSERT INTO paychecks VALUES (?, ?, 'golangpunch', NULL)`,
+		at(-time.Hour*24*4), at(-time.Hour*24*5))
+	return dbPath, cleanup
+}
+
+func TestFsckE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "fsck_sample",
+			fixture: sampleFixture,
+			steps:   []e2eStep{step("fsck")},
+		},
+		{
+			name:    "fsck_repair",
+			fixture: fsckFixture,
+			steps: []e2eStep{
+				step("fsck"),
+				stepWithInput("y\ny\ny\nn\ny\ny\n", "fsck", "--repair"),
+				step("fsck"),
+				stepWithInput("y\n", "fsck", "--repair"),
+				step("fsck", "-r"),
+			},
+		},
+	})
+}
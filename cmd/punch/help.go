@@ -19,16 +19,24 @@ const dbEnvVar string = "PUNCH_CARD"
 
 const queryDefaultCmd string = "status"
 
-const helpCliPattern string = "punch [punch|bill|query|delete|amend|seek] [...]"
+const helpCliPattern string = "punch [--format FORMAT] [--tz ZONE] [--debug] [punch|switch|pause|resume|bill|invoice|rate|query|delete|amend|seek|undo|redo|log|fsck|migrate] [...]"
 const helpDoesWhat string = "Logs & reports time worked on any project"
 
 func isSubCmd(str string) bool {
 	return str == "p" || str == "punch" ||
+		str == "sw" || str == "switch" ||
+		str == "pause" || str == "resume" ||
 		str == "bill" ||
+		str == "invoice" ||
+		str == "rate" ||
 		str == "q" || str == "query" ||
 		str == "d" || str == "delete" ||
 		str == "a" || str == "amend" ||
-		str == "s" || str == "seek"
+		str == "s" || str == "seek" ||
+		str == "undo" || str == "redo" ||
+		str == "log" ||
+		str == "fsck" ||
+		str == "migrate"
 }
 
 // Name, synopsis, description
@@ -50,7 +58,9 @@ func helpCmdPunch(cliOnly bool) string {
 	if !cliOnly {
 		punchHelp = `
     Allows punching in & out of work on a "client"/"project" indiciated by a
-    CLIENT string (an alphanumeric string of characters).
+    CLIENT string (an alphanumeric string of characters). Sub-clients are named
+    within their client, separated by "/", eg: "acme/api/auth" is a sub-client of
+    "acme/api", itself a sub-client of "acme".
 
     There are two uses for this command:
     - 1) starting work: "punching in" to start the clock for some project or client
@@ -62,9 +72,54 @@ func helpCmdPunch(cliOnly bool) string {
     will have no safe assumptions to make, and the command will fail.
 
     Optionally, passing -n NOTE indicates that NOTE string should be stored for
-    future reference for this punchcard entry.`
+    future reference for this punchcard entry.
+
+    Optionally, passing --at TIME punches in or out at TIME rather than now, eg:
+    for when you forgot to punch. TIME cannot be in the future, nor at or before
+    CLIENT's most recent punch. See TIME STAMPS under EXAMPLES for more on TIME.
+
+    Optionally, passing -t TAG (any number of times, before any -n NOTE) tags
+    the session being punched into, or out of, eg: "-t meeting -t billable".
+    TAGs are named as CLIENTs are, though without any "/". Tagged sessions can be picked out by
+    "query report", "query range" and "query dump", which also total time
+    worked per TAG.`
+	}
+	return fmt.Sprintf(
+		"  p|punch    [CLIENT] [--at TIME] [-t TAG]... [-n NOTE]\n%s\n", punchHelp)
+}
+
+func helpCmdSwitch(cliOnly bool) string {
+	var switchHelp string
+	if !cliOnly {
+		switchHelp = `
+    Switches work to CLIENT: punches out of the one client currently punched
+    into, and into CLIENT, at the same instant. If nothing is punched into, it
+    just punches into CLIENT. Fails, punching nothing, if CLIENT is already
+    punched into, or if more than one client is, unless --all is passed to
+    punch out of every one of them.
+
+    --at TIME, -t TAG & -n NOTE are as for "punch", with the TAGs & NOTE going
+    to CLIENT's new session. "undo" reverts the whole switch.`
 	}
-	return fmt.Sprintf("  p|punch    [CLIENT] [-n NOTE]\n%s\n", punchHelp)
+	return fmt.Sprintf(
+		"  sw|switch  [--all] CLIENT [--at TIME] [-t TAG]... [-n NOTE]\n%s\n", switchHelp)
+}
+
+func helpCmdPause(cliOnly bool) string {
+	var pauseHelp string
+	if !cliOnly {
+		pauseHelp = `
+    Takes a break within CLIENT's session, without closing it: "pause" starts
+    the break and "resume" ends it. If CLIENT is not provided, the one client
+    punched into is assumed, as for "punch". --at TIME is as for "punch".
+
+    Breaks are excluded from the session's time, and so from every total,
+    report & bill; reports show each session's gross time (from punch-in to
+    punch-out) where it had breaks. Punching out of a paused session ends its
+    break at the punch-out.`
+	}
+	return fmt.Sprintf(
+		"  pause|resume [CLIENT] [--at TIME]\n%s\n", pauseHelp)
 }
 
 func helpCmdBill(cliOnly bool) string {
@@ -72,10 +127,11 @@ func helpCmdBill(cliOnly bool) string {
 	if !cliOnly {
 		billHelp = `
     Records durations of time over which a payperiod occurs. To see its impact,
-    as a dry run, pass -d. Duration of the pay period is defined to be the
-    inclusive span between the unix time stamps FROM and TO.
+    as a dry run, pass -d; otherwise it's made once confirmed (see CONFIRMING
+    CHANGES under EXAMPLES). Duration of the pay period is defined to be the
+    inclusive span between the time stamps FROM and TO.
 
-    See DATE(1) under EXAMPLES for more on TO/FROM timestamps.
+    See TIME STAMPS under EXAMPLES for more on TO/FROM timestamps.
 
     If a TO stamp is not provided, this implies the duration should end at the
     most recent punch-out. Useful if you've just punched-out to mark the end of
@@ -86,24 +142,97 @@ func helpCmdBill(cliOnly bool) string {
      1) the TO-duration of the previous recorded payperiod
      2) if no previous payperiod is found, the earliest punch stamp under CLIENT
         in the punchcard table.
+    Both look at CLIENT's sub-clients' punches too, and at bills on any client
+    CLIENT is a sub-client of.
+
+    A bill on CLIENT covers its sub-clients, pricing their time at their own
+    rates, or else those of the nearest client they're within. Billing the same
+    time twice, as both CLIENT and one of its sub-clients (or a client it's
+    within), is refused.
 
     Note: data on billing is not in anyway related to the data kept on punches.
     When "query bills" reports time worked over a pay period, it merely
     correlates overlaps in duration indicated by the payperiod with any
-    durations logged through punches.`
+    durations logged through punches, priced per CLIENT's rates (see "rate").`
 	}
 	return fmt.Sprintf(
-		"  bill CLIENT [-d] [-f FROM] [-t TO] [-n NOTE]\n%s\n",
+		"  bill CLIENT [-d] [-y] [-f FROM] [-t TO] [-n NOTE]\n%s\n",
 		billHelp)
 }
 
+func helpCmdInvoice(cliOnly bool) string {
+	var invoiceHelp string
+	if !cliOnly {
+		invoiceHelp = `
+    Prints an invoice for CLIENT's pay period starting at BILL_START (as listed
+    by "query bills"), or for its most recent pay period if BILL_START isn't
+    provided. See TIME STAMPS under EXAMPLES for more on timestamps.
+
+    The invoice lists each session worked within the pay period (its date,
+    times, hours & notes), the total time worked and, if CLIENT has rates (see
+    "rate"), the billable time and amount due.
+
+    TEMPLATE is one of the built-in templates: text (the default), markdown or
+    html; or else the path to a Go template file (see
+    https://golang.org/pkg/text/template), executed as html/template if its
+    name ends in .html or .htm. Templates are executed with: .Client & .Sender
+    (each with .Name, .Email, .Phone, .Address lines & a .Note), .Issued (now),
+    .Bill (with .Startclusive, .Endclusive & .Note), .Sessions (each with
+    .StartAt, .StopAt, .Duration, .NoteStart & .NoteStop), .Total, .Billable,
+    .Amount (nil if unpriced) and .Rates (each with .Hourly). Templates may call:
+    date, clock, datetime & duration on times; hours on durations (eg: "1.50");
+    notes on a session; and cell, to escape a markdown table cell.
+
+    Sender & client details, and a default TEMPLATE, are read from the JSON
+    config file at $PUNCH_CONFIG, eg:
+      {
+        "sender": {"name": "Jane Doe", "address": ["1 Main St", "Springfield"],
+                   "note": "Payable within 30 days"},
+        "clients": {"acme": {"name": "Acme Corp", "email": "ap@acme.example"}},
+        "template": "markdown"
+      }
+    If $PUNCH_CONFIG isn't set, $XDG_CONFIG_HOME/punch/config.json (or
+    ~/.config/punch/config.json) is read, if it exists.`
+	}
+	return fmt.Sprintf(
+		"  invoice CLIENT [BILL_START] [-t TEMPLATE]\n%s\n",
+		invoiceHelp)
+}
+
+func helpCmdRate(cliOnly bool) string {
+	var rateHelp string
+	if !cliOnly {
+		rateHelp = `
+    Sets CLIENT's hourly rate to HOURLY (eg: 85 or 85.50) of CURRENCY (a
+    three-letter code, eg: USD), from the EFFECTIVE stamp onward. To see its
+    impact, as a dry run, pass -d. Rates are never rewritten by later ones:
+    each session is priced at the rate in effect when it started, so pay
+    periods already worked keep their old prices.
+
+    If -f EFFECTIVE is not provided, CLIENT's first rate takes effect from the
+    beginning of time (pricing all its work to date) and later rates take
+    effect now. See TIME STAMPS under EXAMPLES for more on timestamps.
+
+    Optionally, passing -r ROUNDING rounds billable time up to 6, 15 or 30
+    minutes (or 0, the default, for none), where ROUNDING is MINUTES/session to
+    round each session, or MINUTES/period (or just MINUTES) to round the total
+    time worked at this rate over a pay period.
+
+    Amounts are reported by "query bills" and when creating a "bill". All of a
+    pay period's rates must share a currency.`
+	}
+	return fmt.Sprintf(
+		"  rate CLIENT HOURLY CURRENCY [-d] [-f EFFECTIVE] [-r ROUNDING]\n%s\n",
+		rateHelp)
+}
+
 func helpCmdDelete(cliOnly bool) string {
 	var deleteHelp string
 	if !cliOnly {
 		deleteHelp = `
-    Interactively deletes payperiods or punches. The two cases are described
-    below. The -d flag indicates this is a dry-run, and no modifications should
-    be made.
+    Interactively deletes payperiods or punches, once confirmed (see CONFIRMING
+    CHANGES under EXAMPLES). The two cases are described below. The -d flag
+    indicates this is a dry-run, and no modifications should be made.
 
     Case 1: If 'bill' argument is passed, then a CLIENT's payperiod is deleted
     where AT matches the payperiod's FROM timestamp.
@@ -112,12 +241,13 @@ func helpCmdDelete(cliOnly bool) string {
     punches:
      i) If AT matches a punch-out, and there are no punches since AT, then that
         punch-out is deleted (ie: punch session is extended to put you back on
-        the clock).
+        the clock), unless that would overlap another client's session under
+        the "exclusive" concurrency policy.
     ii) If AT matches a punch-in, then the entire session is deleted (from
         punch-in to its corresponding punch-out, if one exists)`
 	}
 	return fmt.Sprintf(
-		"  d|delete bill|punch CLIENT [-d] AT\n%s\n",
+		"  d|delete bill|punch CLIENT [-d] [-y] AT\n%s\n",
 		deleteHelp)
 }
 
@@ -126,21 +256,54 @@ func helpCmdQuery(cliOnly bool) string {
 	if !cliOnly {
 		queryHelp = `
     Allows you to query your work activity, where QUERY is any one of the
-    below. If no QUERY is provided, 'dump' is assumed.
-  - list: Lists all "clients"/"projects" for which records currently exist
-  - dump: pseudo CSV-esque dump of database values, ordered by punch-date,
-    one-punch per-line.
-  - report CLIENT [FROM_STAMP]: Prints a general report on the CLIENT provided.
-    If a unix timestamp FROM_STAMP (in seconds) is specified, it's used as
-    furthest boundary back to fetch records. See DATE(1) under EXAMPLES for more
-    on timestamps.
-  - status: prints running-time on any currently punched-into projects.
+    below. If no QUERY is provided, 'dump' is assumed. See OUTPUT FORMATS for
+    machine-readable renderings of any QUERY.
+  - list [--tree]: Lists all "clients"/"projects" for which records currently
+    exist. Passing --tree indents each sub-client under its client.
+  - overlaps [FROM_STAMP]: lists every span of time, ending after FROM_STAMP if
+    given, over which different clients' sessions ran at once, with the time
+    each one counts more than once in totals (see CONCURRENT SESSIONS under
+    EXAMPLES).
+  - dump [-t TAG]...: pseudo CSV-esque dump of database values, ordered by
+    punch-date, one-punch per-line, with each punch-in's session TAGs.
+  - report CLIENT [-t TAG]... [FROM_STAMP]: Prints a general report on the
+    CLIENT provided. If a timestamp FROM_STAMP is specified, it's used as
+    furthest boundary back to fetch records. See TIME STAMPS under EXAMPLES for
+    more on timestamps. Sessions on CLIENT's sub-clients are reported too, and
+    totaled per sub-client.
+    Passing -t TAG to "dump", "report" or "range" limits it to sessions tagged
+    with every such TAG (see "punch"); any tagged sessions are also totaled
+    per TAG.
+  - status: prints running-time on any currently punched-into projects, along
+    with each project's unbilled time (see "unbilled").
+  - unbilled [CLIENT ...]: prints, for each CLIENT (or every client from "query
+    list", if none are given), the time worked since the end of its most recent
+    payperiod, including any session still in progress.
   - bills [-last] [CLIENT ...]: prints report of payperiod under all CLIENT names.
     If CLIENT is not provided, prints report consecutively for each CLIENT
-    returned by "query list"
+    returned by "query list". Each payperiod lists the number of sessions and
+    time worked within it; sessions straddling either end of the payperiod only
+    count their time within it, and a session still open within the payperiod
+    is warned about, but not counted. Time worked is then rounded & priced
+    per the client's rates (see "rate"), or "n/a" if it has none.
     If -last is provided, prints the scripting-friendly end-timestamp (and its
     human-readable rendering) of the most recent payperiod found for CLIENT.
-    This option requires that exactly one CLIENT be provided.`
+    This option requires that exactly one CLIENT be provided.
+  - range FROM [TO] [-g GROUPING] [-t TAG]...: prints the sessions worked on every client
+    from FROM up to TO (or now), totaled per client and per GROUPING: "day"
+    (the default), "week" (from Monday) or "month". Sessions spanning midnight
+    are divided at day boundaries, in the local timezone (see --tz), each
+    piece counting toward the day, week or month it falls in, though each
+    session is only counted once among a client's sessions. Sessions
+    straddling FROM or TO only count their time within the range, and
+    sessions still open are warned about, but not counted.
+  - timesheet [--week DATE | --month YYYY-MM]: prints a grid of hours worked,
+    with a row per client, and a column per day (Monday to Sunday) of the week
+    containing DATE (see TIME STAMPS under EXAMPLES), or per week of the month
+    YYYY-MM. Defaults to the current week. Sessions spanning midnight are split
+    between the days they span, in the local timezone.
+  - rates [CLIENT ...]: prints each CLIENT's (or every client's) rates, in the
+    order they took effect.`
 	}
 	return fmt.Sprintf("  q|query    [QUERY...]\n%s\n", queryHelp)
 }
@@ -153,10 +316,14 @@ func helpCmdAmend(cliOnly bool) string {
     whose timestamp matches TARGET_STAMP exactly. Note for matching punch is
     replaced with NOTE.
 
-    If NOTE is not provided, the note for said punch is deleted. See DATE(1)
-    under EXAMPLES for more on TO/FROM timestamps.`
+    If NOTE is not provided, the note for said punch is deleted. See TIME STAMPS
+    under EXAMPLES for more on timestamps.
+
+    If more than one client punched at TARGET_STAMP, --client CLIENT picks
+    which of their punches to amend. The change is made once confirmed (see
+    CONFIRMING CHANGES under EXAMPLES).`
 	}
-	return fmt.Sprintf("  a|amend    TARGET_STAMP [NOTE]\n%s\n", amendHelp)
+	return fmt.Sprintf("  a|amend    [-y] [--client CLIENT] TARGET_STAMP [NOTE]\n%s\n", amendHelp)
 }
 
 func helpCmdSeek(cliOnly bool) string {
@@ -169,9 +336,92 @@ func helpCmdSeek(cliOnly bool) string {
     Passing -c indicates SEEK_TO is Closing a still-open session whose punch-in
     is the timestamp STILL_OPEN.
 
-    If -d is passed, "dry-run", no changes will be made.`
+    If more than one client punched at FAULTY_STAMP (or STILL_OPEN), --client
+    CLIENT picks which client's session to seek.
+
+    If -d is passed, "dry-run", no changes will be made; otherwise they're made
+    once confirmed (see CONFIRMING CHANGES under EXAMPLES).`
 	}
-	return fmt.Sprintf("  s|seek  [-d] SEEK_TO  FAULTY_STAMP | -c STILL_OPEN\n%s\n", seekHelp)
+	return fmt.Sprintf(
+		"  s|seek  [-d] [-y] [--client CLIENT] SEEK_TO  FAULTY_STAMP | -c STILL_OPEN\n%s\n",
+		seekHelp)
+}
+
+func helpCmdUndo(cliOnly bool) string {
+	var undoHelp string
+	if !cliOnly {
+		undoHelp = `
+    Every change made to the punch card (by punch, bill, rate, delete, amend,
+    seek or fsck --repair) is recorded in its journal, along with the rows it
+    changed as they were before and after; see "log".
+
+    undo reverts the most recent change not yet undone, and redo re-applies the
+    change most recently undone. Each may be repeated to step further back, or
+    forward, through the journal. Making any new change forgets whatever was
+    left undone, so it can no longer be redone.
+
+    Neither will revert or re-apply a change whose rows have since been changed
+    some other way, eg: by hand. Both restore rows exactly as they were, so
+    bypass the "concurrency" policy (see CONCURRENT SESSIONS under EXAMPLES):
+    eg: undoing a punch-out re-opens its session even if it'd overlap another
+    client's, under "exclusive".`
+	}
+	return fmt.Sprintf("  undo|redo\n%s\n", undoHelp)
+}
+
+func helpCmdLog(cliOnly bool) string {
+	var logHelp string
+	if !cliOnly {
+		logHelp = fmt.Sprintf(`
+    Lists the latest COUNT (default %d, or all if 0) changes in the punch card's
+    journal, newest first: when each was made, by which command, then each row
+    it removed (marked "-") and added (marked "+"). Changes since undone are
+    marked as such.`, logDefaultCount)
+	}
+	return fmt.Sprintf("  log [-n COUNT]\n%s\n", logHelp)
+}
+
+func helpCmdFsck(cliOnly bool) string {
+	var fsckHelp string
+	if !cliOnly {
+		fsckHelp = `
+    Checks the entire punch card for inconsistencies other commands only trip
+    over as they come across them, listing every one found:
+    - invalid CLIENT names
+    - stray punch-outs, with no punch-in before them
+    - double punch-ins, a client punching in twice without punching out between
+    - orphaned tags & breaks, on no session's punch-in
+    - stray breaks, not within their session, and overlapping breaks, starting
+      before the previous one in their session ended
+    - backwards pay periods, whose FROM isn't before their TO
+    - overlapping pay periods, starting before the client's previous one ended,
+      or overlapping one on a client it's within, or on a sub-client
+    - notes with leading or trailing whitespace
+
+    Exits non-zero if any are found. If --repair is passed, each problem that can
+    be repaired automatically is, after asking for confirmation: stray
+    punch-outs are deleted, as is the first of a double punch-in; orphaned tags
+    & breaks are deleted; backwards pay periods have their FROM and TO swapped
+    (or are deleted, if FROM equals TO); notes are trimmed. Invalid clients,
+    stray & overlapping breaks and overlapping pay periods must be fixed by
+    hand, eg: with "delete".`
+	}
+	return fmt.Sprintf("  fsck [--repair]\n%s\n", fsckHelp)
+}
+
+func helpCmdMigrate(cliOnly bool) string {
+	var migrateHelp string
+	if !cliOnly {
+		migrateHelp = `
+    Lists, then applies, any schema migrations the punch card is pending, after
+    first backing up the card alongside itself (as $PUNCH_CARD.vN.bak, where N
+    is its schema version before migrating).
+
+    Every other command applies pending migrations (with the same backup)
+    automatically, so this is only needed to preview them (with -d for a dry
+    run) or to upgrade a card ahead of time.`
+	}
+	return fmt.Sprintf("  migrate [-d]\n%s\n", migrateHelp)
 }
 
 // Subcommands
@@ -185,6 +435,14 @@ func helpSectionCommands() string {
     Otherwise prints all documentation. All of -h, --h, h just print a brief CLI
     pseudo-grammar doc.
 
+%s
+%s
+%s
+%s
+%s
+%s
+%s
+%s
 %s
 %s
 %s
@@ -192,11 +450,65 @@ func helpSectionCommands() string {
 %s
 %s`, queryDefaultCmd,
 		helpCmdPunch(false /*cliOnly*/),
+		helpCmdSwitch(false /*cliOnly*/),
+		helpCmdPause(false /*cliOnly*/),
 		helpCmdBill(false /*cliOnly*/),
+		helpCmdInvoice(false /*cliOnly*/),
+		helpCmdRate(false /*cliOnly*/),
 		helpCmdDelete(false /*cliOnly*/),
 		helpCmdQuery(false /*cliOnly*/),
 		helpCmdAmend(false /*cliOnly*/),
-		helpCmdSeek(false /*cliOnly*/))
+		helpCmdSeek(false /*cliOnly*/),
+		helpCmdUndo(false /*cliOnly*/),
+		helpCmdLog(false /*cliOnly*/),
+		helpCmdFsck(false /*cliOnly*/),
+		helpCmdMigrate(false /*cliOnly*/))
+}
+
+// Machine-readable renderings of queries
+func helpSectionOutput() string {
+	return `OUTPUT FORMATS
+  Passing --format FORMAT before a query (or no command, for the default query)
+  prints its results as stable, machine-readable records instead of prose, where
+  FORMAT is one of:
+   text  the default, human-readable prose; not meant for parsing
+   json  an array of objects, one per line, keyed by the fields below
+   csv   RFC-4180 CSV, led by a header line of the fields below
+   tsv   as csv, but separated by tabs
+
+  Each query prints one kind of record; missing values are null in json, and
+  empty in csv & tsv. Timestamps are RFC-3339, eg: "2017-04-11T21:31:38-04:00".
+  New fields are only ever added after a record's existing ones.
+   list                  project
+   list --tree           project, parent (null for a whole client)
+   overlaps              start, stop, duration_seconds, double_counted_seconds,
+                         projects (comma-separated)
+   dump                  punch, status ("in" or "out"), project, note, tags
+                         (comma-separated, of a punch-in's session)
+   report                project, start, stop (null if still open),
+                         duration_seconds (so far, if still open; less breaks),
+                         note_start, note_stop, tags (comma-separated),
+                         break_seconds
+   status                as report, with unbilled_seconds (as for unbilled)
+                         after note_stop, ahead of tags & break_seconds
+   unbilled              project, since (null if never billed), sessions,
+                         working (true if punched in), duration_seconds
+   bills                 project, startclusive, endclusive, sessions,
+                         duration_seconds, note, billable_seconds (rounded,
+                         per rates), amount_cents & currency (null if no rate)
+   range                 project, grouping, start (of the day, week or month,
+                         in the local timezone), sessions (with any time in
+                         it), duration_seconds (of sessions divided at day
+                         boundaries)
+   timesheet             project, start & end (of the day or week), and
+                         duration_seconds, for every cell of the grid
+   rates                 project, effective, hourly_cents, currency,
+                         round_minutes (0 for none), per_session (false if
+                         per pay period)
+
+  Warnings that prose would print inline (eg: stray punch-outs in a report) are
+  printed to stderr instead. Exit codes are as for text.
+`
 }
 
 // Environment & Examples
@@ -214,37 +526,84 @@ func helpSectionFooter() string {
 
 	return fmt.Sprintf(`ENVIRONMENT
   Work clock is an SQLite3 database file path, which is expected to be in $%s
-  environment variable.
+  environment variable. Settings for "invoice" are read from $%s.
+
+  Passing --debug before any command (or setting $%s) traces every SQL
+  statement run on the card to stderr: with its parameters, its duration, and
+  either the rows it affected and last id it inserted, or the rows it returned.
+
+  Stamps are read & printed in the local timezone, per $TZ, unless --tz ZONE
+  is passed before any command, eg: to report in a client's timezone, as in
+  "punch --tz America/New_York query timesheet". ZONE is an IANA timezone name
+  (or "UTC"). Headers name each zone the stamps below them are printed in, eg:
+  "-0500 EST, -0400 EDT" for stamps spanning a DST change. Days (eg: of
+  "query range" & "query timesheet") start at midnight in that timezone, so may
+  be 23 or 25 hours long; sessions spanning midnight count toward each day.
 
 EXAMPLES
   Common 'punch' command lines:
    $ punch # same as "punch query %s"
-   ch: 99:01 so far
+   ch: 99:01 so far (137:14:00 since last bill)
    $ punch p -n 'phew, finished proving hypothesis'
    $ punch
    Not on the clock.
    $ punch p puzzles -n 'free time to tackle tetris in Brainfuck'
-
-  Unix timestamps can be easily obtained. Passing '+%%s' to GNU's DATE(1)
-  utilizes its excellent parser output to produce valid unix timestamps:
-   $ date    # Tue Apr 11 08:58:26 EDT 2017
-   $ date     --date=yesterday
-   Mon Apr 10 08:58:23 EDT 2017
-   $ date     --date="8pm next Fri"
-   Fri Apr 14 20:00:00 EDT 2017
+   $ punch p --at '-15m' -n 'forgot to punch out a quarter hour ago'
+
+  TIME STAMPS: Every timestamp argument (eg: AT, FROM, TO, TARGET_STAMP) is
+  a time expression read relative to now, in the local timezone. Given it's
+  Tue Apr 11 08:58:26 EDT 2017, the following would all be accepted:
+   @1492214400           # unix timestamp in seconds; '@' optional
+   2017-04-14            # ISO-8601 date (midnight) or date and time, eg:
+   2017-04-14T20:00      #   also "2017-04-14 20:00:00" or with a zone, as in
+                         #   "2017-04-14T20:00:00-04:00"
+   now
+   17:30                 # time of day, today; also "9am", "5:15pm"
+   -15m                  # offset from now; also "-1h30m"
+   2h ago                # also "90 minutes ago"
+   yesterday 17:30       # day & optional time of day, where day is one of:
+   friday 9am            #   today, yesterday, a weekday (the most recent,
+   last friday 9am       #   including today) or "last" a weekday (the most
+                         #   recent, before today)
+  Quote expressions with spaces when they're not the last argument, eg:
+   $ punch bill acme -f 'last friday 9am' -t 'yesterday 17:30'
+
+  Passing '+%%s' to GNU's DATE(1) can still produce unix timestamps for
+  anything not understood above:
    $ date +%%s --date="8pm next Fri"
    1492214400 # perfect unix timestamp in seconds
 
+  CONFIRMING CHANGES: bill, delete, amend & seek print the change they'll
+  make, then ask to proceed. Pass -y (or -q) to skip asking, eg: from scripts;
+  without it, they refuse to make changes when stdin isn't a terminal. If the
+  card is changed by another punch while asking, nothing is written:
+   $ punch d punch acme -y @1492214400
+
+  CONCURRENT SESSIONS: Different clients' sessions may overlap, per the
+  "concurrency" policy set in the config file at $PUNCH_CONFIG (see "invoice"):
+   allow       the default: each session counts its time in full, so totals
+               across clients count overlapping time more than once
+   exclusive   refuses any punch-in, resume or seek later that would overlap
+               another client's session, rather than closing it; "switch"
+               moves from one client to another. Punching out is never
+               refused, as it can only shrink an overlap
+   split       divides time overlapping sessions share evenly between them,
+               in every report, bill & timesheet
+  Sessions don't overlap over their breaks (see "pause"). "query overlaps"
+  lists where sessions overlap, whatever the policy, eg:
+   $ echo '{"concurrency": "split"}' > ~/.config/punch/config.json
+
 BUILD INFORMATION
   %s
-`, dbEnvVar, queryDefaultCmd, buildInfo)
+`, dbEnvVar, configEnvVar, debugEnvVar, queryDefaultCmd, buildInfo)
 }
 
 func helpManual() string {
 	return fmt.Sprintf(
-		"%s\n%s\n%s\n",
+		"%s\n%s\n%s\n%s\n",
 		helpSectionHeader(),
 		helpSectionCommands(),
+		helpSectionOutput(),
 		helpSectionFooter())
 }
 
@@ -283,15 +642,23 @@ func maybePipeToPager(payload string) {
 
 // the tl;dr version of helpManual
 func helpCli() string {
-	return fmt.Sprintf("usage: %s\n  %s%s\n\n%s%s%s%s%sSee --help for more\n",
+	return fmt.Sprintf("usage: %s\n  %s%s\n\n%s%s%s%s%s%s%s%s%s%s%s%s%sSee --help for more\n",
 		helpCliPattern,
 		helpDoesWhat,
 		helpCmdPunch(true /*cliOnly*/),
+		helpCmdSwitch(true /*cliOnly*/),
+		helpCmdPause(true /*cliOnly*/),
 		helpCmdBill(true /*cliOnly*/),
+		helpCmdInvoice(true /*cliOnly*/),
+		helpCmdRate(true /*cliOnly*/),
 		helpCmdDelete(true /*cliOnly*/),
 		helpCmdQuery(true /*cliOnly*/),
 		helpCmdAmend(true /*cliOnly*/),
-		helpCmdSeek(true /*cliOnly*/))
+		helpCmdSeek(true /*cliOnly*/),
+		helpCmdUndo(true /*cliOnly*/),
+		helpCmdLog(true /*cliOnly*/),
+		helpCmdFsck(true /*cliOnly*/),
+		helpCmdMigrate(true /*cliOnly*/))
 }
 
 func subCmdHelp(firstArgChars string, args []string) {
@@ -304,8 +671,16 @@ func subCmdHelp(firstArgChars string, args []string) {
 				switch secondArg {
 				case "p", "punch":
 					helpDoc = helpCmdPunch(false /*cliOnly*/)
+				case "sw", "switch":
+					helpDoc = helpCmdSwitch(false /*cliOnly*/)
+				case "pause", "resume":
+					helpDoc = helpCmdPause(false /*cliOnly*/)
 				case "bill":
 					helpDoc = helpCmdBill(false /*cliOnly*/)
+				case "invoice":
+					helpDoc = helpCmdInvoice(false /*cliOnly*/)
+				case "rate":
+					helpDoc = helpCmdRate(false /*cliOnly*/)
 				case "q", "query":
 					helpDoc = helpCmdQuery(false /*cliOnly*/)
 				case "d", "delete":
@@ -314,6 +689,14 @@ func subCmdHelp(firstArgChars string, args []string) {
 					helpDoc = helpCmdAmend(false /*cliOnly*/)
 				case "s", "seek":
 					helpDoc = helpCmdSeek(false /*cliOnly*/)
+				case "undo", "redo":
+					helpDoc = helpCmdUndo(false /*cliOnly*/)
+				case "log":
+					helpDoc = helpCmdLog(false /*cliOnly*/)
+				case "fsck":
+					helpDoc = helpCmdFsck(false /*cliOnly*/)
+				case "migrate":
+					helpDoc = helpCmdMigrate(false /*cliOnly*/)
 				}
 				helpDoc += "\n  See --help without arguments to see full doc.\n"
 			}
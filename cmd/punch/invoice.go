@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/jzacsh/punch"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Everything an invoice template is executed with. Fields of the embedded
+// report (eg: .Bill, .Sessions, .Billable, .Amount, .Rates) and its .Total are
+// available to templates directly.
+type invoice struct {
+	*punch.BillReport
+	Client contact
+	Sender contact
+	Issued time.Time
+}
+
+// Either of text/template or html/template's Template.
+type invoiceTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// Helpers available to every invoice template.
+var invoiceFuncs texttemplate.FuncMap = texttemplate.FuncMap{
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+	"clock": func(t time.Time) string { return t.Format("15:04") },
+	"datetime": func(t time.Time) string {
+		return t.Format(punch.FormatDateTime)
+	},
+	"duration": punch.DurationToStr,
+	// Decimal hours, eg: "1.50"
+	"hours": func(d time.Duration) string { return fmt.Sprintf("%.2f", d.Hours()) },
+	// Session's notes, eg: "start note; stop note"
+	"notes": func(s *punch.Session) string {
+		var notes []string
+		for _, note := range []string{s.NoteStart, s.NoteStop} {
+			if len(note) > 0 {
+				notes = append(notes, note)
+			}
+		}
+		return strings.Join(notes, "; ")
+	},
+	// Escapes pipes for a markdown table cell
+	"cell": func(s string) string { return strings.Replace(s, "|", `\|`, -1) },
+}
+
+const invoiceTemplateText string = `INVOICE
+Issued: {{date .Issued}}
+{{with .Sender}}
+From: {{.Name}}{{range .Address}}
+      {{.}}{{end}}{{with .Email}}
+      {{.}}{{end}}{{with .Phone}}
+      {{.}}{{end}}
+{{end}}
+To:   {{.Client.Name}}{{range .Client.Address}}
+      {{.}}{{end}}{{with .Client.Email}}
+      {{.}}{{end}}{{with .Client.Phone}}
+      {{.}}{{end}}
+
+For work from {{datetime .Bill.Startclusive}} to {{datetime .Bill.Endclusive}}{{with .Bill.Note}}: {{.}}{{end}}
+
+Date        From   To     Hours  Notes
+{{range .Sessions}}{{date .StartAt}}  {{clock .StartAt}}  {{clock .StopAt}}  {{printf "%5s" (hours .Duration)}}  {{notes .}}
+{{end}}
+Total worked: {{hours .Total}} hours
+{{if .Amount}}Billable:     {{hours .Billable}} hours, at{{range .Rates}} {{.Hourly}}/hour{{end}}
+Amount due:   {{.Amount}}
+{{end}}{{with .Client.Note}}
+{{.}}
+{{end}}{{with .Sender.Note}}
+{{.}}
+{{end}}`
+
+const invoiceTemplateMarkdown string = `# Invoice: {{.Client.Name}}
+
+Issued {{date .Issued}}, for work from {{datetime .Bill.Startclusive}} to {{datetime .Bill.Endclusive}}{{with .Bill.Note}}: {{.}}{{end}}
+{{with .Sender}}
+**From:** {{.Name}}{{range .Address}}\
+{{.}}{{end}}{{with .Email}}\
+{{.}}{{end}}{{with .Phone}}\
+{{.}}{{end}}
+{{end}}
+**To:** {{.Client.Name}}{{range .Client.Address}}\
+{{.}}{{end}}{{with .Client.Email}}\
+{{.}}{{end}}{{with .Client.Phone}}\
+{{.}}{{end}}
+
+| Date | From | To | Hours | Notes |
+|------|------|----|------:|-------|
+{{range .Sessions}}| {{date .StartAt}} | {{clock .StartAt}} | {{clock .StopAt}} | {{hours .Duration}} | {{cell (notes .)}} |
+{{end}}
+**Total worked:** {{hours .Total}} hours
+{{if .Amount}}
+**Billable:** {{hours .Billable}} hours, at{{range .Rates}} {{.Hourly}}/hour{{end}}
+
+**Amount due:** {{.Amount}}
+{{end}}{{with .Client.Note}}
+{{.}}
+{{end}}{{with .Sender.Note}}
+{{.}}
+{{end}}`
+
+const invoiceTemplateHTML string = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice: {{.Client.Name}}, {{date .Bill.Endclusive}}</title>
+</head>
+<body>
+<h1>Invoice: {{.Client.Name}}</h1>
+<p>Issued {{date .Issued}}, for work from {{datetime .Bill.Startclusive}} to {{datetime .Bill.Endclusive}}{{with .Bill.Note}}: {{.}}{{end}}</p>
+{{with .Sender}}<address>
+<strong>From:</strong> {{.Name}}{{range .Address}}<br>
+{{.}}{{end}}{{with .Email}}<br>
+<a href="mailto:{{.}}">{{.}}</a>{{end}}{{with .Phone}}<br>
+{{.}}{{end}}
+</address>
+{{end}}<address>
+<strong>To:</strong> {{.Client.Name}}{{range .Client.Address}}<br>
+{{.}}{{end}}{{with .Client.Email}}<br>
+<a href="mailto:{{.}}">{{.}}</a>{{end}}{{with .Client.Phone}}<br>
+{{.}}{{end}}
+</address>
+<table>
+<tr><th>Date</th><th>From</th><th>To</th><th>Hours</th><th>Notes</th></tr>
+{{range .Sessions}}<tr><td>{{date .StartAt}}</td><td>{{clock .StartAt}}</td><td>{{clock .StopAt}}</td><td>{{hours .Duration}}</td><td>{{notes .}}</td></tr>
+{{end}}</table>
+<p><strong>Total worked:</strong> {{hours .Total}} hours</p>
+{{if .Amount}}<p><strong>Billable:</strong> {{hours .Billable}} hours, at{{range .Rates}} {{.Hourly}}/hour{{end}}</p>
+<p><strong>Amount due:</strong> {{.Amount}}</p>
+{{end}}{{with .Client.Note}}<p>{{.}}</p>
+{{end}}{{with .Sender.Note}}<p>{{.}}</p>
+{{end}}</body>
+</html>
+`
+
+// Parses TEMPLATE, either the name of a built-in template or the path to a
+// template file; files ending in .html or .htm are parsed as html/template.
+func parseInvoiceTemplate(name string) (invoiceTemplate, error) {
+	isHTML := false
+	var source string
+	switch name {
+	case "text", "txt":
+		source = invoiceTemplateText
+	case "markdown", "md":
+		source = invoiceTemplateMarkdown
+	case "html":
+		source, isHTML = invoiceTemplateHTML, true
+	default:
+		contents, e := ioutil.ReadFile(name)
+		if e != nil {
+			return nil, fmt.Errorf("reading TEMPLATE: %s", e)
+		}
+		source = string(contents)
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".html", ".htm":
+			isHTML = true
+		}
+	}
+
+	if isHTML {
+		t, e := htmltemplate.New(name).
+			Funcs(htmltemplate.FuncMap(invoiceFuncs)).
+			Parse(source)
+		if e != nil {
+			return nil, fmt.Errorf("parsing TEMPLATE: %s", e)
+		}
+		return t, nil
+	}
+	t, e := texttemplate.New(name).Funcs(invoiceFuncs).Parse(source)
+	if e != nil {
+		return nil, fmt.Errorf("parsing TEMPLATE: %s", e)
+	}
+	return t, nil
+}
+
+// CLIENT, BILL_START (zero if not passed), TEMPLATE (empty if not passed), error
+func parseInvoiceCli(args []string, now time.Time) (string, time.Time, string, error) {
+	var start time.Time
+	var template string
+	if len(args) < 1 {
+		return "", start, template, errors.New("CLIENT is required")
+	}
+
+	client := strings.TrimSpace(args[0])
+	if !punch.IsValidClient(client) {
+		return client, start, template, fmt.Errorf("invalid CLIENT: '%s'", client)
+	}
+
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "-t":
+			if i+1 >= len(args) {
+				return client, start, template, errors.New("-t passed, but no TEMPLATE found")
+			}
+			template = args[i+1]
+			i++ // skip TEMPLATE
+		default:
+			if !start.IsZero() {
+				return client, start, template, fmt.Errorf(
+					"unrecognized commandline at '%s'", args[i:])
+			}
+			var e error
+			if start, e = parseStampCommand(args[i], now); e != nil {
+				return client, start, template, fmt.Errorf(
+					"bad BILL_START timestamp, '%s': %s", args[i], e)
+			}
+		}
+	}
+	return client, start, template, nil
+}
+
+func subCmdInvoice(clock punch.Clock, dbPath string, args []string) error {
+	client, start, templateName, e := parseInvoiceCli(args, clock.Now())
+	if e != nil {
+		return fmt.Errorf("parse args: %s", e)
+	}
+
+	conf, e := loadConfig()
+	if e != nil {
+		return e
+	}
+	if len(templateName) == 0 {
+		templateName = conf.Template
+	}
+	if len(templateName) == 0 {
+		templateName = "text"
+	}
+	template, e := parseInvoiceTemplate(templateName)
+	if e != nil {
+		return e
+	}
+
+	card, e := openCard(clock, dbPath)
+	if e != nil {
+		return fmt.Errorf("invoice sql: %s", e)
+	}
+	defer card.Close()
+
+	var bill *punch.BillSchema
+	if start.IsZero() {
+		if bill, e = card.LastBill(client); e != nil {
+			return e
+		}
+		if bill == nil {
+			return fmt.Errorf("no '%s' pay periods to invoice; see \"bill\"", client)
+		}
+	} else if bill, e = card.FindBill(client, start); e != nil {
+		return e
+	}
+
+	reports, e := card.ReportBills([]*punch.BillSchema{bill})
+	if e != nil {
+		return e
+	}
+	report := reports[0]
+	if report.Open != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", openInBillWarning(report))
+	}
+	if report.Amount != nil && report.Unpriced > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", unpricedInBillWarning(report))
+	}
+
+	// Renders fully before printing, so a failing template prints nothing
+	var out bytes.Buffer
+	if e := template.Execute(&out, &invoice{
+		BillReport: report,
+		Client:     conf.client(client),
+		Sender:     conf.Sender,
+		Issued:     clock.Now(),
+	}); e != nil {
+		return fmt.Errorf("rendering TEMPLATE: %s", e)
+	}
+	_, e = out.WriteTo(os.Stdout)
+	return e
+}
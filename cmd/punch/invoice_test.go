@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInvoiceCli(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args     []string
+		start    time.Time
+		template string
+	}{
+		{[]string{"acme"}, time.Time{}, ""},
+		{[]string{" acme ", "@1491960000"}, time.Unix(1491960000, 0), ""},
+		{[]string{"acme", "-t", "html"}, time.Time{}, "html"},
+		{[]string{"acme", "-t", "my.tmpl", "-1h"}, now.Add(-time.Hour), "my.tmpl"},
+	} {
+		client, start, template, e := parseInvoiceCli(tt.args, now)
+		if e != nil {
+			t.Errorf("parseInvoiceCli(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if client != "acme" || !start.Equal(tt.start) || template != tt.template {
+			t.Errorf("parseInvoiceCli(%q): got ('%s', %s, '%s')",
+				tt.args, client, start, template)
+		}
+	}
+
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{nil, "CLIENT is required"},
+		{[]string{"bad client"}, "invalid CLIENT"},
+		{[]string{"acme", "-t"}, "no TEMPLATE found"},
+		{[]string{"acme", "whenever"}, "bad BILL_START timestamp"},
+		{[]string{"acme", "-1h", "-2h"}, "unrecognized commandline"},
+	} {
+		_, _, _, e := parseInvoiceCli(tt.args, now)
+		if e == nil || !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf("parseInvoiceCli(%q): expected error containing '%s', got: %v",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, e := ioutil.TempDir("", "punch-config-test")
+	if e != nil {
+		t.Fatalf("creating temp dir: %s", e)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv(configEnvVar, os.Getenv(configEnvVar))
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+
+	// Default config is optional
+	os.Setenv(configEnvVar, "")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	conf, e := loadConfig()
+	if e != nil {
+		t.Fatalf("loading missing default config: %s", e)
+	}
+	if acme := conf.client("acme"); acme.Name != "acme" {
+		t.Errorf("expected unconfigured client to be named for itself, got '%s'", acme.Name)
+	}
+
+	// ...but not an explicit one
+	missing := filepath.Join(dir, "missing.json")
+	os.Setenv(configEnvVar, missing)
+	if _, e := loadConfig(); e == nil {
+		t.Errorf("expected missing $%s to be an error", configEnvVar)
+	}
+
+	os.Setenv(configEnvVar, testConfigPath)
+	if conf, e = loadConfig(); e != nil {
+		t.Fatalf("loading %s: %s", testConfigPath, e)
+	}
+	if conf.Sender.Name != "Jane Doe" || conf.client("spaceship").Name != "Spaceship & Co." {
+		t.Errorf("got sender '%s' and client '%s'",
+			conf.Sender.Name, conf.client("spaceship").Name)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if e := ioutil.WriteFile(bad, []byte(`{"sender": "me"}`), 0600); e != nil {
+		t.Fatalf("writing bad config: %s", e)
+	}
+	os.Setenv(configEnvVar, bad)
+	if _, e := loadConfig(); e == nil || !strings.Contains(e.Error(), "parsing config") {
+		t.Errorf("expected malformed config to be an error, got: %v", e)
+	}
+}
+
+func TestInvoiceE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "invoice_templates",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("invoice", "spaceship"),
+				step("rate", "spaceship", "80", "USD", "-r", "15/session"),
+				step("invoice", "spaceship", "-t", "markdown"),
+				step("invoice", "spaceship", "-t", "html"),
+				step("invoice", "golangpunch", "2017-04-08 06:19", "-t", "testdata/invoice.tmpl"),
+				step("invoice", "spaceship", "-t", "testdata/invoice.tmpl"),
+			},
+		},
+		{
+			name:    "invoice_errors",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("invoice"),
+				step("invoice", "nobody"),
+				step("invoice", "spaceship", "2017-04-11"),
+				step("invoice", "spaceship", "-t", "testdata/missing.tmpl"),
+			},
+		},
+	})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jzacsh/punch"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const logDefaultCount int = 10
+
+// COUNT of changes to list, per `log [-n COUNT]`; 0 lists them all.
+func parseLogCli(args []string) (int, error) {
+	count := logDefaultCount
+	for i := 0; i < len(args); i++ {
+		if args[i] != "-n" {
+			return count, fmt.Errorf("unrecognized commandline at '%s'", args[i:])
+		}
+		if i+1 >= len(args) {
+			return count, errors.New("-n passed, but no COUNT found")
+		}
+		n, e := strconv.Atoi(args[i+1])
+		if e != nil || n < 0 {
+			return count, fmt.Errorf("expected COUNT to be 0 or more, but got '%s'", args[i+1])
+		}
+		count = n
+		i++ // skip COUNT
+	}
+	return count, nil
+}
+
+// Describes each of image's rows, in one line apiece.
+func describeImage(image *punch.CardImage) []string {
+	var rows []string
+	for _, card := range image.Punches {
+		rows = append(rows, fmt.Sprintf("'%s' punch-%s at %s (note: '%s')",
+			card.Project, punch.FromStatus(card.IsStart),
+			card.Punch.Format(punch.FormatDateTime), punch.FromNote(card.Note)))
+	}
+	for _, bill := range image.Bills {
+		rows = append(rows, fmt.Sprintf("'%s' pay period from %s to %s (note: '%s')",
+			bill.Project,
+			bill.Startclusive.Format(punch.FormatDateTime),
+			bill.Endclusive.Format(punch.FormatDateTime),
+			punch.FromNote(bill.Note)))
+	}
+	for _, rate := range image.Rates {
+		rows = append(rows, fmt.Sprintf("'%s' rate of %s/hour from %s (rounding: %s)",
+			rate.Project, rate.Hourly,
+			rate.Effective.Format(punch.FormatDateTime), rate.RoundingString()))
+	}
+	for _, tag := range image.Tags {
+		rows = append(rows, fmt.Sprintf("'%s' session at %s tagged #%s",
+			tag.Project, tag.Punch.Format(punch.FormatDateTime), tag.Tag))
+	}
+	for _, b := range image.Breaks {
+		stop := "still paused"
+		if !b.IsPaused() {
+			stop = fmt.Sprintf("to %s", b.Stop.Format(punch.FormatDateTime))
+		}
+		rows = append(rows, fmt.Sprintf("'%s' session at %s paused from %s %s",
+			b.Project, b.Punch.Format(punch.FormatDateTime),
+			b.Start.Format(punch.FormatDateTime), stop))
+	}
+	return rows
+}
+
+// Every stamp in image, for getTZContext.
+func imageStamps(image *punch.CardImage) []time.Time {
+	var stamps []time.Time
+	for _, card := range image.Punches {
+		stamps = append(stamps, card.Punch)
+	}
+	for _, bill := range image.Bills {
+		stamps = append(stamps, bill.Startclusive, bill.Endclusive)
+	}
+	for _, rate := range image.Rates {
+		stamps = append(stamps, rate.Effective)
+	}
+	for _, tag := range image.Tags {
+		stamps = append(stamps, tag.Punch)
+	}
+	for _, b := range image.Breaks {
+		stamps = append(stamps, b.Punch, b.Start)
+		if !b.IsPaused() {
+			stamps = append(stamps, b.Stop)
+		}
+	}
+	return stamps
+}
+
+// Renders entry as a header line, then a "-" line per row it removed and a "+"
+// line per row it added (a row it modified being both).
+func describeEntry(entry *punch.JournalEntry) string {
+	var undone string
+	if entry.IsUndone {
+		undone = " [undone]"
+	}
+	lines := []string{fmt.Sprintf("#%d at %s%s: %s",
+		entry.ID, entry.At.Format(punch.FormatDateTime), undone, entry.Command)}
+
+	before, after := describeImage(entry.Before), describeImage(entry.After)
+	has := func(rows []string, row string) bool {
+		for _, r := range rows {
+			if r == row {
+				return true
+			}
+		}
+		return false
+	}
+	for _, row := range before {
+		if !has(after, row) {
+			lines = append(lines, "  - "+row)
+		}
+	}
+	for _, row := range after {
+		if !has(before, row) {
+			lines = append(lines, "  + "+row)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func entryStamps(entry *punch.JournalEntry) []time.Time {
+	return append(
+		append([]time.Time{entry.At}, imageStamps(entry.Before)...),
+		imageStamps(entry.After)...)
+}
+
+func subCmdLog(clock punch.Clock, dbPath string, args []string) error {
+	count, e := parseLogCli(args)
+	if e != nil {
+		return fmt.Errorf("parse args: %s", e)
+	}
+
+	card, e := openCard(clock, dbPath)
+	if e != nil {
+		return fmt.Errorf("punch cards: %s", e)
+	}
+	defer card.Close()
+
+	entries, e := card.Journal(count)
+	if e != nil {
+		return e
+	}
+	if len(entries) == 0 {
+		return errors.New("no changes journaled, yet")
+	}
+
+	var stamps []time.Time
+	for _, entry := range entries {
+		stamps = append(stamps, entryStamps(entry)...)
+	}
+	fmt.Printf("Changes to the card, newest first (in %s):\n",
+		getTZContext(clock.Now(), stamps...))
+	for _, entry := range entries {
+		fmt.Println(describeEntry(entry))
+	}
+	return nil
+}
+
+// Undoes the latest change, or redoes the last undone change if isRedo.
+func subCmdUndo(clock punch.Clock, dbPath string, args []string, isRedo bool) error {
+	if len(args) > 0 {
+		return fmt.Errorf("expected no arguments, but got '%s'", strings.Join(args, " "))
+	}
+
+	card, e := openCard(clock, dbPath)
+	if e != nil {
+		return fmt.Errorf("punch cards: %s", e)
+	}
+	defer card.Close()
+
+	action, replay := "Undid", card.Undo
+	if isRedo {
+		action, replay = "Redid", card.Redo
+	}
+	entry, e := replay()
+	if e != nil {
+		return e
+	}
+	fmt.Printf("%s (in %s):\n%s\n",
+		action, getTZContext(clock.Now(), entryStamps(entry)...), describeEntry(entry))
+	return nil
+}
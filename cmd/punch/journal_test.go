@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestParseLogCli(t *testing.T) {
+	for _, tt := range []struct {
+		args     []string
+		expected int
+	}{
+		{nil, logDefaultCount},
+		{[]string{"-n", "3"}, 3},
+		{[]string{"-n", "0"}, 0},
+	} {
+		if count, e := parseLogCli(tt.args); e != nil || count != tt.expected {
+			t.Errorf("%q: got count %d (error: %v), expected %d", tt.args, count, e, tt.expected)
+		}
+	}
+
+	for _, args := range [][]string{{"-n"}, {"-n", "-1"}, {"-n", "x"}, {"3"}} {
+		if _, e := parseLogCli(args); e == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func TestJournalE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "journal_undo_redo",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("log"),
+				step("undo"),
+				step("p", "spaceship", "--at", "-1h", "-n", "liftoff"),
+				step("p"),
+				step("a", "-y", "@1491963757", "shipped"),
+				step("d", "punch", "spaceship", "-y", "@1491966400"),
+				step("log"),
+				step("undo"),
+				step("undo"),
+				step("q", "report", "spaceship", "2017-04-11"),
+				step("log", "-n", "2"),
+				step("redo"),
+				step("rate", "spaceship", "85", "USD"),
+				step("redo"),
+				step("log", "-n", "3"),
+				step("undo", "now"),
+				step("log", "-n", "x"),
+			},
+		},
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jzacsh/punch"
+	"os"
+)
+
+func parseMigrateCmd(args []string) (bool, error) {
+	isDryRun := false
+	for _, arg := range args {
+		if arg != "-d" {
+			return false, fmt.Errorf("expected only optional -d flag, but got '%s'", arg)
+		}
+		isDryRun = true
+	}
+	return isDryRun, nil
+}
+
+func subCmdMigrate(clock punch.Clock, dbPath string, args []string) error {
+	isDryRun, e := parseMigrateCmd(args)
+	if e != nil {
+		return e
+	}
+
+	card, e := punch.OpenUnmigrated(dbPath)
+	if e != nil {
+		return fmt.Errorf("punch cards: %s", e)
+	}
+	defer card.Close()
+	card.Clock = clock
+
+	version, e := card.SchemaVersion()
+	if e != nil {
+		return e
+	}
+	pending, e := card.PendingMigrations()
+	if e != nil {
+		return e
+	}
+	if len(pending) == 0 {
+		fmt.Printf("Punch card already at latest schema, v%d; nothing to do\n", version)
+		return nil
+	}
+
+	fmt.Printf(
+		"Punch card at schema v%d; %d migration(s) to reach v%d:\n",
+		version, len(pending), punch.LatestSchemaVersion())
+	for _, m := range pending {
+		fmt.Printf("  v%d: %s\n", m.Version, m.Description)
+	}
+	if isDryRun {
+		fmt.Fprint(os.Stderr, "[-d]ry-run: finishing early; NO changes written\n")
+		return nil
+	}
+
+	backup, e := card.Backup()
+	if e != nil {
+		return e
+	}
+	fmt.Printf("Backed up v%d card to: %s\n", version, backup)
+
+	applied, e := card.Migrate()
+	for _, m := range applied {
+		fmt.Printf("Migrated to v%d\n", m.Version)
+	}
+	if e != nil {
+		return e
+	}
+	fmt.Println("Done.")
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestParseMigrateCmd(t *testing.T) {
+	for _, tt := range []struct {
+		args     []string
+		isDryRun bool
+		isError  bool
+	}{
+		{nil, false, false},
+		{[]string{"-d"}, true, false},
+		{[]string{"-x"}, false, true},
+		{[]string{"-d", "now"}, false, true},
+	} {
+		isDryRun, e := parseMigrateCmd(tt.args)
+		if (e != nil) != tt.isError {
+			t.Errorf("parseMigrateCmd(%q): got error %v", tt.args, e)
+			continue
+		}
+		if isDryRun != tt.isDryRun {
+			t.Errorf("parseMigrateCmd(%q): got dry-run %t", tt.args, isDryRun)
+		}
+	}
+}
+
+func TestMigrateE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "migrate_up_to_date",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("migrate", "-d"),
+				step("migrate"),
+				step("migrate", "-x"),
+			},
+		},
+		{
+			name:    "migrate_legacy_card",
+			fixture: legacyFixture,
+			steps: []e2eStep{
+				step("migrate", "-d"),
+				step("migrate"),
+				step("migrate"),
+			},
+		},
+		{
+			name:    "migrate_legacy_card_on_open",
+			fixture: legacyFixture,
+			steps: []e2eStep{
+				step("q", "list"),
+				step("q", "list"),
+				step("migrate", "-d"),
+			},
+		},
+	})
+}
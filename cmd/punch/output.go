@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/jzacsh/punch"
+	"io"
+	"strings"
+	"time"
+)
+
+// How query results are printed; all but formatText are stable, machine-readable
+// listings whose fields are documented under OUTPUT FORMATS in help.go.
+type outputFormat string
+
+const (
+	formatText outputFormat = "text"
+	formatJSON outputFormat = "json"
+	formatCSV  outputFormat = "csv"
+	formatTSV  outputFormat = "tsv"
+)
+
+func parseOutputFormat(name string) (outputFormat, error) {
+	switch f := outputFormat(strings.ToLower(strings.TrimSpace(name))); f {
+	case formatText, formatJSON, formatCSV, formatTSV:
+		return f, nil
+	}
+	return formatText, fmt.Errorf(
+		"expected FORMAT of text, json, csv or tsv, but got '%s'", name)
+}
+
+// A machine-readable listing of records, each with one value per field. Values
+// are strings, integers, or nil where a record has no value for a field.
+type table struct {
+	fields []string
+	rows   [][]interface{}
+}
+
+func (t *table) add(row ...interface{}) { t.rows = append(t.rows, row) }
+
+// Renders stamps as RFC-3339, eg: "2017-04-11T21:31:38-04:00"
+func stampValue(stamp time.Time) interface{} { return stamp.Format(time.RFC3339) }
+
+func durationValue(d time.Duration) interface{} { return int64(d / time.Second) }
+
+func optionalValue(s string) interface{} {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// Joins tags with commas, eg: "billable,meeting", or nil if there are none.
+func tagsValue(tags []string) interface{} {
+	if len(tags) == 0 {
+		return nil
+	}
+	return strings.Join(tags, ",")
+}
+
+func clientTable(clients []string) *table {
+	t := &table{fields: []string{"project"}}
+	for _, client := range clients {
+		t.add(client)
+	}
+	return t
+}
+
+// Every client in trees, parents before their sub-clients.
+func clientTreeTable(trees []*punch.ClientNode) *table {
+	t := &table{fields: []string{"project", "parent"}}
+	var add func(nodes []*punch.ClientNode)
+	add = func(nodes []*punch.ClientNode) {
+		for _, node := range nodes {
+			t.add(node.Client, optionalValue(punch.ClientParent(node.Client)))
+			add(node.Children)
+		}
+	}
+	add(trees)
+	return t
+}
+
+// Punches, with the tags of the sessions each punch-in opened.
+func punchTable(cards []*punch.CardSchema, tags punch.SessionTags) *table {
+	t := &table{fields: []string{"punch", "status", "project", "note", "tags"}}
+	for _, c := range cards {
+		var sessionTags []string
+		if c.IsStart {
+			sessionTags = tags.Of(c)
+		}
+		t.add(
+			stampValue(c.Punch),
+			punch.FromStatus(c.IsStart),
+			c.Project,
+			optionalValue(c.Note),
+			tagsValue(sessionTags))
+	}
+	return t
+}
+
+// Sessions, with any fields specific to the table (eg: status's
+// unbilled_seconds) kept in place, ahead of those added since.
+func newSessionTable(fields ...string) *table {
+	t := &table{fields: []string{
+		"project", "start", "stop", "duration_seconds", "note_start", "note_stop",
+	}}
+	t.fields = append(t.fields, fields...)
+	t.fields = append(t.fields, "tags", "break_seconds")
+	return t
+}
+
+// Adds s, with values for any of the table's own fields.
+func (t *table) addSession(client string, s *punch.Session, values ...interface{}) {
+	row := []interface{}{
+		client,
+		stampValue(s.StartAt),
+		stampValue(s.StopAt),
+		durationValue(s.Duration),
+		optionalValue(s.NoteStart),
+		optionalValue(s.NoteStop),
+	}
+	row = append(row, values...)
+	t.add(append(row, tagsValue(s.Tags), durationValue(s.BreakTime()))...)
+}
+
+// Adds the session opened by punchIn that, as of now, has yet to be closed.
+func (t *table) addOpenSession(
+	punchIn *punch.CardSchema, now time.Time,
+	tags []string, breaks []*punch.BreakSchema, values ...interface{}) {
+	s := punch.SoFar(punchIn, breaks, now)
+	row := []interface{}{
+		punchIn.Project,
+		stampValue(punchIn.Punch),
+		nil, /*stop*/
+		durationValue(s.Duration),
+		optionalValue(punchIn.Note),
+		nil, /*note_stop*/
+	}
+	row = append(row, values...)
+	t.add(append(row, tagsValue(tags), durationValue(s.BreakTime()))...)
+}
+
+// Open sessions, as in newSessionTable, along with the unbilled time on each
+// session's client.
+func statusTable(unbilled []*punch.Unbilled, tags punch.SessionTags) *table {
+	t := newSessionTable("unbilled_seconds")
+	for _, u := range unbilled {
+		t.addOpenSession(
+			u.Open, u.AsOf, tags.Of(u.Open), u.OpenBreaks, durationValue(u.Total()))
+	}
+	return t
+}
+
+func unbilledTable(unbilled []*punch.Unbilled) *table {
+	t := &table{fields: []string{
+		"project", "since", "sessions", "working", "duration_seconds",
+	}}
+	for _, u := range unbilled {
+		var since interface{}
+		if !u.Since.IsZero() {
+			since = stampValue(u.Since)
+		}
+		t.add(u.Client, since, len(u.Sessions), u.Open != nil, durationValue(u.Total()))
+	}
+	return t
+}
+
+func billTable(reports []*punch.BillReport) *table {
+	t := &table{fields: []string{
+		"project", "startclusive", "endclusive", "sessions", "duration_seconds", "note",
+		"billable_seconds", "amount_cents", "currency",
+	}}
+	for _, r := range reports {
+		var cents, currency interface{}
+		if r.Amount != nil {
+			cents, currency = r.Amount.Cents, r.Amount.Currency
+		}
+		t.add(
+			r.Bill.Project,
+			stampValue(r.Bill.Startclusive),
+			stampValue(r.Bill.Endclusive),
+			len(r.Sessions),
+			durationValue(r.Total()),
+			optionalValue(r.Bill.Note),
+			durationValue(r.Billable),
+			cents,
+			currency)
+	}
+	return t
+}
+
+func rangeTable(report *punch.RangeReport) *table {
+	t := &table{fields: []string{
+		"project", "grouping", "start", "sessions", "duration_seconds",
+	}}
+	for _, g := range report.Groups {
+		t.add(
+			g.Client,
+			string(report.Grouping),
+			stampValue(g.Start),
+			g.SessionCount(),
+			durationValue(g.Total()))
+	}
+	return t
+}
+
+// One record per cell of sheet, by client, then column.
+func timesheetTable(sheet *punch.Timesheet) *table {
+	t := &table{fields: []string{"project", "start", "end", "duration_seconds"}}
+	for _, row := range sheet.Rows {
+		for i, cell := range row.Cells {
+			t.add(
+				row.Client,
+				stampValue(sheet.Columns[i]),
+				stampValue(sheet.ColumnEnd(i)),
+				durationValue(cell))
+		}
+	}
+	return t
+}
+
+func overlapTable(overlaps []*punch.Overlap) *table {
+	t := &table{fields: []string{
+		"start", "stop", "duration_seconds", "double_counted_seconds", "projects",
+	}}
+	for _, o := range overlaps {
+		t.add(
+			stampValue(o.StartAt),
+			stampValue(o.StopAt),
+			durationValue(o.Duration()),
+			durationValue(o.DoubleCounted()),
+			strings.Join(o.Clients, ","))
+	}
+	return t
+}
+
+func rateTable(rates []*punch.RateSchema) *table {
+	t := &table{fields: []string{
+		"project", "effective", "hourly_cents", "currency", "round_minutes", "per_session",
+	}}
+	for _, r := range rates {
+		t.add(
+			r.Project,
+			stampValue(r.Effective),
+			r.Hourly.Cents,
+			r.Hourly.Currency,
+			int(r.Round/time.Minute),
+			r.PerSession)
+	}
+	return t
+}
+
+func (t *table) write(w io.Writer, format outputFormat) error {
+	switch format {
+	case formatJSON:
+		return t.writeJSON(w)
+	case formatCSV:
+		return t.writeDelimited(w, ',')
+	case formatTSV:
+		return t.writeDelimited(w, '\t')
+	}
+	return fmt.Errorf("no tabular rendering for format '%s'", format)
+}
+
+// Writes an array of objects, one per line, with keys in field order.
+func (t *table) writeJSON(w io.Writer) error {
+	if len(t.rows) == 0 {
+		_, e := fmt.Fprintln(w, "[]")
+		return e
+	}
+
+	var out bytes.Buffer
+	out.WriteString("[\n")
+	for i, row := range t.rows {
+		out.WriteString("  {")
+		for j, field := range t.fields {
+			if j > 0 {
+				out.WriteString(", ")
+			}
+			key, e := json.Marshal(field)
+			if e != nil {
+				return fmt.Errorf("encoding field name %s: %s", field, e)
+			}
+			value, e := json.Marshal(row[j])
+			if e != nil {
+				return fmt.Errorf("encoding %s: %s", field, e)
+			}
+			fmt.Fprintf(&out, "%s: %s", key, value)
+		}
+		out.WriteString("}")
+		if i < len(t.rows)-1 {
+			out.WriteString(",")
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString("]\n")
+
+	_, e := out.WriteTo(w)
+	return e
+}
+
+// Writes a header of field names, then one line per row, quoted per RFC-4180.
+func (t *table) writeDelimited(w io.Writer, delimiter rune) error {
+	out := csv.NewWriter(w)
+	out.Comma = delimiter
+	if e := out.Write(t.fields); e != nil {
+		return e
+	}
+	for _, row := range t.rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			if value != nil {
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if e := out.Write(record); e != nil {
+			return e
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
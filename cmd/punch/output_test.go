@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		expected outputFormat
+	}{
+		{"text", formatText},
+		{"json", formatJSON},
+		{" CSV ", formatCSV},
+		{"tsv", formatTSV},
+	} {
+		if actual, e := parseOutputFormat(tt.name); e != nil || actual != tt.expected {
+			t.Errorf("parseOutputFormat('%s'): got '%s' (error: %v)", tt.name, actual, e)
+		}
+	}
+	if _, e := parseOutputFormat("yaml"); e == nil {
+		t.Errorf("parseOutputFormat('yaml'): expected error, got none")
+	}
+}
+
+func TestParseGlobalFlags(t *testing.T) {
+	flags, args, e := parseGlobalFlags([]string{"punch", "--format", "json", "q", "list"})
+	if e != nil || flags.format != formatJSON || flags.location != nil ||
+		strings.Join(args, " ") != "punch q list" {
+		t.Errorf("got flags %+v, args %q (error: %v)", flags, args, e)
+	}
+
+	flags, args, e = parseGlobalFlags(
+		[]string{"punch", "--tz", "America/New_York", "--format", "csv", "q"})
+	if e != nil || flags.format != formatCSV || flags.location == nil ||
+		flags.location.String() != "America/New_York" || strings.Join(args, " ") != "punch q" {
+		t.Errorf("got flags %+v, args %q (error: %v)", flags, args, e)
+	}
+
+	flags, args, e = parseGlobalFlags([]string{"punch", "--debug", "--tz", "UTC", "q"})
+	if e != nil || !flags.isDebug || flags.location == nil || strings.Join(args, " ") != "punch q" {
+		t.Errorf("got flags %+v, args %q (error: %v)", flags, args, e)
+	}
+
+	flags, args, e = parseGlobalFlags([]string{"punch", "p", "--format", "json"})
+	if e != nil || flags.format != formatText || len(args) != 4 {
+		t.Errorf("expected flags after sub-command untouched, got %+v, %q", flags, args)
+	}
+
+	for _, bad := range [][]string{
+		{"punch", "--format"},
+		{"punch", "--format", "yaml", "q"},
+		{"punch", "--tz"},
+		{"punch", "--tz", "Mars/Olympus_Mons", "q"},
+		{"punch", "--tz", " ", "q"},
+	} {
+		if _, _, e := parseGlobalFlags(bad); e == nil {
+			t.Errorf("parseGlobalFlags(%q): expected error, got none", bad)
+		}
+	}
+}
+
+func sampleTable() *table {
+	t := &table{fields: []string{"project", "count", "note"}}
+	t.add("acme", 3, "says \"hi\", twice")
+	t.add("tabs\tand\nlines", int64(0), nil)
+	return t
+}
+
+func TestTableWrite(t *testing.T) {
+	for _, tt := range []struct {
+		format   outputFormat
+		expected string
+	}{
+		{formatJSON, `[
+  {"project": "acme", "count": 3, "note": "says \"hi\", twice"},
+  {"project": "tabs\tand\nlines", "count": 0, "note": null}
+]
+`},
+		{formatCSV, `project,count,note
+acme,3,"says ""hi"", twice"
+"tabs	and
+lines",0,
+`},
+		{formatTSV, "project\tcount\tnote\n" +
+			"acme\t3\t\"says \"\"hi\"\", twice\"\n" +
+			"\"tabs\tand\nlines\"\t0\t\n"},
+	} {
+		var out bytes.Buffer
+		if e := sampleTable().write(&out, tt.format); e != nil {
+			t.Errorf("writing %s: %s", tt.format, e)
+			continue
+		}
+		if out.String() != tt.expected {
+			t.Errorf("writing %s: got:\n%s\nexpected:\n%s", tt.format, out.String(), tt.expected)
+		}
+	}
+
+	var out bytes.Buffer
+	if e := (&table{fields: []string{"project"}}).write(&out, formatJSON); e != nil ||
+		out.String() != "[]\n" {
+		t.Errorf("writing empty json table: got '%s' (error: %v)", out.String(), e)
+	}
+	if e := sampleTable().write(&out, formatText); e == nil {
+		t.Errorf("writing text table: expected error, got none")
+	}
+}
+
+func TestQueryFormatE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "query_format_sample",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("--format", "json"),
+				step("--format", "json", "q", "list"),
+				step("--format", "csv", "q", "list"),
+				step("--format", "json", "q", "report", "spaceship", "2017-04-11"),
+				step("--format", "tsv", "q", "report", "spaceship", "2017-04-11"),
+				step("--format", "json", "q", "report", "nobody"),
+				step("--format", "csv", "q", "bills"),
+				step("--format", "json", "q", "bills", "-last", "golangpunch"),
+				step("--format", "json", "q", "bills", "nobody"),
+				step("--format", "csv", "q", "dump"),
+				step("--format", "yaml", "q", "list"),
+				step("--format", "json", "p", "acme"),
+			},
+		},
+		{
+			name:    "query_format_open",
+			fixture: twoOpenFixture,
+			steps: []e2eStep{
+				step("--format", "json"),
+				step("--format", "tsv", "q", "status"),
+				step("--format", "json", "q", "unbilled"),
+				step("--format", "csv", "q", "unbilled", "nobody"),
+				step("--format", "json", "q", "report", "golangpunch", "2017-04-12"),
+			},
+		},
+		{
+			name:    "query_format_strays",
+			fixture: strayFixture,
+			steps:   []e2eStep{step("--format", "csv", "q", "report", "strays")},
+		},
+		{
+			name:    "query_format_empty_card",
+			fixture: emptyFixture,
+			steps: []e2eStep{
+				step("--format", "json", "q", "list"),
+				step("--format", "csv", "q", "dump"),
+			},
+		},
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jzacsh/punch"
+	"strings"
+	"time"
+)
+
+// CLIENT (empty if not passed), AT (zero value if not passed), error
+func parsePauseArgs(args []string, now time.Time) (string, time.Time, error) {
+	var client string
+	var at time.Time
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimSpace(args[i])
+		switch {
+		case arg == "--at":
+			if i+1 >= len(args) {
+				return "", at, fmt.Errorf("--at passed, but no TIME found")
+			}
+			i++
+			var e error
+			if at, e = parseStampCommand(args[i], now); e != nil {
+				return "", at, fmt.Errorf("--at TIME: %s", e)
+			}
+
+		case i == 0:
+			client = arg
+			if !punch.IsValidClient(client) {
+				return "", at, fmt.Errorf("invalid CLIENT, '%s'", args[0])
+			}
+
+		default:
+			return "", at, fmt.Errorf(
+				"expected [CLIENT] [--at TIME], but got '%s'", strings.Join(args[i:], " "))
+		}
+	}
+	return client, at, nil
+}
+
+func subCmdPause(clock punch.Clock, dbPath string, args []string, isResume bool) error {
+	client, at, e := parsePauseArgs(args, clock.Now())
+	if e != nil {
+		return e
+	}
+
+	card, e := openCard(clock, dbPath)
+	if e != nil {
+		return fmt.Errorf("punch cards: %s", e)
+	}
+	defer card.Close()
+
+	if isResume {
+		_, e = card.ResumeAt(client, at)
+	} else {
+		_, e = card.PauseAt(client, at)
+	}
+	return e
+}
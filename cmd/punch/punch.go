@@ -1,161 +1,84 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/jzacsh/punch"
 	"strings"
+	"time"
 )
 
-func parseArgs(args []string) (string, string, error) {
-	if len(args) == 0 {
-		return "", "", nil
-	}
-	var client, note, noteRaw string
-	clientOrFlag := strings.TrimSpace(args[0])
-	if len(args) == 1 {
-		if clientOrFlag == "-n" {
-			return "", "", fmt.Errorf(
-				"expected CLIENT, -n NOTE, or CLIENT -n NOTE, but got just -n")
-		}
-
-		client = clientOrFlag
-		if len(client) == 0 {
-			return "", "", fmt.Errorf(
-				"CLIENT must be non-empty (or -n provided), but got '%s'", args[0])
-		}
-		return client, note, nil
-	} else {
-		flagOrNoteChunk := strings.TrimSpace(args[1])
-		if clientOrFlag == "-n" {
-			noteRaw = strings.Join(args[1:], " ")
-		} else {
-			client = clientOrFlag
-			noteRaw = strings.Join(args[2:], " ")
-
-			if flagOrNoteChunk != "-n" {
-				return "", "", fmt.Errorf(
-					"expected CLIENT [-n NOTE], but got CLIENT='%s' followed by, '%s'",
-					clientOrFlag, noteRaw)
+// CLIENT, NOTE, AT (zero value if not passed), TAGs, error
+func parseArgs(args []string, now time.Time) (string, string, time.Time, []string, error) {
+	var client, note string
+	var at time.Time
+	var tags []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimSpace(args[i])
+		switch {
+		case arg == "-n":
+			if len(args) == 1 {
+				return "", "", at, nil, fmt.Errorf(
+					"expected CLIENT, -n NOTE, or CLIENT -n NOTE, but got just -n")
 			}
-		}
-	}
-
-	note = strings.TrimSpace(noteRaw)
-	if len(note) < 1 {
-		return "", "", fmt.Errorf(
-			"expected -n NOTE but '-n %s'",
-			noteRaw)
-	}
-
-	return client, note, nil
-}
-
-func getImpliedClient(db *sql.DB) (string, error) {
-	rows, e := db.Query(`
-		SELECT * FROM punchcard
-		GROUP BY project
-		ORDER BY punch DESC;
-	`)
-	if e != nil {
-		return "", e
-	}
-	defer rows.Close()
-
-	errorMsgIntent := "implying one CLIENT is on clock"
-
-	var punchedInto string
-	for rows.Next() {
-		card, e := scanToCard(rows)
-		if e != nil {
-			return "", fmt.Errorf("punch cards: %s", e)
-		}
-
-		if card.IsStart {
-			if len(punchedInto) > 0 {
-				return "", fmt.Errorf("%s, but found 2: '%s' & '%s'",
-					errorMsgIntent, punchedInto, card.Project)
+			noteRaw := strings.Join(args[i+1:], " ")
+			note = strings.TrimSpace(noteRaw)
+			if len(note) < 1 {
+				return "", "", at, nil, fmt.Errorf(
+					"expected -n NOTE but '-n %s'",
+					noteRaw)
 			}
-			punchedInto = card.Project
-		}
-	}
+			i = len(args) // NOTE is the remainder of the commandline
 
-	if len(punchedInto) == 0 {
-		return "", fmt.Errorf("%s, but none are", errorMsgIntent)
-	}
-
-	return punchedInto, nil
-}
+		case arg == "--at":
+			if i+1 >= len(args) {
+				return "", "", at, nil, fmt.Errorf("--at passed, but no TIME found")
+			}
+			i++
+			var e error
+			if at, e = parseStampCommand(args[i], now); e != nil {
+				return "", "", at, nil, fmt.Errorf("--at TIME: %s", e)
+			}
 
-func isPunchIn(db *sql.DB, client string, isImplicitPunchOut bool) (bool, error) {
-	if isImplicitPunchOut {
-		return false, nil
-	}
+		case arg == "-t":
+			if i+1 >= len(args) {
+				return "", "", at, nil, fmt.Errorf("-t passed, but no TAG found")
+			}
+			i++
+			tag := strings.TrimSpace(args[i])
+			if !punch.IsValidTag(tag) {
+				return "", "", at, nil, fmt.Errorf("-t TAG: invalid TAG, '%s'", args[i])
+			}
+			tags = append(tags, tag)
 
-	rows, e := db.Query(`
-		SELECT * FROM punchcard
-		WHERE project IS ?
-		ORDER BY punch DESC
-		LIMIT 1;
-	`, client)
-	if e != nil {
-		return false, e
-	}
-	defer rows.Close()
+		case i == 0:
+			client = arg
+			if len(client) == 0 {
+				return "", "", at, nil, fmt.Errorf(
+					"CLIENT must be non-empty (or -n provided), but got '%s'", args[0])
+			}
 
-	for rows.Next() {
-		card, e := scanToCard(rows)
-		if e != nil {
-			return false, e
+		default:
+			return "", "", at, nil, fmt.Errorf(
+				"expected CLIENT [--at TIME] [-t TAG]... [-n NOTE], but got CLIENT='%s' followed by, '%s'",
+				client, strings.Join(args[i:], " "))
 		}
-		return !card.IsStart, nil
 	}
-	return true, nil
-}
 
-func punchProject(db *sql.DB, card *CardSchemaSQL) error {
-	stmt, e := db.Prepare(`
-		INSERT INTO
-		punchcard(punch, status, project, note)
-		VALUES (?, ?, ?, ?)
-	`)
-	if e != nil {
-		return e
-	}
-
-	_, e = stmt.Exec(card.Punch, card.Status, card.Project, card.Note)
-	// TODO(zacsh) expose result val here via debug flags on cli
-
-	return e
+	return client, note, at, tags, nil
 }
 
-func subCmdPunch(dbPath string, args []string) error {
-	explicitClient, note, e := parseArgs(args)
+func subCmdPunch(clock punch.Clock, dbPath string, args []string) error {
+	client, note, at, tags, e := parseArgs(args, clock.Now())
 	if e != nil {
 		return e
 	}
 
-	db, e := sql.Open("sqlite3", dbPath)
+	card, e := openCard(clock, dbPath)
 	if e != nil {
 		return fmt.Errorf("punch cards: %s", e)
 	}
-	defer db.Close()
-
-	isImplicitPunchOut := false
-	client := explicitClient
-	if len(client) == 0 {
-		client, e = getImpliedClient(db)
-		if e != nil {
-			return e
-		}
-		isImplicitPunchOut = true
-	}
+	defer card.Close()
 
-	isPunchIn, e := isPunchIn(db, client, isImplicitPunchOut)
-	if e != nil {
-		return e
-	}
-
-	sqlCard := buildCardSQL(isPunchIn, client, note)
-	return punchProject(db, sqlCard)
+	_, e = card.PunchAt(client, note, at, tags...)
+	return e
 }
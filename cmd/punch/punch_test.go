@@ -0,0 +1,239 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args   []string
+		client string
+		note   string
+		at     time.Time
+		tags   string
+	}{
+		{nil, "", "", time.Time{}, ""},
+		{[]string{"acme"}, "acme", "", time.Time{}, ""},
+		{[]string{" acme "}, "acme", "", time.Time{}, ""},
+		{[]string{"-n", "wrapping", "up"}, "", "wrapping up", time.Time{}, ""},
+		{[]string{"acme", "-n", "kick", "off"}, "acme", "kick off", time.Time{}, ""},
+		{[]string{"acme", "-n", "  padded  "}, "acme", "padded", time.Time{}, ""},
+		{[]string{"acme", "--at", "@1491960000"}, "acme", "", time.Unix(1491960000, 0), ""},
+		{[]string{"acme", "--at", "-1h", "-n", "late"}, "acme", "late", now.Add(-time.Hour), ""},
+		{[]string{"--at", "-15m"}, "", "", now.Add(-time.Minute * 15), ""},
+		{[]string{"-n", "--at", "-15m"}, "", "--at -15m", time.Time{}, ""},
+		{[]string{"acme", "-t", "meeting", "-t", "billable"}, "acme", "", time.Time{}, "meeting,billable"},
+		{[]string{"-t", "meeting", "-n", "-t", "x"}, "", "-t x", time.Time{}, "meeting"},
+	} {
+		client, note, at, tags, e := parseArgs(tt.args, now)
+		if e != nil {
+			t.Errorf("parseArgs(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if client != tt.client || note != tt.note || !at.Equal(tt.at) ||
+			strings.Join(tags, ",") != tt.tags {
+			t.Errorf(
+				"parseArgs(%q): got ('%s', '%s', %s, %q), expected ('%s', '%s', %s, '%s')",
+				tt.args, client, note, at, tags, tt.client, tt.note, tt.at, tt.tags)
+		}
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{[]string{"-n"}, "but got just -n"},
+		{[]string{" "}, "CLIENT must be non-empty"},
+		{[]string{"acme", "-n"}, "expected -n NOTE"},
+		{[]string{"acme", "-n", "  "}, "expected -n NOTE"},
+		{[]string{"acme", "boop"}, "CLIENT='acme' followed by, 'boop'"},
+		{[]string{"acme", "--at"}, "no TIME found"},
+		{[]string{"acme", "--at", "whenever"}, "--at TIME"},
+		{[]string{"acme", "-t"}, "no TAG found"},
+		{[]string{"acme", "-t", "two words"}, "invalid TAG"},
+	} {
+		_, _, _, _, e := parseArgs(tt.args, now)
+		if e == nil {
+			t.Errorf("parseArgs(%q): expected error, got none", tt.args)
+			continue
+		}
+		if !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf(
+				"parseArgs(%q): expected error containing '%s', got: %s",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestParseSwitchCli(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	cmd, e := parseSwitchCli([]string{"--all", "acme", "--at", "-1h", "-n", "on", "call"}, now)
+	if e != nil {
+		t.Fatalf("parseSwitchCli: unexpected error: %s", e)
+	}
+	if cmd.Client != "acme" || !cmd.IsAll || !cmd.At.Equal(now.Add(-time.Hour)) || cmd.Note != "on call" {
+		t.Errorf("parseSwitchCli: got %+v", cmd)
+	}
+
+	for _, args := range [][]string{nil, {"--all"}, {"-n", "note"}, {"--at", "-1h"}} {
+		if _, e := parseSwitchCli(args, now); e == nil ||
+			!strings.Contains(e.Error(), "CLIENT is required") {
+			t.Errorf("parseSwitchCli(%q): expected CLIENT required, got: %v", args, e)
+		}
+	}
+}
+
+func TestPunchE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "punch_in_out",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("p", "golangpunch", "-n", "fixing", "bugs"),
+				{now: sampleNow.Add(time.Hour), args: []string{}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"p", "-n", "fixed"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "report", "golangpunch", "@1491970000"}},
+			},
+		},
+		{
+			name:    "punch_at",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("p", "spaceship", "--at", "@1491963757"),
+				step("p", "spaceship", "--at", "+1m"),
+				step("p", "spaceship", "--at", "-1h"),
+				step("p", "--at", "-15m", "-n", "forgot"),
+				step("q", "report", "spaceship", "-2h"),
+			},
+		},
+		{
+			name:    "punch_tags",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("p", "golangpunch", "-t", "meeting", "-t", "billable"),
+				{now: sampleNow.Add(time.Hour), args: []string{"p", "-t", "billable", "-t", "standup", "-n", "done"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"p", "golangpunch", "-t", "dev"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"p", "golangpunch", "-t", "bad tag"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"p", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"q", "report", "golangpunch", "@1491970000"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"q", "report", "golangpunch", "-t", "billable", "-t", "meeting"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"q", "range", "-t", "dev", "@1491970000"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"q", "dump", "-t", "meeting"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"--format", "csv", "q", "report", "golangpunch", "-t", "dev"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"--format", "csv", "q", "dump", "-t", "dev"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"log", "-n", "2"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"undo"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"undo"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"q", "report", "golangpunch", "@1491970000"}},
+			},
+		},
+		{
+			name:    "punch_out_implied_none_open",
+			fixture: sampleFixture,
+			steps:   []e2eStep{step("p")},
+		},
+		{
+			name:    "punch_out_implied_two_open",
+			fixture: twoOpenFixture,
+			steps:   []e2eStep{step("p"), step("p", "spaceship"), step("p")},
+		},
+		{
+			name:    "switch",
+			fixture: openFixture,
+			steps: []e2eStep{
+				step("switch"),
+				step("switch", "golangpunch"),
+				step("sw", "spaceship", "-t", "dev", "-n", "new", "feature"),
+				{now: sampleNow.Add(time.Hour), args: []string{"switch", "golangpunch", "--at", "-30m"}},
+				{now: sampleNow.Add(time.Hour), args: []string{"p", "spaceship"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"switch", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"switch", "--all", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"switch", "--all", "acme"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"log", "-n", "1"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "report", "spaceship", "@1491970000"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "status"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"undo"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "status"}},
+			},
+		},
+		{
+			name:    "punch_exclusive",
+			fixture: openFixture,
+			config:  "testdata/config_exclusive.json",
+			steps: []e2eStep{
+				step("p", "spaceship"),
+				step("switch", "spaceship"),
+				{now: sampleNow.Add(time.Hour), args: []string{"p"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"p", "golangpunch", "--at", "-90m"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"p", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "overlaps"}},
+			},
+		},
+		{
+			name:    "punch_exclusive_overlapping",
+			fixture: twoOpenFixture,
+			config:  "testdata/config_exclusive.json",
+			steps: []e2eStep{
+				step("p", "golangpunch"),
+				step("seek", "-y", "-10m", "-c", "@1491968200"),
+				step("q", "overlaps"),
+			},
+		},
+		{
+			name:    "punch_bad_concurrency",
+			fixture: sampleFixture,
+			config:  "testdata/config_bad_concurrency.json",
+			steps:   []e2eStep{step("p", "acme")},
+		},
+		{
+			name:    "punch_bad_invoice_config",
+			fixture: emptyFixture,
+			config:  "testdata/config_bad_invoice.json",
+			steps:   []e2eStep{step("p", "acme"), step("p", "spaceship")},
+		},
+		{
+			name:    "punch_malformed_config",
+			fixture: emptyFixture,
+			config:  "testdata/config_malformed.json",
+			steps:   []e2eStep{step("p", "acme"), step("q", "status")},
+		},
+		{
+			name:    "pause_resume",
+			fixture: openFixture,
+			steps: []e2eStep{
+				step("resume"),
+				step("pause", "spaceship"),
+				step("pause", "--at", "-1h"),
+				step("pause", "--at", "-15m"),
+				step("pause"),
+				{now: sampleNow.Add(time.Minute * 30), args: []string{"q", "status"}},
+				{now: sampleNow.Add(time.Minute * 30), args: []string{"--format", "csv", "q", "status"}},
+				{now: sampleNow.Add(time.Minute * 30), args: []string{"resume", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour), args: []string{"pause", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"p", "-n", "done"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"resume"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "report", "golangpunch", "-3h"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"--format", "csv", "q", "report", "golangpunch", "-3h"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"bill", "golangpunch", "-y"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "unbilled", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"log", "-n", "3"}},
+			},
+		},
+		{
+			name:    "switch_none_open",
+			fixture: emptyFixture,
+			steps:   []e2eStep{step("switch", "acme"), step("q", "status")},
+		},
+		{
+			name:    "punch_empty_card",
+			fixture: emptyFixture,
+			steps:   []e2eStep{step("p"), step("p", "acme"), step()},
+		},
+	})
+}
@@ -1,101 +1,177 @@
 package main
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/jzacsh/punch"
 	"math"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
 
-func scanToCard(rows *sql.Rows) (*CardSchema, error) {
-	raw := &CardSchemaSQL{}
-	if e := rows.Scan(&raw.Punch, &raw.Status, &raw.Project, &raw.Note); e != nil {
-		return nil, e
+// Pulls each `-t TAG` out of args, returning the TAGs, then the args left.
+func parseTagFlags(args []string) ([]string, []string, error) {
+	var tags, rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] != "-t" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, errors.New("-t passed, but no TAG found")
+		}
+		i++ // skip TAG
+		tag := strings.TrimSpace(args[i])
+		if !punch.IsValidTag(tag) {
+			return nil, nil, fmt.Errorf("-t TAG: invalid TAG, '%s'", args[i])
+		}
+		tags = append(tags, tag)
 	}
-	return raw.toCard(), nil
+	return tags, rest, nil
 }
 
-func scanToBill(rows *sql.Rows) (*BillSchema, error) {
-	raw := &BillSchemaSQL{}
-	e := rows.Scan(&raw.Endclusive, &raw.Startclusive, &raw.Project, &raw.Note)
-	if e != nil {
-		return nil, e
+// Describes tags a query was limited to, eg: " tagged #billable #meeting".
+func taggedClause(tags []string) string {
+	if len(tags) == 0 {
+		return ""
 	}
-	return raw.toBill(), nil
+	return " tagged #" + strings.Join(tags, " #")
 }
 
-func queryClient(db *sql.DB, client string, from *time.Time) error {
-	var fromStamp int64
-	if !from.IsZero() {
-		fromStamp = from.Unix()
+// Prints the time worked per tag across sessions, if any of them are tagged.
+func printTagTotals(sessions []*punch.Session) {
+	totals := punch.TotalByTag(sessions)
+	if len(totals) == 0 || (len(totals) == 1 && totals[0].Tag == "") {
+		return
+	}
+	fmt.Printf("\nTag, Sessions, Worked\n")
+	for _, total := range totals {
+		tag := "(untagged)"
+		if total.Tag != "" {
+			tag = "#" + total.Tag
+		}
+		fmt.Printf("%s, %d, %s\n", tag, total.Sessions, punch.DurationToStr(total.Duration))
 	}
+}
 
-	rows, e := db.Query(`
-		SELECT * FROM punchcard
-		WHERE project IS ?
-		AND punch > ?
-		ORDER BY punch ASC;
-	`, client, fromStamp)
+// Reports on client, and any of its sub-clients.
+func queryClient(
+	card *punch.Card, format outputFormat, client string, from *time.Time, tags []string) error {
+	reports, e := card.ReportTree(client, *from, tags...)
 	if e != nil {
 		return e
 	}
-	defer rows.Close()
+	var sessions []*punch.Session
+	var strays, open []*punch.CardSchema
+	for _, report := range reports {
+		sessions = append(sessions, report.Sessions...)
+		strays = append(strays, report.Strays...)
+		if report.Open != nil {
+			open = append(open, report.Open)
+		}
+	}
+
+	if format != formatText {
+		for _, stray := range strays {
+			fmt.Fprintf(os.Stderr,
+				"WARNING: stray punch-out at %d (note: '%s')\n",
+				stray.Punch.Unix(), punch.FromNote(stray.Note))
+		}
+		t := newSessionTable()
+		for _, session := range sessions {
+			t.addSession(session.Client, session)
+		}
+		for _, report := range reports {
+			if report.Open != nil {
+				t.addOpenSession(
+					report.Open, card.Clock.Now(), report.OpenTags, report.OpenBreaks)
+			}
+		}
+		return t.write(os.Stdout, format)
+	}
 
 	var limited string
 	if !from.IsZero() {
-		limited = fmt.Sprintf(" from %s", from.Format(format_dateTime))
+		limited = fmt.Sprintf(" from %s", from.Format(punch.FormatDateTime))
 	}
 
-	var numSessions int
-	var total time.Duration
-	fmt.Printf("Sessions on '%s' (in %s)%s:\n", client, getTZContext(), limited)
-	var punches []*CardSchema
-	numRecords := 0
-	for rows.Next() {
-		card, e := scanToCard(rows)
-		if e != nil {
-			return e
-		}
-
-		numRecords++
-		if numRecords == 1 && !card.IsStart {
-			fmt.Printf(
-				"  [ERROR: stray punch-out!] at %s (note: '%s')\n",
-				card.Punch.Unix(), fromNote(card.Note))
-			continue
-		} else if !card.IsStart {
-			session := punches[len(punches)-1].toSession(card)
-			numSessions++
-			total += session.Duration
+	isTree := len(reports) > 1 || (len(reports) == 1 && reports[0].Client != client)
+	var subClients string
+	if isTree {
+		subClients = " & its sub-clients"
+	}
 
+	var stamps []time.Time
+	for _, session := range sessions {
+		stamps = append(stamps, session.StartAt, session.StopAt)
+	}
+	for _, punchIn := range open {
+		stamps = append(stamps, punchIn.Punch)
+	}
+	fmt.Printf(
+		"Sessions on '%s'%s%s (in %s)%s:\n",
+		client, subClients, taggedClause(tags),
+		getTZContext(card.Clock.Now(), stamps...), limited)
+	for _, stray := range strays {
+		fmt.Printf(
+			"  [ERROR: stray punch-out!] at %d (note: '%s')\n",
+			stray.Punch.Unix(), punch.FromNote(stray.Note))
+	}
+	for _, report := range reports {
+		if isTree && len(report.Sessions) > 0 {
+			fmt.Printf("%s:\n", report.Client)
+		}
+		for _, session := range report.Sessions {
 			fmt.Printf("%s\n", session)
 		}
-
-		punches = append(punches, card)
 	}
 
-	if len(punches)%2 == 1 {
-		accumulating := time.Since(punches[len(punches)-1].Punch)
+	var total time.Duration
+	for _, session := range sessions {
+		total += session.Duration
+	}
+	for _, report := range reports {
+		if report.Open == nil {
+			continue
+		}
+		accumulating := punch.SoFar(report.Open, report.OpenBreaks, card.Clock.Now()).Duration
 		total += accumulating
+		var on string
+		if isTree {
+			on = fmt.Sprintf(" on '%s'", report.Client)
+		}
+		working := "& working"
+		if paused := punch.PausedSince(report.OpenBreaks); !paused.IsZero() {
+			working = fmt.Sprintf("but paused since %s", paused.Format(punch.FormatDateTime))
+		}
 		fmt.Printf(
-			"Note: currently punched-in & working; %s so far\n",
-			accumulating)
+			"Note: currently punched-in %s%s; %s so far\n",
+			working, on, accumulating)
 	}
 
-	if len(punches) >= 2 {
-		fmt.Printf("Summary: Worked %s over %d sessions\n", total, numSessions)
+	if len(sessions) > 0 {
+		fmt.Printf("Summary: Worked %s over %d sessions\n", total, len(sessions))
+		if isTree {
+			sessionsOf := make(map[string][]*punch.Session)
+			for _, report := range reports {
+				sessionsOf[report.Client] = report.Sessions
+			}
+			fmt.Printf("\nClient, Sessions, Worked\n")
+			for _, t := range punch.RollUp(sessionsOf) {
+				if punch.IsWithinClient(t.Client, client) {
+					fmt.Printf("%s, %d, %s\n", t.Client, t.Sessions, punch.DurationToStr(t.Duration))
+				}
+			}
+		}
+		printTagTotals(sessions)
 	} else {
 		var fromClause string
 		if !from.IsZero() {
-			fromClause = fmt.Sprintf(" in the past %s", time.Since(*from))
+			fromClause = fmt.Sprintf(" in the past %s", card.Clock.Now().Sub(*from))
 		}
 		whatNotFound := "sessions"
-		if len(punches) == 0 && from.IsZero() {
+		if len(open) == 0 && len(strays) == 0 && from.IsZero() && len(tags) == 0 {
 			whatNotFound = "records" // we found _NOTHING_ and no FROM clause passed
 		}
 		fmt.Printf("Warning: no %s found for this client%s.\n", whatNotFound, fromClause)
@@ -104,171 +180,247 @@ func queryClient(db *sql.DB, client string, from *time.Time) error {
 	return nil
 }
 
-func queryClients(db *sql.DB) error {
-	rows, e := db.Query(`
-		SELECT DISTINCT(project) as project
-		FROM punchcard ORDER BY project ASC;
-	`)
+// Lists clients, or with --tree, every client and sub-client as a tree.
+func queryClients(card *punch.Card, format outputFormat, args []string) error {
+	isTree := false
+	for _, arg := range args {
+		if arg != "--tree" {
+			return fmt.Errorf("unrecognized commandline at '%s'", arg)
+		}
+		isTree = true
+	}
+
+	clients, e := card.Clients()
 	if e != nil {
 		return e
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var client string
-		if e := rows.Scan(&client); e != nil {
-			return e
+	if !isTree {
+		if format != formatText {
+			return clientTable(clients).write(os.Stdout, format)
 		}
-		fmt.Printf("%s\n", client)
+		for _, client := range clients {
+			fmt.Printf("%s\n", client)
+		}
+		return nil
 	}
 
+	trees := punch.ClientTree(clients)
+	if format != formatText {
+		return clientTreeTable(trees).write(os.Stdout, format)
+	}
+	var printNodes func(nodes []*punch.ClientNode, depth int)
+	printNodes = func(nodes []*punch.ClientNode, depth int) {
+		for _, node := range nodes {
+			fmt.Printf("%s%s\n", strings.Repeat("  ", depth), node.Name())
+			printNodes(node.Children, depth+1)
+		}
+	}
+	printNodes(trees, 0)
 	return nil
 }
 
-func countProjects(db *sql.DB) (int, error) {
-	rows, e := db.Query(`SELECT COUNT(DISTINCT project) FROM punchcard;`)
+func queryDump(card *punch.Card, format outputFormat, args []string) error {
+	tags, rest, e := parseTagFlags(args)
 	if e != nil {
-		return 0, e
+		return e
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var count int
-		if e := rows.Scan(&count); e != nil {
-			return 0, e
-		}
-		return count, nil
+	if len(rest) > 0 {
+		return fmt.Errorf("expected just -t TAG flags, but got '%s'", strings.Join(rest, " "))
 	}
 
-	return 0, nil // zero projects found
-	// TODO double check this would result in empty rows.Next()
-}
-
-func queryDump(db *sql.DB) error {
-	rows, e := db.Query(`SELECT * FROM punchcard ORDER BY punch ASC;`)
+	cards, e := card.Cards()
 	if e != nil {
 		return e
 	}
-	defer rows.Close()
-
-	var longestProjectStr float64
+	sessionTags, e := card.AllTags()
+	if e != nil {
+		return e
+	}
+	cards = sessionTags.Filter(cards, tags)
 
-	lastPunchInFor := make(map[string]CardSchema)
-	sessionsFor := make(map[string][]Session)
-	fmt.Printf("Punch [%s], Status, Project, Note\n", getTZContext())
-	for rows.Next() {
-		punch, e := scanToCard(rows)
-		if e != nil {
+	if format != formatText {
+		if e := punchTable(cards, sessionTags).write(os.Stdout, format); e != nil {
 			return e
 		}
-		fmt.Printf(
-			"%s, %3s, %s, %s\n",
-			punch.Punch.Format(format_dateTime),
-			fromStatus(punch.IsStart),
-			punch.Project,
-			fromNote(punch.Note))
+		if len(cards) == 0 {
+			return fmt.Errorf("zero punch-card records found")
+		}
+		return nil
+	}
 
-		longestProjectStr = math.Max(float64(len(punch.Project)), longestProjectStr)
+	var longestProjectStr float64
 
-		if punch.IsStart {
-			lastPunchInFor[punch.Project] = *punch
-		} else {
-			lastPunch := lastPunchInFor[punch.Project]
-			sessionsFor[punch.Project] = append(
-				sessionsFor[punch.Project],
-				*((&lastPunch).toSession(punch)))
-			lastPunchInFor[punch.Project] = emptyCard
+	var stamps []time.Time
+	for _, c := range cards {
+		stamps = append(stamps, c.Punch)
+	}
+	fmt.Printf(
+		"Punch [%s], Status, Project, Note\n", getTZContext(card.Clock.Now(), stamps...))
+	for _, c := range cards {
+		var tagged string
+		if c.IsStart {
+			for _, tag := range sessionTags.Of(c) {
+				tagged += fmt.Sprintf(" #%s", tag)
+			}
 		}
+		fmt.Printf(
+			"%s, %3s, %s, %s%s\n",
+			c.Punch.Format(punch.FormatDateTime),
+			punch.FromStatus(c.IsStart),
+			c.Project,
+			punch.FromNote(c.Note),
+			tagged)
+
+		longestProjectStr = math.Max(float64(len(c.Project)), longestProjectStr)
 	}
 
 	if longestProjectStr == 0 {
 		return fmt.Errorf("zero punch-card records found")
 	}
 
-	// Summarize above dump
-	fmt.Printf("\nProject, Sessions, Status, Worked Time\n")
-	for project, sessions := range sessionsFor {
-		var total time.Duration
-		for _, session := range sessions {
-			total += session.Duration
+	// Summarize above dump, rolling sub-clients' totals up into their clients'
+	var sessions []*punch.Session
+	sessionsOf := make(map[string][]*punch.Session)
+	var working []string
+	sessionBreaks, e := card.AllBreaks()
+	if e != nil {
+		return e
+	}
+	for _, summary := range punch.Summarize(cards) {
+		sessionBreaks.Take(summary.Client, summary.Sessions)
+		if e := card.SplitSessions(summary.Sessions); e != nil {
+			return e
 		}
-
+		sessionTags.Tag(summary.Client, summary.Sessions)
+		sessions = append(sessions, summary.Sessions...)
+		sessionsOf[summary.Client] = summary.Sessions
+		if summary.IsWorking() {
+			working = append(working, summary.Client)
+		}
+	}
+	fmt.Printf("\nProject, Sessions, Status, Worked Time\n")
+	for _, total := range punch.RollUp(sessionsOf, working...) {
 		status := "n/a"
-		last := lastPunchInFor[project]
-		if !last.isEmptyCard() && last.IsStart {
+		if total.IsWorking {
 			status = "WORKING"
 		}
 		fmt.Printf(
 			fmt.Sprintf("%s+%d%s\n", "%", int(longestProjectStr), "s, %4d, %s, %s"),
-			project, len(sessions), status, durationToStr(total))
+			total.Client,
+			total.Sessions,
+			status,
+			punch.DurationToStr(total.Duration))
 	}
+	printTagTotals(sessions)
 
 	return nil
 }
 
-func queryStatus(db *sql.DB) error {
-	rows, e := db.Query(`
-		SELECT * FROM punchcard
-		GROUP BY project
-		ORDER BY punch DESC;
-	`)
+func queryStatus(card *punch.Card, format outputFormat) error {
+	open, e := card.OpenPunches()
 	if e != nil {
 		return e
 	}
-	defer rows.Close()
 
-	isOnClock := false
-	for rows.Next() {
-		punch, e := scanToCard(rows)
+	var unbilled []*punch.Unbilled
+	for _, c := range open {
+		u, e := card.Unbilled(c.Project)
+		if e != nil {
+			return e
+		}
+		unbilled = append(unbilled, u)
+	}
+
+	if format != formatText {
+		tags, e := card.AllTags()
 		if e != nil {
 			return e
 		}
-		if punch.IsStart {
-			isOnClock = true
-			fmt.Printf(
-				"%s: %s so far\n",
-				punch.Project,
-				durationToStr(time.Since(punch.Punch)))
-			// TODO include *total* since-last-payperiod logged, in parenthesis, eg:
-			// "golangpunch: 0:03 so far (37:14:00 since last bill)"
+		if e := statusTable(unbilled, tags).write(os.Stdout, format); e != nil {
+			return e
+		}
+		if len(open) == 0 {
+			return fmt.Errorf("not on the clock")
+		}
+		return nil
+	}
+
+	for _, u := range unbilled {
+		sinceBill := "since last bill"
+		if u.Since.IsZero() {
+			sinceBill = "in all, never billed"
+		}
+		var paused string
+		if since := punch.PausedSince(u.OpenBreaks); !since.IsZero() {
+			paused = fmt.Sprintf(", paused since %s", since.Format(punch.FormatDateTime))
 		}
+		fmt.Printf(
+			"%s: %s so far%s (%s %s)\n",
+			u.Client,
+			punch.DurationToStr(punch.SoFar(u.Open, u.OpenBreaks, card.Clock.Now()).Duration),
+			paused,
+			punch.DurationToStr(u.Total()),
+			sinceBill)
 	}
 
-	if isOnClock {
+	if len(open) > 0 {
 		return nil
 	}
 	return fmt.Errorf("not on the clock")
 }
 
-// TODO figure out how to pass entire `clients` array as-is into var-args
-// db.Exec(), instead of manually building a query string with our own
-// injection-checking
-func hack_queryPaychecksIn(db *sql.DB, clients []string) (*sql.Rows, error) {
-	query := "SELECT * FROM paychecks\n"
-	if len(clients) > 0 {
-		for i, c := range clients {
-			if !isValidClient(c) {
-				return nil, fmt.Errorf("invalid client: '%s'", c)
-			}
-			clients[i] = strings.TrimSpace(c)
+func queryUnbilled(card *punch.Card, format outputFormat, clients []string) error {
+	if len(clients) == 0 {
+		var e error
+		if clients, e = card.Clients(); e != nil {
+			return e
 		}
-		query += fmt.Sprintf(
-			"WHERE project IN ('%s')\n", strings.Join(clients, "', '"))
 	}
-	query += "ORDER BY endclusive ASC;"
 
-	return db.Query(query)
-}
+	var unbilled []*punch.Unbilled
+	for _, client := range clients {
+		client = strings.TrimSpace(client)
+		if !punch.IsValidClient(client) {
+			return fmt.Errorf("invalid CLIENT, '%s'", client)
+		}
+		u, e := card.Unbilled(client)
+		if e != nil {
+			return e
+		}
+		unbilled = append(unbilled, u)
+	}
+
+	if format != formatText {
+		return unbilledTable(unbilled).write(os.Stdout, format)
+	}
 
-func queryBills(db *sql.DB, args []string) error {
-	// TODO(zacsh) make this a JOIN and fetch all the punches within a
-	// {end,start}clusive, and include amount of time worked in this report
-	//   SELECT *
-	//   FROM paychecks as p
-	//   JOIN punchcard as c
-	//   ON p.project=c.project
-	//   AND p.startclusive < c.punch
-	//   AND p.endclusive > c.punch;
+	var stamps []time.Time
+	for _, u := range unbilled {
+		if !u.Since.IsZero() {
+			stamps = append(stamps, u.Since)
+		}
+	}
+	fmt.Printf(
+		"Client, Last Billed (%s), Sessions, Status, Unbilled\n",
+		getTZContext(card.Clock.Now(), stamps...))
+	for _, u := range unbilled {
+		since := "n/a"
+		if !u.Since.IsZero() {
+			since = u.Since.Format(punch.FormatDateTime)
+		}
+		status := "n/a"
+		if u.Open != nil {
+			status = "WORKING"
+		}
+		fmt.Printf(
+			"%s, %s, %d, %s, %s\n",
+			u.Client, since, len(u.Sessions), status, punch.DurationToStr(u.Total()))
+	}
+	return nil
+}
 
+func queryBills(card *punch.Card, format outputFormat, args []string) error {
 	isForLast := false
 	clients := args
 	if len(args) > 0 && strings.TrimSpace(args[0]) == "-last" {
@@ -280,19 +432,35 @@ func queryBills(db *sql.DB, args []string) error {
 		return fmt.Errorf("exactly one CLIENT required with -last option")
 	}
 
-	rows, e := hack_queryPaychecksIn(db, clients)
+	bills, e := card.Bills(clients...)
 	if e != nil {
 		return e
 	}
-	defer rows.Close()
 
-	var bills []*BillSchema
-	for rows.Next() {
-		b, e := scanToBill(rows)
+	if isForLast && len(bills) > 0 {
+		bills = bills[len(bills)-1:]
+	}
+
+	if format != formatText {
+		reports, e := card.ReportBills(bills)
 		if e != nil {
 			return e
 		}
-		bills = append(bills, b)
+		for _, r := range reports {
+			if r.Open != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n", openInBillWarning(r))
+			}
+			if r.Amount != nil && r.Unpriced > 0 {
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n", unpricedInBillWarning(r))
+			}
+		}
+		if e := billTable(reports).write(os.Stdout, format); e != nil {
+			return e
+		}
+		if len(bills) == 0 {
+			return fmt.Errorf("no pay-periods closed, yet")
+		}
+		return nil
 	}
 
 	if len(bills) == 0 {
@@ -300,29 +468,383 @@ func queryBills(db *sql.DB, args []string) error {
 	}
 
 	if isForLast {
-		lastBill := bills[len(bills)-1]
+		lastBill := bills[0]
 		fmt.Printf(
 			"%d\t%s\n",
 			lastBill.Endclusive.Unix(),
-			lastBill.Endclusive.Format(format_dateTime))
+			lastBill.Endclusive.Format(punch.FormatDateTime))
+		return nil
+	}
+
+	reports, e := card.ReportBills(bills)
+	if e != nil {
+		return e
+	}
+	var stamps []time.Time
+	for _, b := range bills {
+		stamps = append(stamps, b.Startclusive, b.Endclusive)
+	}
+	fmt.Printf(
+		"Billed, From (%s), To, Sessions, Worked, Billable, Amount, Note\n",
+		getTZContext(card.Clock.Now(), stamps...))
+	for _, r := range reports {
+		billable, amount := "n/a", "n/a"
+		if r.Amount != nil {
+			billable = punch.DurationToStr(r.Billable)
+			amount = r.Amount.String()
+		}
+		fmt.Printf(
+			"%s, %s, %s, %d, %s, %s, %s, %s\n",
+			r.Bill.Project,
+			r.Bill.Startclusive.Format(punch.FormatDateTime),
+			r.Bill.Endclusive.Format(punch.FormatDateTime),
+			len(r.Sessions),
+			punch.DurationToStr(r.Total()),
+			billable,
+			amount,
+			punch.FromNote(r.Bill.Note))
+		if r.Open != nil {
+			fmt.Printf("  Warning: %s\n", openInBillWarning(r))
+		}
+		if r.Amount != nil && r.Unpriced > 0 {
+			fmt.Printf("  Warning: %s\n", unpricedInBillWarning(r))
+		}
+	}
+	return nil
+}
+
+func unpricedInBillWarning(r *punch.BillReport) string {
+	return fmt.Sprintf(
+		"%s of '%s' pay period ending %s predates any rate; none of it is priced",
+		punch.DurationToStr(r.Unpriced),
+		r.Bill.Project,
+		r.Bill.Endclusive.Format(punch.FormatDateTime))
+}
+
+// FROM, TO, grouping, TAGs, error
+func parseRangeArgs(
+	args []string, now time.Time) (time.Time, time.Time, punch.Grouping, []string, error) {
+	var from, to time.Time
+	grouping := punch.GroupByDay
+	tags, args, e := parseTagFlags(args)
+	if e != nil {
+		return from, to, grouping, nil, e
+	}
+	var stamps []string
+	for i := 0; i < len(args); i++ {
+		if args[i] != "-g" {
+			stamps = append(stamps, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return from, to, grouping, nil, errors.New("-g passed, but no GROUPING found")
+		}
+		if grouping, e = punch.ParseGrouping(strings.TrimSpace(args[i+1])); e != nil {
+			return from, to, grouping, nil, fmt.Errorf("bad GROUPING: %s", e)
+		}
+		i++ // skip GROUPING
+	}
+
+	if len(stamps) < 1 || len(stamps) > 2 {
+		return from, to, grouping, nil, fmt.Errorf(
+			"expected FROM and optionally TO stamps, but got %d args: %q", len(stamps), stamps)
+	}
+	from, e = parseStampCommand(stamps[0], now)
+	if e != nil {
+		return from, to, grouping, nil, fmt.Errorf("bad FROM timestamp, '%s': %s", stamps[0], e)
+	}
+	to = now
+	if len(stamps) > 1 {
+		if to, e = parseStampCommand(stamps[1], now); e != nil {
+			return from, to, grouping, nil, fmt.Errorf("bad TO timestamp, '%s': %s", stamps[1], e)
+		}
+	}
+	return from, to, grouping, tags, nil
+}
+
+func queryRange(card *punch.Card, format outputFormat, args []string) error {
+	from, to, grouping, tags, e := parseRangeArgs(args, card.Clock.Now())
+	if e != nil {
+		return e
+	}
+	report, e := card.ReportRange(from, to, grouping, tags...)
+	if e != nil {
+		return e
+	}
+
+	if format != formatText {
+		for _, open := range report.Open {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", openInRangeWarning(open))
+		}
+		return rangeTable(report).write(os.Stdout, format)
+	}
+
+	fmt.Printf(
+		"Sessions%s from %s to %s (in %s), by %s:\n",
+		taggedClause(tags),
+		from.Format(punch.FormatDateTime),
+		to.Format(punch.FormatDateTime),
+		getTZContext(card.Clock.Now(), from, to),
+		grouping)
+	for _, open := range report.Open {
+		fmt.Printf("  Warning: %s\n", openInRangeWarning(open))
+	}
+	if len(report.Groups) == 0 {
+		fmt.Printf("Warning: no sessions found in this range.\n")
+		return nil
+	}
+
+	fmt.Printf("Client, %s, Sessions, Worked\n", strings.Title(string(grouping)))
+	var clients []string // in order of report.Groups
+	var all []*punch.Session
+	worked := make(map[string]time.Duration)
+	for _, g := range report.Groups {
+		all = append(all, g.Sessions...)
+		fmt.Printf(
+			"%s, %s, %d, %s\n",
+			g.Client,
+			grouping.Label(g.Start),
+			g.SessionCount(),
+			punch.DurationToStr(g.Total()))
+
+		if _, ok := worked[g.Client]; !ok {
+			clients = append(clients, g.Client)
+		}
+		worked[g.Client] += g.Total()
+	}
+
+	fmt.Printf("\nClient, Sessions, Worked\n")
+	for _, client := range clients {
+		fmt.Printf(
+			"%s, %d, %s\n",
+			client, report.SessionCount(client), punch.DurationToStr(worked[client]))
+	}
+	fmt.Printf("Summary: Worked %s over %d sessions\n",
+		report.Total(), report.SessionCount("" /*client*/))
+	printTagTotals(all)
+	return nil
+}
+
+func openInRangeWarning(open *punch.CardSchema) string {
+	return fmt.Sprintf(
+		"'%s' session still open since %s overlaps range; none of it is counted",
+		open.Project,
+		open.Punch.Format(punch.FormatDateTime))
+}
+
+// Reads `--week DATE` or `--month YYYY-MM`, returning a day within the week or
+// month (defaulting to now's week), and whether it's a month.
+func parseTimesheetArgs(args []string, now time.Time) (time.Time, bool, error) {
+	if len(args) == 0 {
+		return now, false, nil
+	}
+	if len(args) != 2 {
+		return now, false, fmt.Errorf(
+			"expected just --week DATE or --month YYYY-MM, but got %q", args)
+	}
+
+	switch args[0] {
+	case "--week":
+		day, e := parseStampCommand(args[1], now)
+		if e != nil {
+			return now, false, fmt.Errorf("bad --week DATE, '%s': %s", args[1], e)
+		}
+		return day, false, nil
+	case "--month":
+		month, e := time.ParseInLocation("2006-01", strings.TrimSpace(args[1]), now.Location())
+		if e != nil {
+			return now, true, fmt.Errorf("expected --month YYYY-MM, but got '%s'", args[1])
+		}
+		return month, true, nil
+	}
+	return now, false, fmt.Errorf("unrecognized commandline at '%s'", args)
+}
+
+func queryTimesheet(card *punch.Card, format outputFormat, args []string) error {
+	day, isMonth, e := parseTimesheetArgs(args, card.Clock.Now())
+	if e != nil {
+		return e
+	}
+	var sheet *punch.Timesheet
+	if isMonth {
+		sheet, e = card.MonthTimesheet(day)
 	} else {
-		fmt.Printf("Billed, From (%s), To, Note\n", getTZContext())
-		for _, b := range bills {
-			fmt.Println(b.String(false /*showTimezone*/))
+		sheet, e = card.WeekTimesheet(day)
+	}
+	if e != nil {
+		return e
+	}
+
+	if format != formatText {
+		for _, open := range sheet.Open {
+			fmt.Fprintf(os.Stderr, "WARNING: %s\n", openInRangeWarning(open))
+		}
+		return timesheetTable(sheet).write(os.Stdout, format)
+	}
+
+	period, title := "week", fmt.Sprintf("week of %s", sheet.From.Format("2006-01-02"))
+	if isMonth {
+		period, title = "month", sheet.From.Format("January 2006")
+	}
+	fmt.Printf(
+		"Timesheet for %s, in hours (%s):\n",
+		title, getTZContext(card.Clock.Now(), sheet.From, sheet.To))
+	for _, open := range sheet.Open {
+		fmt.Printf("  Warning: %s\n", openInRangeWarning(open))
+	}
+	if len(sheet.Rows) == 0 {
+		fmt.Printf("Warning: no sessions found in this %s.\n", period)
+		return nil
+	}
+
+	clientWidth := len("Total")
+	for _, row := range sheet.Rows {
+		if len(row.Client) > clientWidth {
+			clientWidth = len(row.Client)
+		}
+	}
+
+	header := []string{fmt.Sprintf("%-*s", clientWidth, "Client")}
+	for _, column := range sheet.Columns {
+		label := column.Format("Mon 01-02")
+		if isMonth {
+			label = column.Format("Wk 01-02")
+		}
+		header = append(header, fmt.Sprintf("%9s", label))
+	}
+	fmt.Println(strings.Join(append(header, fmt.Sprintf("%9s", "Total")), " "))
+
+	hours := func(d time.Duration) string {
+		if d == 0 {
+			return fmt.Sprintf("%9s", "-")
+		}
+		return fmt.Sprintf("%9.2f", d.Hours())
+	}
+	var total time.Duration
+	for _, row := range sheet.Rows {
+		line := []string{fmt.Sprintf("%-*s", clientWidth, row.Client)}
+		for _, cell := range row.Cells {
+			line = append(line, hours(cell))
+		}
+		fmt.Println(strings.Join(append(line, hours(row.Total())), " "))
+		total += row.Total()
+	}
+	footer := []string{fmt.Sprintf("%-*s", clientWidth, "Total")}
+	for i := range sheet.Columns {
+		footer = append(footer, hours(sheet.ColumnTotal(i)))
+	}
+	fmt.Println(strings.Join(append(footer, hours(total)), " "))
+	return nil
+}
+
+func queryRates(card *punch.Card, format outputFormat, clients []string) error {
+	var rates []*punch.RateSchema
+	if len(clients) == 0 {
+		var e error
+		if rates, e = card.AllRates(); e != nil {
+			return e
+		}
+	}
+	for _, client := range clients {
+		client = strings.TrimSpace(client)
+		if !punch.IsValidClient(client) {
+			return fmt.Errorf("invalid CLIENT, '%s'", client)
+		}
+		clientRates, e := card.Rates(client)
+		if e != nil {
+			return e
+		}
+		rates = append(rates, clientRates...)
+	}
+
+	if format != formatText {
+		return rateTable(rates).write(os.Stdout, format)
+	}
+
+	if len(rates) == 0 {
+		return fmt.Errorf("no rates set, yet")
+	}
+	var stamps []time.Time
+	for _, r := range rates {
+		stamps = append(stamps, r.Effective)
+	}
+	fmt.Printf(
+		"Client, Effective (%s), Hourly, Rounding\n", getTZContext(card.Clock.Now(), stamps...))
+	for _, r := range rates {
+		fmt.Printf(
+			"%s, %s, %s, %s\n",
+			r.Project,
+			r.Effective.Format(punch.FormatDateTime),
+			r.Hourly,
+			r.RoundingString())
+	}
+	return nil
+}
+
+func queryOverlaps(card *punch.Card, format outputFormat, args []string) error {
+	var from time.Time
+	if len(args) > 0 {
+		var e error
+		if from, e = parseStampCommand(strings.Join(args, " "), card.Clock.Now()); e != nil {
+			return fmt.Errorf("parsing FROM_STAMP: %s", e)
 		}
 	}
+	overlaps, e := card.Overlaps(from)
+	if e != nil {
+		return e
+	}
+
+	if format != formatText {
+		return overlapTable(overlaps).write(os.Stdout, format)
+	}
 
+	if len(overlaps) == 0 {
+		fmt.Printf("No sessions overlap (concurrency: %s)\n", card.Concurrency)
+		return nil
+	}
+	var stamps []time.Time
+	for _, o := range overlaps {
+		stamps = append(stamps, o.StartAt, o.StopAt)
+	}
+	fmt.Printf("Overlapping sessions (in %s; concurrency: %s):\n",
+		getTZContext(card.Clock.Now(), stamps...), card.Concurrency)
+	var doubled time.Duration
+	for _, o := range overlaps {
+		clients := make([]string, len(o.Clients))
+		for i, client := range o.Clients {
+			clients[i] = fmt.Sprintf("'%s'", client)
+		}
+		fmt.Printf(
+			"%s from %s to %s: %s\n",
+			punch.DurationToStr(o.Duration()),
+			o.StartAt.Format(punch.FormatDateTime),
+			o.StopAt.Format(punch.FormatDateTime),
+			strings.Join(clients, " & "))
+		doubled += o.DoubleCounted()
+	}
+	fmt.Printf("Summary: %s counted more than once, over %d overlaps\n",
+		punch.DurationToStr(doubled), len(overlaps))
 	return nil
 }
 
+func openInBillWarning(r *punch.BillReport) string {
+	return fmt.Sprintf(
+		"'%s' session still open since %s overlaps pay period ending %s; none of it is counted",
+		r.Open.Project,
+		r.Open.Punch.Format(punch.FormatDateTime),
+		r.Bill.Endclusive.Format(punch.FormatDateTime))
+}
+
 // Subcommand "query" driver; has it own subcommands `args` which drive its
 // response
-func subCmdQuery(dbInfo os.FileInfo, dbPath string, args []string) error {
-	db, e := sql.Open("sqlite3", dbPath)
+func subCmdQuery(
+	clock punch.Clock, format outputFormat,
+	dbInfo os.FileInfo, dbPath string, args []string) error {
+	card, e := openCard(clock, dbPath)
 	if e != nil {
 		return fmt.Errorf("punch cards: %s", e)
 	}
-	defer db.Close()
+	defer card.Close()
 
 	subCmd := "dump"
 	if len(args) > 0 {
@@ -335,30 +857,45 @@ func subCmdQuery(dbInfo os.FileInfo, dbPath string, args []string) error {
 		if len(args) > 1 {
 			queryBillArgs = args[1:]
 		}
-		return queryBills(db, queryBillArgs)
+		return queryBills(card, format, queryBillArgs)
 	case "status":
-		return queryStatus(db)
+		return queryStatus(card, format)
+	case "unbilled":
+		return queryUnbilled(card, format, args[1:])
+	case "timesheet":
+		return queryTimesheet(card, format, args[1:])
+	case "range":
+		return queryRange(card, format, args[1:])
+	case "rates":
+		return queryRates(card, format, args[1:])
 	case "list":
-		return queryClients(db)
+		return queryClients(card, format, args[1:])
+	case "overlaps":
+		return queryOverlaps(card, format, args[1:])
 	case "report":
 		if len(args) < 2 || len(args[1]) < 1 {
 			return errors.New("usage error: need client name to report on")
 		}
+		tags, rest, e := parseTagFlags(args[2:])
+		if e != nil {
+			return e
+		}
 		var from time.Time
-		if len(args) > 2 {
-			fromStamp, e := strconv.ParseInt(args[2], 10, 64)
+		if len(rest) > 0 {
+			from, e = parseStampCommand(strings.Join(rest, " "), clock.Now())
 			if e != nil {
 				return fmt.Errorf("parsing FROM_STAMP: %s", e)
 			}
-			from = time.Unix(fromStamp, 0 /*nanoseconds*/)
 		}
-		queryClient(db, args[1], &from)
+		return queryClient(card, format, args[1], &from, tags)
 	case "dump":
-		return queryDump(db)
+		var dumpArgs []string
+		if len(args) > 1 {
+			dumpArgs = args[1:]
+		}
+		return queryDump(card, format, dumpArgs)
 	default:
 		return fmt.Errorf(
 			"usage error: unrecognized query cmd, '%s'", subCmd)
 	}
-
-	return nil
 }
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"github.com/jzacsh/punch"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRangeArgs(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args     []string
+		from, to time.Time
+		grouping punch.Grouping
+		tags     string
+	}{
+		{[]string{"@1491600000"}, time.Unix(1491600000, 0), now, punch.GroupByDay, ""},
+		{[]string{"@1491600000", "-1h"}, time.Unix(1491600000, 0), now.Add(-time.Hour), punch.GroupByDay, ""},
+		{[]string{"-g", "week", "@1491600000"}, time.Unix(1491600000, 0), now, punch.GroupByWeek, ""},
+		{[]string{"@1491600000", "@1491700000", "-g", "month"}, time.Unix(1491600000, 0), time.Unix(1491700000, 0), punch.GroupByMonth, ""},
+		{[]string{"-t", "meeting", "@1491600000", "-t", "billable"}, time.Unix(1491600000, 0), now, punch.GroupByDay, "meeting,billable"},
+	} {
+		from, to, grouping, tags, e := parseRangeArgs(tt.args, now)
+		if e != nil {
+			t.Errorf("parseRangeArgs(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if !from.Equal(tt.from) || !to.Equal(tt.to) || grouping != tt.grouping ||
+			strings.Join(tags, ",") != tt.tags {
+			t.Errorf("parseRangeArgs(%q): got (%s, %s, %s, %q)", tt.args, from, to, grouping, tags)
+		}
+	}
+
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{nil, "expected FROM and optionally TO"},
+		{[]string{"-1h", "now", "later"}, "expected FROM and optionally TO"},
+		{[]string{"whenever"}, "bad FROM timestamp"},
+		{[]string{"-1h", "whenever"}, "bad TO timestamp"},
+		{[]string{"-1h", "-g"}, "no GROUPING found"},
+		{[]string{"-1h", "-g", "year"}, "bad GROUPING"},
+		{[]string{"-1h", "-t"}, "no TAG found"},
+		{[]string{"-1h", "-t", "not a tag"}, "invalid TAG"},
+	} {
+		_, _, _, _, e := parseRangeArgs(tt.args, now)
+		if e == nil || !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf("parseRangeArgs(%q): expected error containing '%s', got: %v",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestParseTimesheetArgs(t *testing.T) {
+	now := time.Unix(1491970000, 0).UTC()
+	for _, tt := range []struct {
+		args    []string
+		day     time.Time
+		isMonth bool
+	}{
+		{nil, now, false},
+		{[]string{"--week", "@1491600000"}, time.Unix(1491600000, 0), false},
+		{[]string{"--month", "2017-03"}, time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC), true},
+	} {
+		day, isMonth, e := parseTimesheetArgs(tt.args, now)
+		if e != nil {
+			t.Errorf("parseTimesheetArgs(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if !day.Equal(tt.day) || isMonth != tt.isMonth {
+			t.Errorf("parseTimesheetArgs(%q): got (%s, %t)", tt.args, day, isMonth)
+		}
+	}
+
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{[]string{"--week"}, "expected just --week DATE or --month YYYY-MM"},
+		{[]string{"--week", "whenever"}, "bad --week DATE"},
+		{[]string{"--month", "March"}, "expected --month YYYY-MM"},
+		{[]string{"--year", "2017"}, "unrecognized commandline"},
+	} {
+		_, _, e := parseTimesheetArgs(tt.args, now)
+		if e == nil || !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf("parseTimesheetArgs(%q): expected error containing '%s', got: %v",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestQueryE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "query_sample",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step(),
+				step("q"),
+				step("q", "list"),
+				step("q", "report", "golangpunch"),
+				step("q", "report", "spaceship", "2017-04-11"),
+				step("q", "report", "nobody"),
+				step("q", "report", "nobody", "-1h"),
+				step("q", "report"),
+				step("q", "bills"),
+				step("q", "bills", "golangpunch"),
+				step("q", "bills", "-last", "golangpunch"),
+				step("q", "bills", "-last"),
+				step("q", "bills", "bad client"),
+				step("q", "unbilled"),
+				step("q", "unbilled", "spaceship", "nobody"),
+				step("q", "unbilled", "bad client"),
+				step("q", "frobnicate"),
+			},
+		},
+		{
+			name:    "query_overlaps",
+			fixture: twoOpenFixture,
+			steps: []e2eStep{
+				step("q", "overlaps"),
+				step("--format", "csv", "q", "overlaps"),
+				{now: sampleNow.Add(time.Hour), args: []string{"p", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"p", "spaceship"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "overlaps", "-1h"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "overlaps", "@1491970000"}},
+			},
+		},
+		{
+			name:    "query_concurrency_split",
+			fixture: twoOpenFixture,
+			config:  "testdata/config_split.json",
+			steps: []e2eStep{
+				{now: sampleNow.Add(time.Hour), args: []string{"p", "golangpunch"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"p", "spaceship"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "overlaps", "@1491970000"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "report", "golangpunch", "-3h"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "report", "spaceship", "-3h"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "range", "-3h"}},
+				{now: sampleNow.Add(time.Hour * 2), args: []string{"q", "unbilled"}},
+			},
+		},
+		{
+			name:    "query_sub_clients",
+			fixture: emptyFixture,
+			steps: []e2eStep{
+				step("p", "acme/api/auth"),
+				{now: sampleNow.Add(time.Hour), args: []string{"p"}},
+				{now: sampleNow.Add(time.Hour), args: []string{"p", "acme/web"}},
+				{now: sampleNow.Add(time.Hour * 3), args: []string{"p"}},
+				{now: sampleNow.Add(time.Hour * 3), args: []string{"p", "acme"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"p"}},
+				{now: sampleNow.Add(time.Hour * 4), args: []string{"p", "acme/api"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"p", "acme/api/"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"q", "list"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"q", "list", "--tree"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"--format", "csv", "q", "list", "--tree"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"q", "report", "acme"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"q", "report", "acme/api"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"q", "report", "acme/web"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"q", "dump"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"bill", "acme/web", "-y"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"bill", "acme", "-y", "-f", "@1491970000"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"bill", "acme", "-y", "-f", "@1491980800"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"q", "unbilled", "acme", "acme/web"}},
+				{now: sampleNow.Add(time.Hour * 5), args: []string{"q", "bills"}},
+			},
+		},
+		{
+			name:    "query_range",
+			fixture: openFixture,
+			steps: []e2eStep{
+				step("q", "range", "2017-04-09", "2017-04-12"),
+				step("q", "range", "2017-04-09", "-g", "week"),
+				step("q", "range", "2017-04-01", "-g", "month"),
+				step("--format", "csv", "q", "range", "2017-04-09", "2017-04-12"),
+				step("q", "range", "2017-04-10", "2017-04-11"),
+				step("q", "range", "2017-04-12", "2017-04-09"),
+				step("q", "range"),
+			},
+		},
+		{
+			name:    "query_timesheet",
+			fixture: openFixture,
+			steps: []e2eStep{
+				step("q", "timesheet"),
+				step("q", "timesheet", "--week", "2017-04-08"),
+				step("q", "timesheet", "--month", "2017-04"),
+				step("--format", "tsv", "q", "timesheet"),
+				step("q", "timesheet", "--month", "2017-03"),
+				step("q", "timesheet", "--month", "April"),
+			},
+		},
+		{
+			name:    "query_one_open",
+			fixture: openFixture,
+			steps: []e2eStep{
+				step(),
+				{now: sampleNow.Add(time.Hour * 30), args: []string{"q", "status"}},
+				step("q", "unbilled"),
+				step("q", "report", "golangpunch", "2017-04-12"),
+				step("q", "dump"),
+			},
+		},
+		{
+			name:    "query_two_open",
+			fixture: twoOpenFixture,
+			steps: []e2eStep{
+				step(),
+				step("q", "report", "spaceship", "2017-04-12"),
+				step("q", "dump"),
+			},
+		},
+		{
+			name:    "query_stray_punch_out",
+			fixture: strayFixture,
+			steps: []e2eStep{
+				step("q", "report", "strays"),
+				step("q", "list"),
+				step("q", "dump"),
+			},
+		},
+		{
+			name:    "query_empty_card",
+			fixture: emptyFixture,
+			steps: []e2eStep{
+				step(),
+				step("q", "list"),
+				step("q", "dump"),
+				step("q", "report", "acme"),
+				step("q", "bills"),
+			},
+		},
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jzacsh/punch"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parses rounding like "15", "15/session" or "30/period"
+func parseRounding(arg string) (time.Duration, bool, error) {
+	minutes := strings.TrimSpace(arg)
+	isPerSession := false
+	if i := strings.Index(minutes, "/"); i >= 0 {
+		switch per := minutes[i+1:]; per {
+		case "session":
+			isPerSession = true
+		case "period":
+		default:
+			return 0, false, fmt.Errorf(
+				"expected rounding per 'session' or 'period', but got '%s'", per)
+		}
+		minutes = minutes[:i]
+	}
+
+	switch minutes {
+	case "0", "6", "15", "30":
+		m, _ := strconv.Atoi(minutes)
+		return time.Duration(m) * time.Minute, isPerSession, nil
+	}
+	return 0, false, fmt.Errorf(
+		"expected rounding to 6, 15 or 30 minutes (or 0, for none), but got '%s'", minutes)
+}
+
+// isDryRun, rate (whose Effective is zero, if not passed), error
+func parseRateCli(args []string, now time.Time) (bool, *punch.RateSchema, error) {
+	if len(args) < 3 {
+		return false, nil, errors.New("CLIENT, HOURLY and CURRENCY are required")
+	}
+
+	rate := &punch.RateSchema{Project: strings.TrimSpace(args[0])}
+	if !punch.IsValidClient(rate.Project) {
+		return false, nil, fmt.Errorf("invalid CLIENT: '%s'", rate.Project)
+	}
+
+	var e error
+	if rate.Hourly, e = punch.ParseMoney(args[1], args[2]); e != nil {
+		return false, nil, fmt.Errorf("bad HOURLY rate: %s", e)
+	}
+
+	isDryRun := false
+	for i := 3; i < len(args); i++ {
+		switch args[i] {
+		case "-d":
+			isDryRun = true
+		case "-f":
+			if i+1 >= len(args) {
+				return isDryRun, nil, errors.New("-f passed, but no EFFECTIVE stamp found")
+			}
+			if rate.Effective, e = parseStampCommand(args[i+1], now); e != nil {
+				return isDryRun, nil, fmt.Errorf(
+					"bad EFFECTIVE timestamp, '%s': %s", args[i+1], e)
+			}
+			i++ // skip EFFECTIVE stamp
+		case "-r":
+			if i+1 >= len(args) {
+				return isDryRun, nil, errors.New("-r passed, but no ROUNDING found")
+			}
+			if rate.Round, rate.PerSession, e = parseRounding(args[i+1]); e != nil {
+				return isDryRun, nil, e
+			}
+			i++ // skip ROUNDING
+		default:
+			return isDryRun, nil, fmt.Errorf("unrecognized commandline at '%s'", args[i:])
+		}
+	}
+	return isDryRun, rate, nil
+}
+
+func subCmdRate(clock punch.Clock, dbPath string, args []string) error {
+	isDryRun, rate, e := parseRateCli(args, clock.Now())
+	if e != nil {
+		return fmt.Errorf("parse args: %s", e)
+	}
+
+	card, e := openCard(clock, dbPath)
+	if e != nil {
+		return fmt.Errorf("rate sql: %s", e)
+	}
+	defer card.Close()
+
+	return card.Transact(func() error { return rateCard(clock, card, rate, isDryRun) })
+}
+
+// Sets rate on card, within one transaction, defaulting its effective date by
+// the client's existing rates.
+func rateCard(clock punch.Clock, card *punch.Card, rate *punch.RateSchema, isDryRun bool) error {
+	if rate.Effective.IsZero() {
+		// A client's first rate prices all its work to date; later rates only
+		// price work from now on.
+		existing, e := card.Rates(rate.Project)
+		if e != nil {
+			return e
+		}
+		rate.Effective = time.Unix(0, 0)
+		if len(existing) > 0 {
+			rate.Effective = clock.Now()
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, `    Will set rate for '%s':
+      %s/hour, rounding %s
+      effective '%s'
+`,
+		rate.Project,
+		rate.Hourly,
+		rate.RoundingString(),
+		rate.Effective.Format(punch.FormatDateTime))
+
+	if isDryRun {
+		fmt.Fprintf(os.Stderr, "\n[-d]ry-run mode; NOT writing any changes\n")
+		return nil
+	}
+
+	if e := card.SetRate(rate); e != nil {
+		return e
+	}
+	fmt.Fprintf(os.Stderr, "Done.\n")
+	return nil
+}
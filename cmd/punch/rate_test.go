@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateCli(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args         []string
+		isDryRun     bool
+		cents        int64
+		effective    time.Time
+		round        time.Duration
+		isPerSession bool
+	}{
+		{[]string{"acme", "85", "usd"}, false, 8500, time.Time{}, 0, false},
+		{[]string{"acme", "85.5", "USD", "-d"}, true, 8550, time.Time{}, 0, false},
+		{[]string{"acme", "85", "USD", "-f", "@1491960000"}, false, 8500, time.Unix(1491960000, 0), 0, false},
+		{[]string{"acme", "85", "USD", "-r", "15"}, false, 8500, time.Time{}, time.Minute * 15, false},
+		{[]string{"acme", "85", "USD", "-r", "6/session"}, false, 8500, time.Time{}, time.Minute * 6, true},
+		{[]string{"acme", "85", "USD", "-r", "30/period", "-f", "-1h"}, false, 8500, now.Add(-time.Hour), time.Minute * 30, false},
+	} {
+		isDryRun, rate, e := parseRateCli(tt.args, now)
+		if e != nil {
+			t.Errorf("parseRateCli(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if isDryRun != tt.isDryRun ||
+			rate.Project != "acme" ||
+			rate.Hourly.Cents != tt.cents ||
+			rate.Hourly.Currency != "USD" ||
+			!rate.Effective.Equal(tt.effective) ||
+			rate.Round != tt.round ||
+			rate.PerSession != tt.isPerSession {
+			t.Errorf("parseRateCli(%q): got dry-run %t and %+v", tt.args, isDryRun, *rate)
+		}
+	}
+}
+
+func TestParseRateCliErrors(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{nil, "CLIENT, HOURLY and CURRENCY are required"},
+		{[]string{"acme", "85"}, "CLIENT, HOURLY and CURRENCY are required"},
+		{[]string{"bad client", "85", "USD"}, "invalid CLIENT"},
+		{[]string{"acme", "lots", "USD"}, "bad HOURLY rate"},
+		{[]string{"acme", "85", "dollars"}, "three-letter currency code"},
+		{[]string{"acme", "85", "USD", "-f"}, "no EFFECTIVE stamp found"},
+		{[]string{"acme", "85", "USD", "-f", "whenever"}, "bad EFFECTIVE timestamp"},
+		{[]string{"acme", "85", "USD", "-r"}, "no ROUNDING found"},
+		{[]string{"acme", "85", "USD", "-r", "10"}, "6, 15 or 30 minutes"},
+		{[]string{"acme", "85", "USD", "-r", "15/day"}, "per 'session' or 'period'"},
+		{[]string{"acme", "85", "USD", "-x"}, "unrecognized commandline"},
+	} {
+		_, _, e := parseRateCli(tt.args, now)
+		if e == nil {
+			t.Errorf("parseRateCli(%q): expected error, got none", tt.args)
+			continue
+		}
+		if !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf(
+				"parseRateCli(%q): expected error containing '%s', got: %s",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestRateE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "rate_prices_bills",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("q", "rates"),
+				step("rate", "spaceship", "80", "USD", "-r", "15/session", "-d"),
+				step("rate", "spaceship", "80", "USD", "-r", "15/session"),
+				// Only prices sessions from the 11th on
+				step("rate", "golangpunch", "60.50", "EUR", "-f", "2017-04-11"),
+				step("rate", "golangpunch", "100", "EUR", "-r", "30"),
+				step("q", "rates"),
+				step("--format", "json", "q", "rates", "golangpunch"),
+				step("q", "bills"),
+				step("--format", "csv", "q", "bills", "golangpunch"),
+				step("bill", "spaceship", "-d"),
+			},
+		},
+		{
+			name:    "rate_errors",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("rate", "spaceship", "80"),
+				step("rate", "spaceship", "80", "USD", "-r", "10"),
+				step("rate", "spaceship", "80", "USD", "-f", "2017-04-11"),
+				step("rate", "spaceship", "80", "EUR", "-f", "2017-04-11 13:08"),
+				step("q", "bills", "spaceship"),
+				step("rate", "spaceship", "80", "USD", "-f", "2017-04-11 13:08"),
+				step("q", "bills", "spaceship"),
+				step("q", "rates", "bad client"),
+			},
+		},
+	})
+}
@@ -1,23 +1,25 @@
 package main
 
 import (
-	"database/sql"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/jzacsh/punch"
 	"os"
+	"strings"
 	"time"
 )
 
 type SeekCmd struct {
+	Client    string
 	SeekTo    time.Time
 	Faulty    time.Time
 	StillOpen time.Time
 	IsDryRun  bool
+	IsYes     bool // skips confirmation
 }
 
 func (s *SeekCmd) isClose() bool { return !s.StillOpen.IsZero() }
 
-func parseSeekCmd(args []string) (*SeekCmd, error) {
+func parseSeekCmd(args []string, now time.Time) (*SeekCmd, error) {
 	cmd := &SeekCmd{}
 	if len(args) < 2 {
 		return nil, fmt.Errorf(
@@ -29,27 +31,43 @@ func parseSeekCmd(args []string) (*SeekCmd, error) {
 		switch args[i] {
 		case "-d":
 			cmd.IsDryRun = true
+		case "-y", "-q":
+			cmd.IsYes = true
 		case "-c":
 			i++ // skip to next arg
-			stamp, e := parseStampCommand(args[i])
+			if i >= len(args) {
+				return nil, fmt.Errorf("-c passed, but no STILL_OPEN found")
+			}
+			stamp, e := parseStampCommand(args[i], now)
 			if e != nil {
 				return nil, fmt.Errorf("STILL_OPEN: %s", e)
 			}
 			cmd.StillOpen = stamp
+		case "--client":
+			i++ // skip to next arg
+			if i >= len(args) {
+				return nil, fmt.Errorf("--client passed, but no CLIENT found")
+			}
+			cmd.Client = strings.TrimSpace(args[i])
+			if !punch.IsValidClient(cmd.Client) {
+				return nil, fmt.Errorf("invalid CLIENT, '%s'", cmd.Client)
+			}
 		default:
 			// we're processing a positional argument, a timestamp
 			if cmd.SeekTo.IsZero() {
-				stamp, e := parseStampCommand(args[i])
+				stamp, e := parseStampCommand(args[i], now)
 				if e != nil {
 					return nil, fmt.Errorf("SEEK_TO: %s", e)
 				}
 				cmd.SeekTo = stamp
-			} else {
-				stamp, e := parseStampCommand(args[i])
+			} else if cmd.Faulty.IsZero() {
+				stamp, e := parseStampCommand(args[i], now)
 				if e != nil {
 					return nil, fmt.Errorf("FAULTY_STAMP: %s", e)
 				}
 				cmd.Faulty = stamp
+			} else {
+				return nil, fmt.Errorf("unexpected argument '%s'", args[i])
 			}
 		}
 	}
@@ -58,182 +76,71 @@ func parseSeekCmd(args []string) (*SeekCmd, error) {
 		return nil, fmt.Errorf("require positional arg SEEK_TO")
 	}
 
+	if cmd.Faulty.IsZero() == cmd.StillOpen.IsZero() {
+		return nil, fmt.Errorf("expected exactly one of FAULTY_STAMP or -c STILL_OPEN")
+	}
+
 	return cmd, nil
 }
 
-func seekStillOpenPunchIn(db *sql.DB, cmd *SeekCmd) error {
-	if cmd.SeekTo.Before(cmd.StillOpen) {
-		return fmt.Errorf("SEEK_TO <= STILL_OPEN creates empty session")
-	}
-	rows, e := db.Query(`
-		SELECT * FROM punchcard
-		WHERE status IS 1
-		AND punch IS ?
-	`, cmd.StillOpen.Unix())
+func subCmdSeek(clock punch.Clock, dbPath string, args []string) error {
+	cmd, e := parseSeekCmd(args, clock.Now())
 	if e != nil {
-		return fmt.Errorf("querying STILL_OPEN punch: %s", e)
+		return fmt.Errorf("parsing command: %s", e)
 	}
-	defer rows.Close()
 
-	var openPunch *CardSchema
-	for rows.Next() {
-		card, e := scanToCard(rows)
-		if e != nil {
-			return fmt.Errorf("reading STILL_OPEN punch: %s", e)
-		}
-		if openPunch != nil {
-			// TODO(zacsh) add a CLI flag to break this ambiguity
-			return fmt.Errorf(
-				"ambiguous: more than one client has open seesion starting at STILL_OPEN")
-		}
-		openPunch = card
-	}
-	if openPunch == nil {
-		return fmt.Errorf("No punches found matching STILL_OPEN")
+	card, e := openCard(clock, dbPath)
+	if e != nil {
+		return fmt.Errorf("delete from db: %s", e)
 	}
+	defer card.Close()
 
-	closingPunch := *openPunch
-	closingPunch.IsStart = false
-	closingPunch.Punch = cmd.SeekTo
-	closingPunch.Note = "" // TODO(zacsh) add CLI flag to accept note
-	resultingSession := openPunch.toSession(&closingPunch)
-	fmt.Printf(
-		"Closing '%s' session, resulting in:\n%s\n",
-		closingPunch.Project, resultingSession)
-	if cmd.IsDryRun {
-		fmt.Fprint(os.Stderr, "[-d]ry-run: finishing early; NO changes written\n")
-		return nil
-	}
-	if e := punchProject(db, closingPunch.toSQL()); e != nil {
-		return fmt.Errorf("closing session: %s", e)
+	var plan *punch.SeekPlan
+	isApplied, e := previewThenApply(card, func() (proceed, error) {
+		var e error
+		plan, e = previewSeek(card, cmd)
+		return proceedFor(cmd.IsDryRun, cmd.IsYes), e
+	}, func() error {
+		return card.Seek(plan)
+	})
+	if e != nil || !isApplied {
+		return e
 	}
+
+	fmt.Println("Done.")
 	return nil
 }
 
-func seekExistingPunchOut(db *sql.DB, cmd *SeekCmd) error {
-	if cmd.SeekTo.Sub(cmd.Faulty) == 0 {
-		return fmt.Errorf("no effective change requested: FAULTY_STAMP equals SEEK_TO")
-	}
-
-	rows, e := db.Query(`
-		SELECT * FROM punchcard
-		WHERE punch IS ?
-		AND status IS 0
-	`, cmd.Faulty.Unix())
-	if e != nil {
-		return fmt.Errorf("querying for FAULTY_STAMP: %s", e)
-	}
-	defer rows.Close()
-
-	var origClose *CardSchema
-	for rows.Next() {
-		card, e := scanToCard(rows)
+// Plans cmd and prints what it will do, stopping there if it's a dry-run.
+func previewSeek(card *punch.Card, cmd *SeekCmd) (*punch.SeekPlan, error) {
+	var plan *punch.SeekPlan
+	var e error
+	if cmd.isClose() {
+		plan, e = card.PlanSeekClose(cmd.Client, cmd.StillOpen, cmd.SeekTo)
 		if e != nil {
-			return fmt.Errorf("reading FAULTY_STAMP punch: %s", e)
+			return nil, e
 		}
-		if origClose != nil {
-			// TODO(zacsh) add a CLI flag to break this ambiguity
-			return fmt.Errorf("ambiguous: more than one punch-out shares this FAULTY_STAMP")
-		}
-		origClose = card
-	}
-	if origClose == nil {
-		return fmt.Errorf("No punches found matching FAULTY_STAMP")
-	}
-
-	newClose := *origClose
-	newClose.Punch = cmd.SeekTo
-
-	seekDirection := "Rewind"
-	seekOffset := origClose.Punch.Sub(cmd.SeekTo)
-	if seekOffset < 0 {
-		seekDirection = "Fast-forward"
-		seekOffset = cmd.SeekTo.Sub(origClose.Punch)
-	}
-	fmt.Printf("%sing '%s' session's close by %s\n",
-		seekDirection, newClose.Project, seekOffset)
-
-	rows, e = db.Query(`
-		SELECT * FROM punchcard
-		WHERE punch < ?
-		AND project IS ?
-		AND status IS 1
-		LIMIT 1
-	`, cmd.Faulty.Unix(), origClose.Project)
-	if e != nil {
-		return fmt.Errorf("querying for FAULTY_STAMP's opening punch: %s", e)
-	}
-
-	var punchIn *CardSchema
-	for rows.Next() {
-		card, e := scanToCard(rows)
+		fmt.Printf(
+			"Closing '%s' session, resulting in:\n%s\n",
+			plan.PunchIn.Project, plan.Session())
+	} else {
+		plan, e = card.PlanSeekPunchOut(cmd.Client, cmd.Faulty, cmd.SeekTo)
 		if e != nil {
-			return fmt.Errorf("reading FAULTY_STAMP's session cards: %s", e)
+			return nil, e
 		}
 
-		if punchIn != nil {
-			panic("Despite SQL `LIMIT 1`, got multiple records")
+		seekDirection := "Rewind"
+		seekOffset := -plan.Offset()
+		if seekOffset < 0 {
+			seekDirection = "Fast-forward"
+			seekOffset = plan.Offset()
 		}
-
-		punchIn = card
-	}
-	if punchIn == nil {
-		return fmt.Errorf("bad data state: no open punch to FAULTY_STAMP's close")
-	}
-
-	if !punchIn.Punch.Before(cmd.SeekTo) {
-		return fmt.Errorf(
-			"SEEK_TO will rewind sesion-close to %s BEFORE session's start",
-			punchIn.Punch.Sub(cmd.SeekTo))
+		fmt.Printf("%sing '%s' session's close by %s\n",
+			seekDirection, plan.PunchIn.Project, seekOffset)
 	}
 
 	if cmd.IsDryRun {
 		fmt.Fprint(os.Stderr, "[-d]ry-run: finishing early; NO changes written\n")
-		return nil
 	}
-
-	stmt, e := db.Prepare(`
-		UPDATE punchcard
-		SET punch = ?
-		WHERE punch IS ?
-		AND project IS ?
-	`)
-	if e != nil {
-		return fmt.Errorf("building UPDATE query: %s", e)
-	}
-
-	// TODO(zacsh) expose result val here via debug flags on cli
-	if _, e := stmt.Exec(
-		newClose.Punch.Unix(),
-		origClose.Punch.Unix(),
-		newClose.Project); e != nil {
-		return fmt.Errorf("running UPDATE query: %s", e)
-	}
-	return nil
-}
-
-func subCmdSeek(dbPath string, args []string) error {
-	cmd, e := parseSeekCmd(args)
-	if e != nil {
-		return fmt.Errorf("parsing command: %s", e)
-	}
-
-	db, e := sql.Open("sqlite3", dbPath)
-	if e != nil {
-		return fmt.Errorf("delete from db: %s", e)
-	}
-	defer db.Close()
-
-	if cmd.isClose() {
-		if e := seekStillOpenPunchIn(db, cmd); e != nil {
-			return e
-		}
-	} else {
-		if e := seekExistingPunchOut(db, cmd); e != nil {
-			return e
-		}
-	}
-
-	fmt.Println("Done.")
-	return nil
+	return plan, nil
 }
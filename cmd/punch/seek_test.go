@@ -0,0 +1,143 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSeekCmd(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args     []string
+		expected SeekCmd
+	}{
+		{
+			[]string{"@1491963000", "@1491963757"},
+			SeekCmd{SeekTo: time.Unix(1491963000, 0), Faulty: time.Unix(1491963757, 0)},
+		},
+		{
+			[]string{"-d", "1491963000", "1491963757"},
+			SeekCmd{
+				SeekTo:   time.Unix(1491963000, 0),
+				Faulty:   time.Unix(1491963757, 0),
+				IsDryRun: true,
+			},
+		},
+		{
+			[]string{"-1h", "-c", "@1491946298", "-d"},
+			SeekCmd{
+				SeekTo:    now.Add(-time.Hour),
+				StillOpen: time.Unix(1491946298, 0),
+				IsDryRun:  true,
+			},
+		},
+		{
+			[]string{"--client", "acme", "@1491963000", "@1491963757"},
+			SeekCmd{
+				Client: "acme",
+				SeekTo: time.Unix(1491963000, 0),
+				Faulty: time.Unix(1491963757, 0),
+			},
+		},
+		{
+			[]string{"@1491963000", "-y", "@1491963757"},
+			SeekCmd{
+				SeekTo: time.Unix(1491963000, 0),
+				Faulty: time.Unix(1491963757, 0),
+				IsYes:  true,
+			},
+		},
+	} {
+		cmd, e := parseSeekCmd(tt.args, now)
+		if e != nil {
+			t.Errorf("parseSeekCmd(%q): unexpected error: %s", tt.args, e)
+			continue
+		}
+		if *cmd != tt.expected {
+			t.Errorf("parseSeekCmd(%q): got %+v, expected %+v", tt.args, *cmd, tt.expected)
+		}
+	}
+}
+
+func TestParseSeekCmdErrors(t *testing.T) {
+	now := time.Unix(1491970000, 0)
+	for _, tt := range []struct {
+		args          []string
+		errorContains string
+	}{
+		{nil, "expected at least SEEK_TO and one more stamp"},
+		{[]string{"@1491963000"}, "expected at least SEEK_TO and one more stamp"},
+		{[]string{"-d", "@1491963000"}, "expected exactly one of FAULTY_STAMP or -c"},
+		{[]string{"-d", "-d"}, "require positional arg SEEK_TO"},
+		{[]string{"@1491963000", "-c"}, "no STILL_OPEN found"},
+		{[]string{"@1491963000", "-c", "whenever"}, "STILL_OPEN"},
+		{[]string{"whenever", "@1491963757"}, "SEEK_TO"},
+		{[]string{"@1491963000", "whenever"}, "FAULTY_STAMP"},
+		{[]string{"@1491963000", "@1491963757", "@1491963758"}, "unexpected argument"},
+		{[]string{"@1491963000", "@1491963757", "--client"}, "no CLIENT found"},
+		{[]string{"@1491963000", "@1491963757", "--client", "a b"}, "invalid CLIENT"},
+		{
+			[]string{"@1491963000", "@1491963757", "-c", "@1491946298"},
+			"expected exactly one of FAULTY_STAMP or -c",
+		},
+	} {
+		_, e := parseSeekCmd(tt.args, now)
+		if e == nil {
+			t.Errorf("parseSeekCmd(%q): expected error, got none", tt.args)
+			continue
+		}
+		if !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf(
+				"parseSeekCmd(%q): expected error containing '%s', got: %s",
+				tt.args, tt.errorContains, e)
+		}
+	}
+}
+
+func TestSeekE2E(t *testing.T) {
+	runE2E(t, []e2eCase{
+		{
+			name:    "seek_punch_out",
+			fixture: sampleFixture,
+			steps: []e2eStep{
+				step("s", "-d", "@1491963000", "@1491963757"),
+				step("s", "-y", "@1491963000", "@1491963757"),
+				step("s", "-y", "@1491964000", "@1491963000"),
+				step("s", "-y", "@1491940000", "@1491964000"),
+				step("s", "-y", "@1491964000", "@1491964000"),
+				step("q", "report", "spaceship", "@1491946298"),
+			},
+		},
+		{
+			name:    "seek_close_open_session",
+			fixture: openFixture,
+			steps: []e2eStep{
+				step("s", "-y", "-1h", "-c", "-45m"),
+				step("s", "-d", "-15m", "-c", "-45m"),
+				step("s", "-y", "-15m", "-c", "-45m"),
+				step("q", "report", "golangpunch", "-1h"),
+			},
+		},
+		{
+			name:    "seek_shared_stamp",
+			fixture: sharedStampFixture,
+			steps: []e2eStep{
+				step("s", "-y", "-45m", "-1h"),
+				step("s", "-y", "--client", "golangpunch", "-45m", "-1h"),
+				step("s", "-y", "-1m", "-c", "-30m"),
+				step("s", "-y", "--client", "spaceship", "-1m", "-c", "-30m"),
+				step("q", "report", "golangpunch", "-2h"),
+				step("q", "report", "spaceship", "-2h"),
+			},
+		},
+		{
+			name:    "seek_empty_card",
+			fixture: emptyFixture,
+			steps: []e2eStep{
+				step("s", "-y", "@1491963000", "@1491963757"),
+				step("s", "-y", "@1491963000", "-c", "@1491960000"),
+			},
+		},
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jzacsh/punch"
+	"time"
+)
+
+type SwitchCmd struct {
+	Client string
+	Note   string
+	At     time.Time // zero value if not passed
+	Tags   []string
+	IsAll  bool // punches out of every client on the clock
+}
+
+func parseSwitchCli(args []string, now time.Time) (*SwitchCmd, error) {
+	cmd := &SwitchCmd{}
+	if len(args) > 0 && args[0] == "--all" {
+		cmd.IsAll = true
+		args = args[1:]
+	}
+	if len(args) < 1 || args[0] == "-n" {
+		return nil, fmt.Errorf("argument CLIENT is required")
+	}
+
+	var e error
+	if cmd.Client, cmd.Note, cmd.At, cmd.Tags, e = parseArgs(args, now); e != nil {
+		return nil, e
+	}
+	if len(cmd.Client) == 0 {
+		return nil, fmt.Errorf("argument CLIENT is required")
+	}
+	return cmd, nil
+}
+
+func subCmdSwitch(clock punch.Clock, dbPath string, args []string) error {
+	cmd, e := parseSwitchCli(args, clock.Now())
+	if e != nil {
+		return e
+	}
+
+	card, e := openCard(clock, dbPath)
+	if e != nil {
+		return fmt.Errorf("punch cards: %s", e)
+	}
+	defer card.Close()
+
+	_, e = card.SwitchAt(cmd.Client, cmd.Note, cmd.At, cmd.IsAll, cmd.Tags...)
+	return e
+}
@@ -0,0 +1,292 @@
+package punch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Concurrency is a policy on different clients' sessions overlapping in time.
+type Concurrency int
+
+const (
+	// ConcurrencyAllow lets sessions overlap, each counting its time in full.
+	ConcurrencyAllow Concurrency = iota
+
+	// ConcurrencyExclusive refuses any punch-in, resume, or seek of a session's
+	// close later, that would overlap another client's session; closing a
+	// session, which can only shrink an overlap, never is. It never closes the
+	// other session itself: see Switch, to move from one client to another.
+	ConcurrencyExclusive
+
+	// ConcurrencySplit lets sessions overlap, but divides the time they share
+	// evenly between them, when reporting.
+	ConcurrencySplit
+)
+
+var concurrencyNames = []string{"allow", "exclusive", "split"}
+
+func (c Concurrency) String() string {
+	if c < 0 || int(c) >= len(concurrencyNames) {
+		return fmt.Sprintf("Concurrency(%d)", c)
+	}
+	return concurrencyNames[c]
+}
+
+// ParseConcurrency reads a Concurrency by its name, eg: "split".
+func ParseConcurrency(name string) (Concurrency, error) {
+	for i, n := range concurrencyNames {
+		if n == name {
+			return Concurrency(i), nil
+		}
+	}
+	return ConcurrencyAllow, fmt.Errorf(
+		"invalid concurrency policy, '%s' (expected one of: %s)",
+		name, strings.Join(concurrencyNames, ", "))
+}
+
+// Overlap is a span of time over which several clients' sessions ran at once.
+type Overlap struct {
+	StartAt time.Time
+	StopAt  time.Time
+	Clients []string // sorted
+}
+
+func (o *Overlap) Duration() time.Duration { return o.StopAt.Sub(o.StartAt) }
+
+// DoubleCounted is the time o adds to totals beyond its own duration, as each of
+// its clients counts it in full.
+func (o *Overlap) DoubleCounted() time.Duration {
+	return o.Duration() * time.Duration(len(o.Clients)-1)
+}
+
+func (o *Overlap) has(client string) bool {
+	for _, c := range o.Clients {
+		if c == client {
+			return true
+		}
+	}
+	return false
+}
+
+// A span of time a session was worked over, which may still be open.
+type span struct {
+	client      string
+	start, stop time.Time
+}
+
+// Divides client's session from start to stop into the spans it wasn't paused
+// over, per its breaks.
+func workedSpans(client string, start, stop time.Time, breaks []*BreakSchema) []*span {
+	var spans []*span
+	for _, b := range breaks {
+		pauseStop := b.Stop
+		if b.IsPaused() || pauseStop.After(stop) {
+			pauseStop = stop
+		}
+		if b.Start.After(start) {
+			end := b.Start
+			if end.After(stop) {
+				end = stop
+			}
+			if end.After(start) {
+				spans = append(spans, &span{client, start, end})
+			}
+		}
+		if pauseStop.After(start) {
+			start = pauseStop
+		}
+	}
+	if stop.After(start) {
+		spans = append(spans, &span{client, start, stop})
+	}
+	return spans
+}
+
+// Pairs cards, expected in chronological order, into the spans each session
+// was worked over, less its breaks, taking any session still open to run until
+// `until`.
+func spansOf(cards []*CardSchema, breaks SessionBreaks, until time.Time) []*span {
+	lastPunchInFor := make(map[string]*CardSchema)
+	var spans []*span
+	for _, card := range cards {
+		if card.IsStart {
+			lastPunchInFor[card.Project] = card
+			continue
+		}
+		if in := lastPunchInFor[card.Project]; in != nil {
+			spans = append(spans,
+				workedSpans(card.Project, in.Punch, card.Punch, breaks.Of(in))...)
+			delete(lastPunchInFor, card.Project)
+		}
+	}
+	for client, in := range lastPunchInFor {
+		spans = append(spans, workedSpans(client, in.Punch, until, breaks.Of(in))...)
+	}
+	return spans
+}
+
+// Overlapping finds where different clients' sessions in cards, expected in
+// chronological order, overlap, taking any session still open to run until
+// `until`. Sessions meeting at an instant, eg: per Switch, don't overlap, nor
+// do sessions over any of their breaks.
+func Overlapping(cards []*CardSchema, breaks SessionBreaks, until time.Time) []*Overlap {
+	type event struct {
+		at      time.Time
+		client  string
+		isStart bool
+	}
+	var events []event
+	for _, s := range spansOf(cards, breaks, until) {
+		events = append(events, event{s.start, s.client, true}, event{s.stop, s.client, false})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].at.Before(events[j].at) })
+
+	var overlaps []*Overlap
+	active := make(map[string]int)
+	for i := 0; i < len(events); {
+		at := events[i].at
+		for ; i < len(events) && events[i].at.Equal(at); i++ {
+			if events[i].isStart {
+				active[events[i].client]++
+			} else if active[events[i].client]--; active[events[i].client] == 0 {
+				delete(active, events[i].client)
+			}
+		}
+		if len(active) < 2 || i == len(events) {
+			continue
+		}
+
+		var clients []string
+		for client := range active {
+			clients = append(clients, client)
+		}
+		sort.Strings(clients)
+		next := events[i].at
+		if n := len(overlaps); n > 0 && overlaps[n-1].StopAt.Equal(at) &&
+			strings.Join(overlaps[n-1].Clients, "\n") == strings.Join(clients, "\n") {
+			overlaps[n-1].StopAt = next
+			continue
+		}
+		overlaps = append(overlaps, &Overlap{StartAt: at, StopAt: next, Clients: clients})
+	}
+	return overlaps
+}
+
+// Split divides the time each of sessions shares with other clients' sessions,
+// per overlaps, evenly between them: deducting the others' shares from its
+// Duration, including once it's clipped (see Session.Clip).
+func Split(sessions []*Session, overlaps []*Overlap) {
+	for _, s := range sessions {
+		for _, o := range overlaps {
+			if o.has(s.Client) && o.StartAt.Before(s.StopAt) && s.StartAt.Before(o.StopAt) {
+				s.overlaps = append(s.overlaps, o)
+			}
+		}
+		s.Duration -= s.splitOff()
+	}
+}
+
+// The time split off s to other clients' sessions, over its span.
+func (s *Session) splitOff() time.Duration {
+	var off time.Duration
+	for _, o := range s.overlaps {
+		start, stop := o.StartAt, o.StopAt
+		if s.StartAt.After(start) {
+			start = s.StartAt
+		}
+		if s.StopAt.Before(stop) {
+			stop = s.StopAt
+		}
+		if stop.After(start) {
+			shared := stop.Sub(start)
+			off += shared - (shared / time.Duration(len(o.Clients))).Round(time.Second)
+		}
+	}
+	return off
+}
+
+// Overlaps finds where different clients' sessions overlap, per Overlapping,
+// ending after `from` (or anywhere on the card, if `from` is the zero value).
+func (c *Card) Overlaps(from time.Time) ([]*Overlap, error) {
+	cards, e := c.Cards()
+	if e != nil {
+		return nil, e
+	}
+	breaks, e := c.AllBreaks()
+	if e != nil {
+		return nil, e
+	}
+	now := time.Unix(c.Clock.Now().Unix(), 0 /*nanoseconds*/)
+	var overlaps []*Overlap
+	for _, o := range Overlapping(cards, breaks, now) {
+		if o.StopAt.After(from) {
+			overlaps = append(overlaps, o)
+		}
+	}
+	return overlaps, nil
+}
+
+// SplitSessions splits sessions per Split, if c.Concurrency is
+// ConcurrencySplit.
+func (c *Card) SplitSessions(sessions []*Session) error {
+	if c.Concurrency != ConcurrencySplit || len(sessions) == 0 {
+		return nil
+	}
+	overlaps, e := c.Overlaps(time.Time{})
+	if e != nil {
+		return fmt.Errorf("finding overlapping sessions: %s", e)
+	}
+	Split(sessions, overlaps)
+	return nil
+}
+
+// Far enough in the future that sessions still open can be taken to run until
+// it.
+var forever = time.Unix(1<<40, 0 /*nanoseconds*/)
+
+// Fails, if c.Concurrency is ConcurrencyExclusive, if client's session from
+// start to stop (the zero value if it's to stay open) would overlap another
+// client's session, outside of either's breaks. Other clients' sessions still
+// open are taken to run until openUntil, or indefinitely, if it's the zero
+// value.
+func (c *Card) checkExclusive(client string, start, stop, openUntil time.Time) error {
+	if c.Concurrency != ConcurrencyExclusive {
+		return nil
+	}
+	if stop.IsZero() {
+		stop = forever
+	}
+	if openUntil.IsZero() {
+		openUntil = forever
+	}
+
+	cards, e := c.Cards()
+	if e != nil {
+		return e
+	}
+	breaks, e := c.AllBreaks()
+	if e != nil {
+		return e
+	}
+	own := workedSpans(client, start, stop, breaks[sessionKey{start.Unix(), client}])
+	for _, s := range spansOf(cards, breaks, openUntil) {
+		if s.client == client {
+			continue
+		}
+		for _, o := range own {
+			if !s.start.Before(o.stop) || !o.start.Before(s.stop) {
+				continue
+			}
+			if s.stop.Equal(forever) {
+				return fmt.Errorf(
+					"'%s' is still punched in, and sessions are exclusive (see switch)", s.client)
+			}
+			return fmt.Errorf(
+				"would overlap '%s' session from %s to %s, and sessions are exclusive",
+				s.client, s.start.Format(FormatDateTime), s.stop.Format(FormatDateTime))
+		}
+	}
+	return nil
+}
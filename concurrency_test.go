@@ -0,0 +1,196 @@
+package punch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConcurrency(t *testing.T) {
+	for _, policy := range []Concurrency{ConcurrencyAllow, ConcurrencyExclusive, ConcurrencySplit} {
+		if parsed, e := ParseConcurrency(policy.String()); e != nil || parsed != policy {
+			t.Errorf("ParseConcurrency('%s'): got %s (error: %v)", policy, parsed, e)
+		}
+	}
+	if _, e := ParseConcurrency("sometimes"); e == nil {
+		t.Errorf("expected invalid policy to be an error")
+	}
+	if name := Concurrency(7).String(); name != "Concurrency(7)" {
+		t.Errorf("got unknown policy's name '%s'", name)
+	}
+}
+
+func TestOverlapping(t *testing.T) {
+	epoch := time.Unix(1491000000, 0)
+	at := func(minutes int) time.Time { return epoch.Add(time.Minute * time.Duration(minutes)) }
+	cards := []*CardSchema{
+		{Punch: at(0), IsStart: true, Project: "a"},
+		{Punch: at(10), IsStart: true, Project: "b"},
+		{Punch: at(20), IsStart: true, Project: "c"},
+		{Punch: at(30), Project: "c"},
+		{Punch: at(40), Project: "a"},
+		{Punch: at(40), IsStart: true, Project: "c"}, // meets b's end
+		{Punch: at(50), Project: "b"},
+		{Punch: at(60), IsStart: true, Project: "a"},
+	}
+
+	var got []string
+	for _, o := range Overlapping(cards, nil /*breaks*/, at(70)) {
+		got = append(got, fmt.Sprintf("%s-%s:%s",
+			o.StartAt.Sub(epoch), o.StopAt.Sub(epoch), strings.Join(o.Clients, "+")))
+	}
+	if expected := "10m0s-20m0s:a+b,20m0s-30m0s:a+b+c,30m0s-40m0s:a+b," +
+		"40m0s-50m0s:b+c,1h0m0s-1h10m0s:a+c"; strings.Join(got, ",") != expected {
+		t.Errorf("got overlaps %s, expected %s", strings.Join(got, ","), expected)
+	}
+
+	sessions := []*Session{cards[0].ToSession(cards[4]), cards[1].ToSession(cards[6])}
+	Split(sessions, Overlapping(cards, nil /*breaks*/, at(70)))
+	// a: 10m alone, then shares 10m with b, 10m with b & c, and 10m with b again
+	if d := sessions[0].Duration; d != time.Second*(600+300+200+300) {
+		t.Errorf("got a's split duration %s", d)
+	}
+	if clipped := sessions[0].Clip(at(0), at(25)); clipped.Duration != time.Second*(600+300+100) {
+		t.Errorf("got a's clipped split duration %s", clipped.Duration)
+	}
+}
+
+func TestExclusive(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	c.Concurrency = ConcurrencyExclusive
+
+	if _, e := c.PunchAt("beta", "", epoch.Add(time.Minute*30)); e == nil ||
+		!strings.Contains(e.Error(), "would overlap 'acme' session") {
+		t.Errorf("expected overlap with a past session refused, got: %v", e)
+	}
+	if _, e := c.PunchAt("beta", "", epoch.Add(time.Hour*3)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+	if _, e := c.PunchAt("acme", "", epoch.Add(time.Hour*4)); e == nil ||
+		!strings.Contains(e.Error(), "'beta' is still punched in") {
+		t.Errorf("expected a second open session refused, got: %v", e)
+	}
+	if _, e := c.PlanSeekPunchOut("acme", epoch.Add(time.Minute*170), epoch.Add(time.Hour*4)); e == nil ||
+		!strings.Contains(e.Error(), "'beta' is still punched in") {
+		t.Errorf("expected seek into beta's open session refused, got: %v", e)
+	}
+	if _, e := c.PlanSeekPunchOut("acme", epoch.Add(time.Minute*170), epoch.Add(time.Minute*160)); e != nil {
+		t.Errorf("expected rewinding a punch-out allowed, got: %s", e)
+	}
+	if _, e := c.Switch("acme", "", false /*all*/); e != nil {
+		t.Errorf("switching: %s", e)
+	}
+}
+
+// Punches client at each of minutes past epoch, failing t on any error.
+func punchMinutes(t *testing.T, c *Card, epoch time.Time, client string, minutes ...int) {
+	for _, m := range minutes {
+		if _, e := c.PunchAt(client, "", epoch.Add(time.Minute*time.Duration(m))); e != nil {
+			t.Fatalf("punching '%s' at +%dm: %s", client, m, e)
+		}
+	}
+}
+
+func TestSplitBreaks(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	c.Concurrency = ConcurrencySplit
+	at := func(minutes int) time.Time { return epoch.Add(time.Minute * time.Duration(minutes)) }
+
+	punchMinutes(t, c, epoch, "acme", 180)
+	if _, e := c.PauseAt("acme", at(190)); e != nil {
+		t.Fatalf("pausing: %s", e)
+	}
+	punchMinutes(t, c, epoch, "beta", 190, 220)
+	if _, e := c.ResumeAt("acme", at(220)); e != nil {
+		t.Fatalf("resuming: %s", e)
+	}
+	punchMinutes(t, c, epoch, "acme", 240)
+
+	if overlaps, e := c.Overlaps(time.Time{}); e != nil || len(overlaps) != 0 {
+		t.Errorf("expected beta's session within acme's break not to overlap, got %d (error: %v)",
+			len(overlaps), e)
+	}
+	for client, expected := range map[string]time.Duration{
+		"acme": time.Minute * 30,
+		"beta": time.Minute * 30,
+	} {
+		report, e := c.Report(client, at(180))
+		if e != nil {
+			t.Fatalf("reporting '%s': %s", client, e)
+		}
+		if total := report.Total(); total != expected {
+			t.Errorf("got '%s' total %s, expected %s", client, total, expected)
+		}
+	}
+}
+
+func TestExclusiveBreaks(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	c.Concurrency = ConcurrencyExclusive
+	at := func(minutes int) time.Time { return epoch.Add(time.Minute * time.Duration(minutes)) }
+
+	punchMinutes(t, c, epoch, "acme", 180)
+	if _, e := c.PauseAt("acme", at(190)); e != nil {
+		t.Fatalf("pausing: %s", e)
+	}
+	punchMinutes(t, c, epoch, "beta", 190)
+	if _, e := c.ResumeAt("acme", at(200)); e == nil ||
+		!strings.Contains(e.Error(), "'beta' is still punched in") {
+		t.Errorf("expected resume while beta's punched in refused, got: %v", e)
+	}
+	punchMinutes(t, c, epoch, "beta", 220)
+	if _, e := c.ResumeAt("acme", at(210)); e == nil ||
+		!strings.Contains(e.Error(), "would overlap 'beta' session") {
+		t.Errorf("expected resume within beta's session refused, got: %v", e)
+	}
+	if _, e := c.ResumeAt("acme", at(220)); e != nil {
+		t.Fatalf("resuming: %s", e)
+	}
+	punchMinutes(t, c, epoch, "acme", 240)
+}
+
+func TestExclusiveDeletion(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	punchMinutes(t, c, epoch, "acme", 180)
+	punchMinutes(t, c, epoch, "beta", 190)
+	punchMinutes(t, c, epoch, "acme", 200)
+	c.Concurrency = ConcurrencyExclusive
+
+	if _, e := c.PlanPunchDeletion("acme", epoch.Add(time.Minute*200)); e == nil ||
+		!strings.Contains(e.Error(), "'beta' is still punched in") {
+		t.Errorf("expected re-opening acme alongside beta refused, got: %v", e)
+	}
+	if _, e := c.PlanPunchDeletion("acme", epoch.Add(time.Minute*180)); e != nil {
+		t.Errorf("expected deleting acme's whole session allowed, got: %s", e)
+	}
+
+	// undo restores the card as it was, policy or not
+	if _, e := c.Undo(); e != nil {
+		t.Fatalf("undoing acme's punch-out: %s", e)
+	}
+	if open, e := c.OpenPunches(); e != nil || len(open) != 2 {
+		t.Errorf("expected undo to re-open acme alongside beta, got %d open (error: %v)",
+			len(open), e)
+	}
+}
+
+func TestOverlapsOpenUntilNow(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	punchMinutes(t, c, epoch, "acme", 180)
+	punchMinutes(t, c, epoch, "beta", 240)
+	c.Clock = FixedClock(epoch.Add(time.Hour*5 + time.Millisecond*500))
+
+	overlaps, e := c.Overlaps(time.Time{})
+	if e != nil {
+		t.Fatalf("finding overlaps: %s", e)
+	}
+	if len(overlaps) != 1 || overlaps[0].Duration() != time.Hour {
+		t.Errorf("expected 1h overlap, up to now to the second, got %v", overlaps)
+	}
+}
@@ -0,0 +1,136 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PunchDeletion describes the records removed by deleting a single punch.
+type PunchDeletion struct {
+	Target *CardSchema
+
+	// When Target is a punch-in, this is its corresponding punch-out (if the
+	// session has been closed).
+	PunchOut *CardSchema
+}
+
+// IsSessionDeletion indicates an entire session is being deleted, rather than
+// re-opening a session by deleting its punch-out.
+func (d *PunchDeletion) IsSessionDeletion() bool { return d.Target.IsStart }
+
+// PlanPunchDeletion finds client's punch at `at` and what deleting it entails:
+//   - if `at` is a punch-in, the entire session is to be deleted
+//   - if `at` is a punch-out, and no punches have happened since, the session is
+//     effectively re-opened, unless that would break c.Concurrency
+func (c *Card) PlanPunchDeletion(client string, at time.Time) (*PunchDeletion, error) {
+	cards, e := c.queryCards(`
+		SELECT * FROM punchcard
+		WHERE project IS ?
+		AND punch >= ?
+		ORDER BY punch ASC
+		LIMIT 2
+		;`, client, at.Unix())
+	if e != nil {
+		return nil, fmt.Errorf("querying DB: %s", e)
+	}
+
+	if len(cards) == 0 {
+		return nil, fmt.Errorf(
+			"no '%s' punches found between %s and now",
+			client,
+			at.Format(FormatDateTime))
+	}
+	if !cards[0].Punch.Equal(at) {
+		return nil, fmt.Errorf("no '%s' punch at %s",
+			client, at.Format(FormatDateTime))
+	}
+
+	deletion := &PunchDeletion{Target: cards[0]}
+	if deletion.IsSessionDeletion() {
+		if len(cards) > 1 {
+			second := cards[1]
+			if second.IsStart {
+				return nil, fmt.Errorf(
+					"malformed db: found TWO punch-ins in a row, second at %d",
+					second.Punch.Unix())
+			}
+			deletion.PunchOut = second
+		}
+		return deletion, nil
+	}
+
+	// We're effectively punching back in (ie: we punched out out of a
+	// work-session, and want to undo that, indicating we've still been
+	// working until now, this whole time).
+
+	rows, e := c.conn().Query(`
+		SELECT COUNT(DISTINCT punch)
+		FROM punchcard
+		WHERE project IS ?
+		AND punch > ?
+		;`, client, at.Unix())
+	if e != nil {
+		return nil, fmt.Errorf("querying DB: %s", e)
+	}
+	defer rows.Close()
+
+	count := -1
+	for rows.Next() {
+		if e := rows.Scan(&count); e != nil {
+			return nil, fmt.Errorf("querying DB: %s", e)
+		}
+	}
+	if count == -1 {
+		panic("somehow pointer not populated by successful rows.Scan()")
+	}
+
+	if count != 0 {
+		return nil, fmt.Errorf(
+			"re-opening work session with new sessions opened since will cause data inconsistency (%d punches found since). HINT: to delete an ENTIRE session, delete its punch-IN time.", count)
+	}
+	if e := c.checkExclusive(client, at, time.Time{}, time.Time{}); e != nil {
+		return nil, fmt.Errorf("re-opening session: %s", e)
+	}
+
+	return deletion, nil
+}
+
+// DeletePunches removes the punches planned by PlanPunchDeletion, along with the
+// session's tags and breaks when deleting the whole session.
+func (c *Card) DeletePunches(d *PunchDeletion) error {
+	keys := []rowKey{punchKey(d.Target)}
+	if d.IsSessionDeletion() {
+		keys = append(keys, tagsKey(d.Target), breaksKey(d.Target))
+	}
+	closing := d.Target.Punch.Unix()
+	if d.PunchOut != nil {
+		keys = append(keys, punchKey(d.PunchOut))
+		closing = d.PunchOut.Punch.Unix()
+	}
+
+	summary := fmt.Sprintf("delete '%s' punch-out", d.Target.Project)
+	if d.IsSessionDeletion() {
+		summary = fmt.Sprintf("delete '%s' session", d.Target.Project)
+	}
+	return c.journaled(summary, keys, func(tx *sql.Tx) error {
+		stmt, e := tx.Prepare(`
+			DELETE FROM punchcard
+			WHERE project IS ?
+			AND punch IN (?, ?)
+			;`)
+		if e != nil {
+			return fmt.Errorf("preparing SQL for deletion: %s", e)
+		}
+		if _, e = stmt.Exec(d.Target.Project, d.Target.Punch.Unix(), closing); e != nil {
+			return e
+		}
+		if d.IsSessionDeletion() {
+			if e := deleteTags(tx, d.Target); e != nil {
+				return e
+			}
+			return deleteBreaks(tx, d.Target)
+		}
+		return nil
+	})
+}
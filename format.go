@@ -0,0 +1,80 @@
+package punch
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const FormatDateTime string = "2006-01-02 15:04:05"
+
+const DurationToStrMaxLen = 20
+
+func DurationToStr(d time.Duration) string {
+	daysStr := ""
+	days := int(d.Hours()) / 24
+	if days > 0 {
+		daysStr = fmt.Sprintf("%04d days ", days)
+	}
+	h, m, s := DurationToHMS(d)
+	colonIf := func(q int) string {
+		if q > 0 {
+			return fmt.Sprintf("%02d:", q)
+		}
+		return ""
+	}
+	return fmt.Sprintf("%s%s%02d:%02d", daysStr, colonIf(h), m, s)
+}
+
+// DurationToHMS splits d, less any whole days, into hours, minutes & seconds.
+// As d is elapsed time its days are always 24h; see Session.SplitDays for
+// calendar days, which DST changes make 23h or 25h long.
+func DurationToHMS(d time.Duration) (int, int, int) {
+	days := int(d.Hours()) / 24
+	h := int((d - time.Duration(days)*time.Hour*24).Hours()) % 24
+	m := int((d - time.Duration(days)*time.Hour*24 -
+		time.Duration(h)*time.Hour).Minutes())
+	s := int((d - time.Duration(days)*time.Hour*24 -
+		time.Duration(h)*time.Hour -
+		time.Duration(m)*time.Minute).Seconds())
+	return h, m, s
+}
+
+func FromStatus(status bool) string {
+	if status {
+		return "in"
+	}
+	return "out"
+}
+
+func FromNote(note string) string {
+	if len(note) == 0 {
+		return "n/a"
+	}
+	return note
+}
+
+// One part of a client's name, between any ClientSeparator.
+var clientNamePattern string = fmt.Sprintf(
+	"(%s)+(-*%s)*(_*%s)*", alphaOrNumeric, alphaOrNumeric, alphaOrNumeric)
+
+var validClientRegexp *regexp.Regexp = regexp.MustCompile(fmt.Sprintf(
+	"^%s(%s%s)*$", clientNamePattern, ClientSeparator, clientNamePattern))
+
+var validTagRegexp *regexp.Regexp = regexp.MustCompile(
+	fmt.Sprintf("^%s$", clientNamePattern))
+
+const alphaOrNumeric string = "[[:alpha:]]|[[:digit:]]"
+
+// IsValidClient reports whether clientStr may name a client, or a sub-client
+// of one, eg: "acme/api/auth".
+func IsValidClient(clientStr string) bool {
+	if len(clientStr) < 1 {
+		return false
+	}
+	return validClientRegexp.MatchString(clientStr)
+}
+
+// IsValidTag reports whether tagStr may tag a session; tags are named as
+// clients are, but have no sub-tags.
+func IsValidTag(tagStr string) bool { return validTagRegexp.MatchString(tagStr) }
@@ -0,0 +1,118 @@
+package punch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationToStr(t *testing.T) {
+	for _, tt := range []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "00:00"},
+		{time.Second * 7, "00:07"},
+		{time.Minute*3 + time.Second*7, "03:07"},
+		{time.Hour, "01:00:00"},
+		{time.Hour*23 + time.Minute*59 + time.Second*59, "23:59:59"},
+		{time.Hour * 24, "0001 days 00:00"},
+		{time.Hour*26 + time.Second*5, "0001 days 02:00:05"},
+		{time.Hour*24*400 + time.Minute, "0400 days 01:00"},
+	} {
+		if actual := DurationToStr(tt.d); actual != tt.expected {
+			t.Errorf("DurationToStr(%s): got '%s', expected '%s'", tt.d, actual, tt.expected)
+		}
+	}
+}
+
+func TestDurationToHMS(t *testing.T) {
+	for _, tt := range []struct {
+		d       time.Duration
+		h, m, s int
+	}{
+		{0, 0, 0, 0},
+		{time.Millisecond * 999, 0, 0, 0},
+		{time.Second * 59, 0, 0, 59},
+		{time.Second * 61, 0, 1, 1},
+		{time.Hour*5 + time.Minute*4 + time.Second*3, 5, 4, 3},
+		{time.Hour * 24, 0, 0, 0},
+		{time.Hour*49 + time.Minute*30, 1, 30, 0},
+	} {
+		h, m, s := DurationToHMS(tt.d)
+		if h != tt.h || m != tt.m || s != tt.s {
+			t.Errorf(
+				"DurationToHMS(%s): got %d, %d, %d expected %d, %d, %d",
+				tt.d, h, m, s, tt.h, tt.m, tt.s)
+		}
+	}
+}
+
+func TestIsValidClient(t *testing.T) {
+	for _, tt := range []struct {
+		client  string
+		isValid bool
+	}{
+		{"acme", true},
+		{"ACME2", true},
+		{"7", true},
+		{"golang-punch", true},
+		{"golang--punch", true},
+		{"golang_punch", true},
+		{"a-b_c", true},
+		{"", false},
+		{" ", false},
+		{"-acme", false},
+		{"acme-", false},
+		{"_acme", false},
+		{"acme_", false},
+		{"acme corp", false},
+		{"acme.corp", false},
+		{"acme/api", true},
+		{"acme/api-v2/auth_z", true},
+		{"acme/", false},
+		{"/acme", false},
+		{"acme//api", false},
+		{"acme/ api", false},
+		{"a_b-c", false},
+		{"acme'; DROP TABLE punchcard;--", false},
+	} {
+		if actual := IsValidClient(tt.client); actual != tt.isValid {
+			t.Errorf("IsValidClient('%s'): got %t, expected %t", tt.client, actual, tt.isValid)
+		}
+	}
+}
+
+func TestIsValidTag(t *testing.T) {
+	for _, tt := range []struct {
+		tag     string
+		isValid bool
+	}{
+		{"meeting", true},
+		{"code-review", true},
+		{"", false},
+		{"two words", false},
+		{"dev/ops", false},
+	} {
+		if actual := IsValidTag(tt.tag); actual != tt.isValid {
+			t.Errorf("IsValidTag('%s'): got %t, expected %t", tt.tag, actual, tt.isValid)
+		}
+	}
+}
+
+func TestFromNote(t *testing.T) {
+	if actual := FromNote(""); actual != "n/a" {
+		t.Errorf("empty note: got '%s', expected 'n/a'", actual)
+	}
+	if actual := FromNote("boop"); actual != "boop" {
+		t.Errorf("got '%s', expected 'boop'", actual)
+	}
+}
+
+func TestFromStatus(t *testing.T) {
+	if actual := FromStatus(true); actual != "in" {
+		t.Errorf("punch-in: got '%s', expected 'in'", actual)
+	}
+	if actual := FromStatus(false); actual != "out" {
+		t.Errorf("punch-out: got '%s', expected 'out'", actual)
+	}
+}
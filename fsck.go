@@ -0,0 +1,464 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProblemKind is a class of inconsistency Check finds on a punch card.
+type ProblemKind string
+
+const (
+	StrayPunchOut   ProblemKind = "stray punch-out"  // with no punch-in before it
+	DoublePunchIn   ProblemKind = "double punch-in"  // never punched out of, before another
+	BackwardsBill   ProblemKind = "backwards bill"   // a pay period not starting before it ends
+	OverlappingBill ProblemKind = "overlapping bill" // starting before the client's (or a lineage's) previous ended
+	InvalidClient   ProblemKind = "invalid client"   // per IsValidClient
+	UntrimmedNote   ProblemKind = "untrimmed note"   // with leading or trailing whitespace
+
+	OrphanedTag      ProblemKind = "orphaned tag"      // on no session's punch-in
+	OrphanedBreak    ProblemKind = "orphaned break"    // within no session's punch-in
+	StrayBreak       ProblemKind = "stray break"       // not within its session's span
+	OverlappingBreak ProblemKind = "overlapping break" // starting before its session's previous ended
+)
+
+// Problem is one inconsistency found by Check.
+type Problem struct {
+	Kind   ProblemKind
+	Client string
+
+	// The record at fault, one of Punch, Bill, Tag or Break (none for
+	// InvalidClient).
+	Punch *CardSchema
+	Bill  *BillSchema
+	Tag   *TagSchema
+	Break *BreakSchema
+
+	// The record it conflicts with, if any: the later punch-in of a
+	// DoublePunchIn, the earlier pay period of an OverlappingBill (perhaps on
+	// a client it's within, or a sub-client), or the earlier break of an
+	// OverlappingBreak.
+	OtherPunch *CardSchema
+	OtherBill  *BillSchema
+	OtherBreak *BreakSchema
+}
+
+func (p *Problem) String() string {
+	switch {
+	case p.Punch != nil:
+		s := fmt.Sprintf("%s: '%s' punch-%s at %s [@%d] (note: '%s')",
+			p.Kind, p.Client, FromStatus(p.Punch.IsStart),
+			p.Punch.Punch.Format(FormatDateTime), p.Punch.Punch.Unix(), FromNote(p.Punch.Note))
+		if p.OtherPunch != nil {
+			s += fmt.Sprintf(", then punched in again at %s [@%d]",
+				p.OtherPunch.Punch.Format(FormatDateTime), p.OtherPunch.Punch.Unix())
+		}
+		return s
+	case p.Bill != nil:
+		s := fmt.Sprintf("%s: '%s' pay period from %s [@%d] to %s [@%d] (note: '%s')",
+			p.Kind, p.Client,
+			p.Bill.Startclusive.Format(FormatDateTime), p.Bill.Startclusive.Unix(),
+			p.Bill.Endclusive.Format(FormatDateTime), p.Bill.Endclusive.Unix(),
+			FromNote(p.Bill.Note))
+		if p.OtherBill != nil && p.OtherBill.Project != p.Client {
+			s += fmt.Sprintf(", though '%s' pay period runs from %s [@%d] to %s [@%d]",
+				p.OtherBill.Project,
+				p.OtherBill.Startclusive.Format(FormatDateTime), p.OtherBill.Startclusive.Unix(),
+				p.OtherBill.Endclusive.Format(FormatDateTime), p.OtherBill.Endclusive.Unix())
+		} else if p.OtherBill != nil {
+			s += fmt.Sprintf(", though the previous ended %s [@%d]",
+				p.OtherBill.Endclusive.Format(FormatDateTime), p.OtherBill.Endclusive.Unix())
+		}
+		return s
+	case p.Tag != nil:
+		return fmt.Sprintf("%s: '%s' tag '%s' on session punched in at %s [@%d]",
+			p.Kind, p.Client, p.Tag.Tag,
+			p.Tag.Punch.Format(FormatDateTime), p.Tag.Punch.Unix())
+	case p.Break != nil:
+		s := fmt.Sprintf("%s: '%s' %s, in session punched in at %s [@%d]",
+			p.Kind, p.Client, describeBreak(p.Break),
+			p.Break.Punch.Format(FormatDateTime), p.Break.Punch.Unix())
+		if p.OtherBreak != nil {
+			s += fmt.Sprintf(", though the previous %s", describeBreak(p.OtherBreak))
+		}
+		return s
+	}
+	return fmt.Sprintf("%s: '%s'", p.Kind, p.Client)
+}
+
+// Renders b's span, eg: "break from 2017-04-11 09:00:00 [@1491901200] to ...".
+func describeBreak(b *BreakSchema) string {
+	s := fmt.Sprintf("break from %s [@%d]", b.Start.Format(FormatDateTime), b.Start.Unix())
+	if b.IsPaused() {
+		return s + " still paused"
+	}
+	return s + fmt.Sprintf(" to %s [@%d]", b.Stop.Format(FormatDateTime), b.Stop.Unix())
+}
+
+// RepairPlan describes what Repair would do about p, or is empty if p can only
+// be fixed by hand.
+func (p *Problem) RepairPlan() string {
+	switch p.Kind {
+	case StrayPunchOut:
+		return "delete the punch-out"
+	case DoublePunchIn:
+		return "delete the first punch-in, as it was never punched out of"
+	case BackwardsBill:
+		if p.Bill.Startclusive.Equal(p.Bill.Endclusive) {
+			return "delete the empty pay period"
+		}
+		return "swap the pay period's start and end"
+	case UntrimmedNote:
+		return "trim the note"
+	case OrphanedTag:
+		return "delete the tag"
+	case OrphanedBreak:
+		return "delete the break"
+	}
+	return ""
+}
+
+// Check scans the entire punch card for inconsistencies, ordered by the kind of
+// problem, then client and time.
+func (c *Card) Check() ([]*Problem, error) {
+	var problems []*Problem
+
+	clients, e := c.allClients()
+	if e != nil {
+		return nil, fmt.Errorf("listing clients: %s", e)
+	}
+	for _, client := range clients {
+		if !IsValidClient(client) {
+			problems = append(problems, &Problem{Kind: InvalidClient, Client: client})
+		}
+	}
+
+	cards, e := c.Cards()
+	if e != nil {
+		return nil, fmt.Errorf("listing punches: %s", e)
+	}
+	punchInFor := make(map[string]*CardSchema)
+	sessions := make(map[sessionKey]*Session) // by punch-in; StopAt zero if never closed
+	for _, card := range cards {
+		punchIn := punchInFor[card.Project]
+		if card.IsStart {
+			if punchIn != nil {
+				problems = append(problems, &Problem{
+					Kind: DoublePunchIn, Client: card.Project, Punch: punchIn, OtherPunch: card})
+			}
+			punchInFor[card.Project] = card
+			sessions[sessionKey{card.Punch.Unix(), card.Project}] = &Session{
+				Client: card.Project, StartAt: card.Punch}
+			continue
+		}
+		if punchIn == nil {
+			problems = append(problems, &Problem{
+				Kind: StrayPunchOut, Client: card.Project, Punch: card})
+		} else {
+			sessions[sessionKey{punchIn.Punch.Unix(), card.Project}].StopAt = card.Punch
+		}
+		punchInFor[card.Project] = nil
+	}
+
+	sessionProblems, e := c.checkSessionRows(sessions)
+	if e != nil {
+		return nil, e
+	}
+	problems = append(problems, sessionProblems...)
+
+	untrimmed, e := c.untrimmedPunchNotes()
+	if e != nil {
+		return nil, fmt.Errorf("listing punch notes: %s", e)
+	}
+	for _, card := range untrimmed {
+		problems = append(problems, &Problem{
+			Kind: UntrimmedNote, Client: card.Project, Punch: card})
+	}
+
+	bills, e := c.Bills()
+	if e != nil {
+		return nil, fmt.Errorf("listing pay periods: %s", e)
+	}
+	sort.SliceStable(bills, func(i, j int) bool {
+		if bills[i].Project != bills[j].Project {
+			return bills[i].Project < bills[j].Project
+		}
+		return bills[i].Startclusive.Before(bills[j].Startclusive)
+	})
+	var previous *BillSchema // of the same client
+	for _, bill := range bills {
+		if isUntrimmed(bill.Note) {
+			problems = append(problems, &Problem{
+				Kind: UntrimmedNote, Client: bill.Project, Bill: bill})
+		}
+
+		if !bill.Startclusive.Before(bill.Endclusive) {
+			problems = append(problems, &Problem{
+				Kind: BackwardsBill, Client: bill.Project, Bill: bill})
+			continue // so not to be compared to its neighbors
+		}
+		if previous != nil && previous.Project == bill.Project &&
+			bill.Startclusive.Before(previous.Endclusive) {
+			problems = append(problems, &Problem{
+				Kind: OverlappingBill, Client: bill.Project, Bill: bill, OtherBill: previous})
+		}
+		if previous == nil || previous.Project != bill.Project ||
+			bill.Endclusive.After(previous.Endclusive) {
+			previous = bill
+		}
+	}
+	problems = append(problems, lineageBillOverlaps(bills)...)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Kind != problems[j].Kind {
+			return kindOrder(problems[i].Kind) < kindOrder(problems[j].Kind)
+		}
+		return problems[i].Client < problems[j].Client
+	})
+	return problems, nil
+}
+
+// Repair fixes p per its RepairPlan.
+func (c *Card) Repair(p *Problem) error {
+	var query string
+	var args []interface{}
+	var keys []rowKey
+	switch p.Kind {
+	case StrayPunchOut, DoublePunchIn:
+		query = `DELETE FROM punchcard WHERE punch IS ? AND project IS ?;`
+		args = []interface{}{p.Punch.Punch.Unix(), p.Punch.Project}
+		keys = []rowKey{punchKey(p.Punch)}
+		if p.Punch.IsStart {
+			keys = append(keys, tagsKey(p.Punch), breaksKey(p.Punch))
+		}
+	case BackwardsBill:
+		keys = []rowKey{billKey(p.Bill)}
+		if p.Bill.Startclusive.Equal(p.Bill.Endclusive) {
+			query = `DELETE FROM paychecks WHERE endclusive IS ? AND project IS ?;`
+			args = []interface{}{p.Bill.Endclusive.Unix(), p.Bill.Project}
+		} else {
+			query = `
+				UPDATE paychecks
+				SET endclusive = ?, startclusive = ?
+				WHERE endclusive IS ? AND project IS ?
+			;`
+			args = []interface{}{
+				p.Bill.Startclusive.Unix(), p.Bill.Endclusive.Unix(),
+				p.Bill.Endclusive.Unix(), p.Bill.Project}
+			keys = append(keys, rowKey{"paychecks", p.Bill.Startclusive.Unix(), p.Bill.Project})
+		}
+	case OrphanedTag:
+		query = `DELETE FROM tags WHERE punch IS ? AND project IS ? AND tag IS ?;`
+		args = []interface{}{p.Tag.Punch.Unix(), p.Tag.Project, p.Tag.Tag}
+		keys = []rowKey{tagsKey(&CardSchema{Punch: p.Tag.Punch, Project: p.Tag.Project})}
+	case OrphanedBreak:
+		query = `DELETE FROM breaks WHERE punch IS ? AND project IS ? AND start IS ?;`
+		args = []interface{}{p.Break.Punch.Unix(), p.Break.Project, p.Break.Start.Unix()}
+		keys = []rowKey{breaksKey(&CardSchema{Punch: p.Break.Punch, Project: p.Break.Project})}
+	case UntrimmedNote:
+		if p.Punch != nil {
+			query = `UPDATE punchcard SET note = ? WHERE punch IS ? AND project IS ?;`
+			args = []interface{}{toNullString(p.Punch.Note), p.Punch.Punch.Unix(), p.Punch.Project}
+			keys = []rowKey{punchKey(p.Punch)}
+		} else {
+			query = `UPDATE paychecks SET note = ? WHERE endclusive IS ? AND project IS ?;`
+			args = []interface{}{toNullString(p.Bill.Note), p.Bill.Endclusive.Unix(), p.Bill.Project}
+			keys = []rowKey{billKey(p.Bill)}
+		}
+	default:
+		return fmt.Errorf("no automatic repair for %s; must be fixed by hand", p.Kind)
+	}
+
+	summary := fmt.Sprintf("repair '%s' %s", p.Client, p.Kind)
+	return c.journaled(summary, keys, func(tx *sql.Tx) error {
+		r, e := tx.Exec(query, args...)
+		if e != nil {
+			return fmt.Errorf("repairing %s: %s", p.Kind, e)
+		}
+		if a, e := r.RowsAffected(); e != nil {
+			return fmt.Errorf("parsing results of repair: %s", e)
+		} else if a != 1 {
+			return fmt.Errorf("expected 1 record repaired, but got %d", a)
+		}
+		if p.Kind == DoublePunchIn {
+			if e := deleteTags(tx, p.Punch); e != nil {
+				return e
+			}
+			return deleteBreaks(tx, p.Punch)
+		}
+		return nil
+	})
+}
+
+// Pay periods overlapping one on a client they're within, as checkBillLineage
+// refuses, each reported against the one starting earlier (or, failing that,
+// on the client it's within).
+func lineageBillOverlaps(bills []*BillSchema) []*Problem {
+	var problems []*Problem
+	for i, bill := range bills {
+		for _, other := range bills[i+1:] {
+			if other.Project == bill.Project ||
+				!bill.Startclusive.Before(bill.Endclusive) ||
+				!other.Startclusive.Before(other.Endclusive) ||
+				!(IsWithinClient(other.Project, bill.Project) ||
+					IsWithinClient(bill.Project, other.Project)) ||
+				!other.Startclusive.Before(bill.Endclusive) ||
+				!bill.Startclusive.Before(other.Endclusive) {
+				continue
+			}
+			later, earlier := bill, other
+			if earlier.Startclusive.After(later.Startclusive) ||
+				(earlier.Startclusive.Equal(later.Startclusive) &&
+					IsWithinClient(earlier.Project, later.Project)) {
+				later, earlier = earlier, later
+			}
+			problems = append(problems, &Problem{
+				Kind: OverlappingBill, Client: later.Project, Bill: later, OtherBill: earlier})
+		}
+	}
+	return problems
+}
+
+// Tags and breaks not on any of sessions, by punch-in, and breaks outside their
+// session's span, or overlapping one another.
+func (c *Card) checkSessionRows(sessions map[sessionKey]*Session) ([]*Problem, error) {
+	var problems []*Problem
+	tags, e := c.AllTags()
+	if e != nil {
+		return nil, fmt.Errorf("listing tags: %s", e)
+	}
+	var tagged []sessionKey
+	for key := range tags {
+		tagged = append(tagged, key)
+	}
+	for _, key := range sortKeys(tagged) {
+		if sessions[key] != nil {
+			continue
+		}
+		for _, tag := range tags[key] {
+			problems = append(problems, &Problem{
+				Kind:   OrphanedTag,
+				Client: key.project,
+				Tag:    &TagSchema{time.Unix(key.punch, 0 /*nanoseconds*/), key.project, tag},
+			})
+		}
+	}
+
+	breaks, e := c.AllBreaks()
+	if e != nil {
+		return nil, fmt.Errorf("listing breaks: %s", e)
+	}
+	var paused []sessionKey
+	for key := range breaks {
+		paused = append(paused, key)
+	}
+	for _, key := range sortKeys(paused) {
+		session := sessions[key]
+		var previous *BreakSchema // latest ending, in the same session
+		for _, b := range breaks[key] {
+			if session == nil {
+				problems = append(problems, &Problem{Kind: OrphanedBreak, Client: key.project, Break: b})
+				continue
+			}
+			isOpen := session.StopAt.IsZero()
+			if b.Start.Before(session.StartAt) ||
+				(!b.IsPaused() && !b.Stop.After(b.Start)) ||
+				(!isOpen && (b.IsPaused() || b.Stop.After(session.StopAt))) {
+				problems = append(problems, &Problem{Kind: StrayBreak, Client: key.project, Break: b})
+				continue
+			}
+			if previous != nil && (previous.IsPaused() || b.Start.Before(previous.Stop)) {
+				problems = append(problems, &Problem{
+					Kind: OverlappingBreak, Client: key.project, Break: b, OtherBreak: previous})
+			}
+			if previous == nil || b.IsPaused() ||
+				(!previous.IsPaused() && b.Stop.After(previous.Stop)) {
+				previous = b
+			}
+		}
+	}
+	return problems, nil
+}
+
+// Sorts keys by punch-in, then client.
+func sortKeys(keys []sessionKey) []sessionKey {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].punch != keys[j].punch {
+			return keys[i].punch < keys[j].punch
+		}
+		return keys[i].project < keys[j].project
+	})
+	return keys
+}
+
+// Every client named in any table, valid or not.
+func (c *Card) allClients() ([]string, error) {
+	rows, e := c.conn().Query(`
+		SELECT project FROM punchcard
+		UNION SELECT project FROM paychecks
+		UNION SELECT project FROM rates
+		ORDER BY project ASC;
+	`)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var clients []string
+	for rows.Next() {
+		var client string
+		if e := rows.Scan(&client); e != nil {
+			return nil, e
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+// Punches whose notes have leading or trailing whitespace, which (unlike
+// queryCards) is left on their Note.
+func (c *Card) untrimmedPunchNotes() ([]*CardSchema, error) {
+	rows, e := c.conn().Query(`
+		SELECT * FROM punchcard
+		WHERE note IS NOT NULL
+		ORDER BY punch ASC, project ASC;
+	`)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var cards []*CardSchema
+	for rows.Next() {
+		raw := &CardSchemaSQL{}
+		if e := rows.Scan(&raw.Punch, &raw.Status, &raw.Project, &raw.Note); e != nil {
+			return nil, e
+		}
+		if isUntrimmed(raw.Note.String) {
+			card := raw.ToCard()
+			card.Note = raw.Note.String
+			cards = append(cards, card)
+		}
+	}
+	return cards, rows.Err()
+}
+
+func isUntrimmed(note string) bool { return strings.TrimSpace(note) != note }
+
+// The order Check reports each kind of problem in.
+func kindOrder(k ProblemKind) int {
+	for i, kind := range []ProblemKind{
+		// Notes first, so repairs of other problems don't move the records they're on
+		InvalidClient, UntrimmedNote, StrayPunchOut, DoublePunchIn,
+		OrphanedTag, OrphanedBreak, StrayBreak, OverlappingBreak,
+		BackwardsBill, OverlappingBill,
+	} {
+		if k == kind {
+			return i
+		}
+	}
+	return -1
+}
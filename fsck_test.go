@@ -0,0 +1,154 @@
+package punch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckAndRepair(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	// Written directly, as punch's own validation refuses them
+	at := func(d time.Duration) int64 { return epoch.Add(d).Unix() }
+	for _, stmt := range []struct {
+		sql  string
+		args []interface{}
+	}{
+		{`INSERT INTO punchcard VALUES (?, 0, 'acme', ' late ')`, []interface{}{at(time.Hour * 4)}},
+		{`INSERT INTO punchcard VALUES (?, 1, 'acme', NULL)`, []interface{}{at(time.Hour * 5)}},
+		{`INSERT INTO punchcard VALUES (?, 1, 'acme', NULL)`, []interface{}{at(time.Hour * 6)}},
+		{`INSERT INTO punchcard VALUES (?, 0, 'bad client', NULL)`, []interface{}{at(time.Hour * 7)}},
+		{`INSERT INTO paychecks VALUES (?, ?, 'acme', NULL)`, []interface{}{at(time.Hour), at(0)}},
+		{`INSERT INTO paychecks VALUES (?, ?, 'acme', NULL)`, []interface{}{at(time.Hour * 2), at(time.Minute * 30)}},
+		{`INSERT INTO paychecks VALUES (?, ?, 'acme', NULL)`, []interface{}{at(time.Hour * 3), at(time.Hour * 4)}},
+	} {
+		if _, e := c.db.Exec(stmt.sql, stmt.args...); e != nil {
+			t.Fatalf("inserting fixture: %s", e)
+		}
+	}
+
+	problems, e := c.Check()
+	if e != nil {
+		t.Fatalf("checking card: %s", e)
+	}
+	var kinds []ProblemKind
+	for _, p := range problems {
+		kinds = append(kinds, p.Kind)
+	}
+	expected := []ProblemKind{
+		InvalidClient, UntrimmedNote, StrayPunchOut, StrayPunchOut, DoublePunchIn,
+		BackwardsBill, OverlappingBill,
+	}
+	if len(kinds) != len(expected) {
+		t.Fatalf("got problems %q, expected %q", kinds, expected)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Fatalf("got problems %q, expected %q", kinds, expected)
+		}
+	}
+	if p := problems[4]; !p.Punch.Punch.Equal(epoch.Add(time.Hour*5)) ||
+		!p.OtherPunch.Punch.Equal(epoch.Add(time.Hour*6)) {
+		t.Errorf("expected earlier punch-in to be at fault, got %s", p)
+	}
+
+	for _, p := range problems {
+		if len(p.RepairPlan()) == 0 {
+			if e := c.Repair(p); e == nil {
+				t.Errorf("expected %s to need fixing by hand", p.Kind)
+			}
+			continue
+		}
+		if p.Kind == StrayPunchOut && p.Client == "acme" {
+			continue // so to show its note was trimmed
+		}
+		if e := c.Repair(p); e != nil {
+			t.Errorf("repairing %s: %s", p, e)
+		}
+	}
+
+	if problems, e = c.Check(); e != nil {
+		t.Fatalf("re-checking card: %s", e)
+	}
+	// 'bad client' went with its only punch, the stray punch-out
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems to remain, got %d: %v", len(problems), problems)
+	}
+	if stray := problems[0]; stray.Kind != StrayPunchOut || stray.Punch.Note != "late" {
+		t.Errorf("expected stray punch-out's note to have been trimmed, got %s", stray)
+	}
+	if problems[1].Kind != OverlappingBill {
+		t.Errorf("expected overlapping pay period to remain, got %s", problems[1])
+	}
+
+	bills, e := c.Bills("acme")
+	if e != nil {
+		t.Fatalf("listing pay periods: %s", e)
+	}
+	if last := bills[len(bills)-1]; !last.Startclusive.Equal(epoch.Add(time.Hour*3)) ||
+		!last.Endclusive.Equal(epoch.Add(time.Hour*4)) {
+		t.Errorf("expected backwards pay period to be swapped, got %s", last.String(false))
+	}
+}
+
+func TestCheckSessionRows(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	at := func(minutes int) int64 { return epoch.Add(time.Minute * time.Duration(minutes)).Unix() }
+	for _, stmt := range []struct {
+		sql  string
+		args []interface{}
+	}{
+		{`INSERT INTO tags VALUES (?, 'acme', 'lost')`, []interface{}{at(30)}},
+		{`INSERT INTO breaks VALUES (?, 'acme', ?, ?)`, []interface{}{at(30), at(35), at(40)}},
+		{`INSERT INTO breaks VALUES (?, 'acme', ?, ?)`, []interface{}{at(60), at(65), at(85)}},
+		{`INSERT INTO breaks VALUES (?, 'acme', ?, ?)`, []interface{}{at(120), at(125), at(140)}},
+		{`INSERT INTO breaks VALUES (?, 'acme', ?, ?)`, []interface{}{at(120), at(130), at(135)}},
+		{`INSERT INTO paychecks VALUES (?, ?, 'acme', NULL)`, []interface{}{at(60), at(0)}},
+		{`INSERT INTO paychecks VALUES (?, ?, 'acme/api', NULL)`, []interface{}{at(120), at(30)}},
+	} {
+		if _, e := c.db.Exec(stmt.sql, stmt.args...); e != nil {
+			t.Fatalf("inserting fixture: %s", e)
+		}
+	}
+
+	problems, e := c.Check()
+	if e != nil {
+		t.Fatalf("checking card: %s", e)
+	}
+	var kinds []ProblemKind
+	for _, p := range problems {
+		kinds = append(kinds, p.Kind)
+	}
+	expected := []ProblemKind{
+		OrphanedTag, OrphanedBreak, StrayBreak, OverlappingBreak, OverlappingBill,
+	}
+	if len(kinds) != len(expected) {
+		t.Fatalf("got problems %q, expected %q", kinds, expected)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Fatalf("got problems %q, expected %q", kinds, expected)
+		}
+	}
+	if p := problems[3]; p.Break.Start.Unix() != at(130) || p.OtherBreak.Start.Unix() != at(125) {
+		t.Errorf("expected later break to be at fault, got %s", p)
+	}
+	if p := problems[4]; p.Client != "acme/api" || p.OtherBill.Project != "acme" {
+		t.Errorf("expected sub-client's later pay period to be at fault, got %s", p)
+	}
+
+	for _, p := range problems[:2] {
+		if e := c.Repair(p); e != nil {
+			t.Errorf("repairing %s: %s", p, e)
+		}
+	}
+	if problems, e = c.Check(); e != nil {
+		t.Fatalf("re-checking card: %s", e)
+	}
+	if len(problems) != 3 {
+		t.Errorf("expected 3 problems to remain, got %d: %v", len(problems), problems)
+	}
+}
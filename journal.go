@@ -0,0 +1,441 @@
+package punch
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CardImage is the rows of each table a change touched, as they were either
+// before or after it.
+type CardImage struct {
+	Punches []*CardSchema
+	Bills   []*BillSchema
+	Rates   []*RateSchema
+	Tags    []*TagSchema
+	Breaks  []*BreakSchema
+}
+
+// JournalEntry is one change made to the card, as recorded in its journal.
+type JournalEntry struct {
+	ID      int64
+	At      time.Time
+	Command string // per Card.Command, as of the change
+
+	Before, After *CardImage
+
+	// Whether the change has been undone (and is now only redo-able).
+	IsUndone bool
+
+	before, after *journalImage
+}
+
+// The rows of a CardImage, exactly as stored.
+type journalImage struct {
+	Punches []*CardSchemaSQL  `json:"punchcard,omitempty"`
+	Bills   []*BillSchemaSQL  `json:"paychecks,omitempty"`
+	Rates   []*RateSchemaSQL  `json:"rates,omitempty"`
+	Tags    []*TagSchemaSQL   `json:"tags,omitempty"`
+	Breaks  []*BreakSchemaSQL `json:"breaks,omitempty"`
+}
+
+func (img *journalImage) toImage() *CardImage {
+	image := &CardImage{}
+	for _, raw := range img.Punches {
+		image.Punches = append(image.Punches, raw.ToCard())
+	}
+	for _, raw := range img.Bills {
+		image.Bills = append(image.Bills, raw.ToBill())
+	}
+	for _, raw := range img.Rates {
+		image.Rates = append(image.Rates, raw.ToRate())
+	}
+	for _, raw := range img.Tags {
+		image.Tags = append(image.Tags, raw.ToTag())
+	}
+	for _, raw := range img.Breaks {
+		image.Breaks = append(image.Breaks, raw.ToBreak())
+	}
+	return image
+}
+
+// rowKey identifies one row, by primary key, of the punchcard, paychecks or
+// rates table: stamp is its punch, endclusive or effective, respectively. Of
+// the tags and breaks tables, it identifies all of one session's rows, by its
+// punch-in.
+type rowKey struct {
+	table   string
+	stamp   int64
+	project string
+}
+
+func punchKey(card *CardSchema) rowKey {
+	return rowKey{"punchcard", card.Punch.Unix(), card.Project}
+}
+
+func billKey(bill *BillSchema) rowKey {
+	return rowKey{"paychecks", bill.Endclusive.Unix(), bill.Project}
+}
+
+func rateKey(rate *RateSchema) rowKey {
+	return rowKey{"rates", rate.Effective.Unix(), rate.Project}
+}
+
+// The tags on the session punched in to by punchIn.
+func tagsKey(punchIn *CardSchema) rowKey {
+	return rowKey{"tags", punchIn.Punch.Unix(), punchIn.Project}
+}
+
+// The breaks within the session punched in to by punchIn.
+func breaksKey(punchIn *CardSchema) rowKey {
+	return rowKey{"breaks", punchIn.Punch.Unix(), punchIn.Project}
+}
+
+// Appends key to keys, unless it's already among them.
+func appendKey(keys []rowKey, key rowKey) []rowKey {
+	for _, k := range keys {
+		if k == key {
+			return keys
+		}
+	}
+	return append(keys, key)
+}
+
+// Every table's primary key, as SQL matching a rowKey's stamp then project.
+var rowKeyWhere = map[string]string{
+	"punchcard": "punch IS ? AND project IS ?",
+	"paychecks": "endclusive IS ? AND project IS ?",
+	"rates":     "effective IS ? AND project IS ?",
+	"tags":      "punch IS ? AND project IS ?",
+	"breaks":    "punch IS ? AND project IS ?",
+}
+
+// Reads the rows at keys, skipping any that don't exist.
+func imageOf(tx *sql.Tx, keys []rowKey) (*journalImage, error) {
+	image := &journalImage{}
+	for _, key := range keys {
+		rows, e := tx.Query(fmt.Sprintf(
+			"SELECT * FROM %s WHERE %s;", key.table, rowKeyWhere[key.table]),
+			key.stamp, key.project)
+		if e != nil {
+			return nil, e
+		}
+		for rows.Next() {
+			switch key.table {
+			case "punchcard":
+				raw := &CardSchemaSQL{}
+				e = rows.Scan(&raw.Punch, &raw.Status, &raw.Project, &raw.Note)
+				image.Punches = append(image.Punches, raw)
+			case "paychecks":
+				raw := &BillSchemaSQL{}
+				e = rows.Scan(&raw.Endclusive, &raw.Startclusive, &raw.Project, &raw.Note)
+				image.Bills = append(image.Bills, raw)
+			case "rates":
+				raw := &RateSchemaSQL{}
+				e = rows.Scan(
+					&raw.Project, &raw.Effective, &raw.HourlyCents,
+					&raw.Currency, &raw.RoundMinutes, &raw.PerSession)
+				image.Rates = append(image.Rates, raw)
+			case "tags":
+				raw := &TagSchemaSQL{}
+				e = rows.Scan(&raw.Punch, &raw.Project, &raw.Tag)
+				image.Tags = append(image.Tags, raw)
+			case "breaks":
+				raw := &BreakSchemaSQL{}
+				e = rows.Scan(&raw.Punch, &raw.Project, &raw.Start, &raw.Stop)
+				image.Breaks = append(image.Breaks, raw)
+			}
+			if e != nil {
+				rows.Close()
+				return nil, e
+			}
+		}
+		rows.Close()
+		if e := rows.Err(); e != nil {
+			return nil, e
+		}
+	}
+	sort.Slice(image.Tags, func(i, j int) bool {
+		a, b := image.Tags[i], image.Tags[j]
+		if a.Punch != b.Punch {
+			return a.Punch < b.Punch
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.Tag < b.Tag
+	})
+	sort.Slice(image.Breaks, func(i, j int) bool {
+		a, b := image.Breaks[i], image.Breaks[j]
+		if a.Punch != b.Punch {
+			return a.Punch < b.Punch
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.Start < b.Start
+	})
+	return image, nil
+}
+
+// Runs change, which may only touch the rows at keys, recording them as they
+// were before and after it in the journal (and forgetting any undone changes,
+// which can no longer be redone).
+func (c *Card) journaled(summary string, keys []rowKey, change func(tx *sql.Tx) error) error {
+	return c.Transact(func() error {
+		return c.journalChange(c.tx, summary, keys, change)
+	})
+}
+
+func (c *Card) journalChange(
+	tx *sql.Tx, summary string, keys []rowKey, change func(tx *sql.Tx) error) error {
+	before, e := imageOf(tx, keys)
+	if e != nil {
+		return fmt.Errorf("journaling rows before change: %s", e)
+	}
+	if e := change(tx); e != nil {
+		return e
+	}
+	after, e := imageOf(tx, keys)
+	if e != nil {
+		return fmt.Errorf("journaling rows after change: %s", e)
+	}
+
+	beforeJSON, e := json.Marshal(before)
+	if e != nil {
+		return fmt.Errorf("journaling rows before change: %s", e)
+	}
+	afterJSON, e := json.Marshal(after)
+	if e != nil {
+		return fmt.Errorf("journaling rows after change: %s", e)
+	}
+
+	command := c.Command
+	if len(command) == 0 {
+		command = summary
+	}
+	if _, e := tx.Exec(`DELETE FROM journal WHERE undone IS 1;`); e != nil {
+		return fmt.Errorf("forgetting undone changes: %s", e)
+	}
+	if _, e := tx.Exec(`
+		INSERT INTO
+		journal(at, command, before, after, undone)
+		VALUES (?, ?, ?, ?, 0)
+	`, c.Clock.Now().Unix(), command, string(beforeJSON), string(afterJSON)); e != nil {
+		return fmt.Errorf("journaling change: %s", e)
+	}
+	return nil
+}
+
+// Journal lists the most recent limit changes to the card (or all of them, if
+// limit is not positive), newest first.
+func (c *Card) Journal(limit int) ([]*JournalEntry, error) {
+	if limit <= 0 {
+		limit = -1 // sqlite's "no limit"
+	}
+	return c.queryJournal(`
+		SELECT * FROM journal
+		ORDER BY id DESC
+		LIMIT ?;
+	`, limit)
+}
+
+// Revision identifies the card's state as of its latest journaled change: it
+// differs after any change is made, undone or redone, eg: to tell whether the
+// card changed while awaiting a user's confirmation.
+func (c *Card) Revision() (string, error) {
+	var latest int64
+	var undone sql.NullString
+	if e := c.conn().QueryRow(`
+		SELECT
+			IFNULL(MAX(id), 0),
+			(SELECT group_concat(id) FROM journal WHERE undone IS 1)
+		FROM journal;
+	`).Scan(&latest, &undone); e != nil {
+		return "", fmt.Errorf("reading journal: %s", e)
+	}
+	return fmt.Sprintf("%d/%s", latest, undone.String), nil
+}
+
+// Undo reverts the most recent change not already undone, returning it. As
+// with Redo, rows are restored exactly, bypassing c.Concurrency.
+func (c *Card) Undo() (*JournalEntry, error) {
+	return c.replayNext(`
+		SELECT * FROM journal
+		WHERE undone IS 0
+		ORDER BY id DESC
+		LIMIT 1;
+	`, true /*isUndo*/)
+}
+
+// Redo re-applies the change most recently undone, returning it.
+func (c *Card) Redo() (*JournalEntry, error) {
+	return c.replayNext(`
+		SELECT * FROM journal
+		WHERE undone IS 1
+		ORDER BY id ASC
+		LIMIT 1;
+	`, false /*isUndo*/)
+}
+
+// Replays the journal entry found by query.
+func (c *Card) replayNext(query string, isUndo bool) (*JournalEntry, error) {
+	var entry *JournalEntry
+	e := c.Transact(func() error {
+		entries, e := c.queryJournal(query)
+		if e != nil {
+			return fmt.Errorf("reading journal: %s", e)
+		}
+		if len(entries) == 0 {
+			if isUndo {
+				return fmt.Errorf("nothing to undo")
+			}
+			return fmt.Errorf("nothing to redo")
+		}
+		entry = entries[0]
+		return c.replay(entry, isUndo)
+	})
+	if e != nil {
+		return nil, e
+	}
+	entry.IsUndone = isUndo
+	return entry, nil
+}
+
+// Swaps entry's after-image for its before-image (or vice versa, to redo it),
+// so long as the rows it touched haven't been changed since. Must be run within
+// Transact.
+func (c *Card) replay(entry *JournalEntry, isUndo bool) error {
+	from, to := entry.after, entry.before
+	if !isUndo {
+		from, to = entry.before, entry.after
+	}
+
+	undone, action := 0, "redone"
+	if isUndo {
+		undone, action = 1, "undone"
+	}
+
+	if e := replayImage(c.tx, from, to); e != nil {
+		return fmt.Errorf(
+			"card changed since #%d, so it can't be %s: %s", entry.ID, action, e)
+	}
+	if _, e := c.tx.Exec(`UPDATE journal SET undone = ? WHERE id IS ?;`, undone, entry.ID); e != nil {
+		return fmt.Errorf("updating journal: %s", e)
+	}
+	return nil
+}
+
+func replayImage(tx *sql.Tx, from, to *journalImage) error {
+	var keys []rowKey
+	for _, raw := range from.Punches {
+		keys = append(keys, rowKey{"punchcard", int64(raw.Punch), raw.Project})
+		if raw.Status == 1 {
+			keys = appendKey(keys, rowKey{"tags", int64(raw.Punch), raw.Project})
+			keys = appendKey(keys, rowKey{"breaks", int64(raw.Punch), raw.Project})
+		}
+	}
+	for _, raw := range from.Bills {
+		keys = append(keys, rowKey{"paychecks", int64(raw.Endclusive), raw.Project})
+	}
+	for _, raw := range from.Rates {
+		keys = append(keys, rowKey{"rates", int64(raw.Effective), raw.Project})
+	}
+	for _, raw := range from.Tags {
+		keys = appendKey(keys, rowKey{"tags", int64(raw.Punch), raw.Project})
+	}
+	for _, raw := range from.Breaks {
+		keys = appendKey(keys, rowKey{"breaks", int64(raw.Punch), raw.Project})
+	}
+	current, e := imageOf(tx, keys)
+	if e != nil {
+		return e
+	}
+	if !from.equals(current) {
+		return fmt.Errorf("its rows no longer match")
+	}
+	for _, key := range keys {
+		if _, e := tx.Exec(fmt.Sprintf(
+			"DELETE FROM %s WHERE %s;", key.table, rowKeyWhere[key.table]),
+			key.stamp, key.project); e != nil {
+			return e
+		}
+	}
+
+	for _, raw := range to.Punches {
+		if _, e := tx.Exec(`
+			INSERT INTO punchcard(punch, status, project, note)
+			VALUES (?, ?, ?, ?)
+		`, raw.Punch, raw.Status, raw.Project, raw.Note); e != nil {
+			return e
+		}
+	}
+	for _, raw := range to.Bills {
+		if _, e := tx.Exec(`
+			INSERT INTO paychecks(endclusive, startclusive, project, note)
+			VALUES (?, ?, ?, ?)
+		`, raw.Endclusive, raw.Startclusive, raw.Project, raw.Note); e != nil {
+			return e
+		}
+	}
+	for _, raw := range to.Rates {
+		if _, e := tx.Exec(`
+			INSERT INTO
+			rates(project, effective, hourly_cents, currency, round_minutes, per_session)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, raw.Project, raw.Effective, raw.HourlyCents,
+			raw.Currency, raw.RoundMinutes, raw.PerSession); e != nil {
+			return e
+		}
+	}
+	for _, raw := range to.Tags {
+		if e := insertTag(tx, raw); e != nil {
+			return e
+		}
+	}
+	for _, raw := range to.Breaks {
+		if e := insertBreak(tx, raw); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// Whether img and other hold exactly the same rows, in the same order.
+func (img *journalImage) equals(other *journalImage) bool {
+	if len(img.Punches) != len(other.Punches) ||
+		len(img.Bills) != len(other.Bills) ||
+		len(img.Rates) != len(other.Rates) ||
+		len(img.Tags) != len(other.Tags) ||
+		len(img.Breaks) != len(other.Breaks) {
+		return false
+	}
+	for i := range img.Punches {
+		if *img.Punches[i] != *other.Punches[i] {
+			return false
+		}
+	}
+	for i := range img.Bills {
+		if *img.Bills[i] != *other.Bills[i] {
+			return false
+		}
+	}
+	for i := range img.Rates {
+		if *img.Rates[i] != *other.Rates[i] {
+			return false
+		}
+	}
+	for i := range img.Tags {
+		if *img.Tags[i] != *other.Tags[i] {
+			return false
+		}
+	}
+	for i := range img.Breaks {
+		if *img.Breaks[i] != *other.Breaks[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package punch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUndoRedo(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	notes := func() string {
+		cards, e := c.Cards()
+		if e != nil {
+			t.Fatalf("listing punches: %s", e)
+		}
+		var all string
+		for _, card := range cards {
+			all += FromNote(card.Note) + ","
+		}
+		return all
+	}
+
+	if e := c.AmendNote("acme", epoch, "kickoff"); e != nil {
+		t.Fatalf("amending note: %s", e)
+	}
+	deletion, e := c.PlanPunchDeletion("acme", epoch.Add(time.Hour))
+	if e != nil {
+		t.Fatalf("planning deletion: %s", e)
+	}
+	if e := c.DeletePunches(deletion); e != nil {
+		t.Fatalf("deleting session: %s", e)
+	}
+	if got := notes(); got != "kickoff,n/a,n/a,n/a," {
+		t.Fatalf("got notes %s before undoing", got)
+	}
+
+	entries, e := c.Journal(2)
+	if e != nil {
+		t.Fatalf("reading journal: %s", e)
+	}
+	if len(entries) != 2 || entries[0].Command != "delete 'acme' session" ||
+		len(entries[0].Before.Punches) != 2 || len(entries[0].After.Punches) != 0 {
+		t.Fatalf("expected deletion to be journaled first, got %+v", entries)
+	}
+
+	if undone, e := c.Undo(); e != nil || undone.ID != entries[0].ID {
+		t.Fatalf("undoing deletion: %v (error: %v)", undone, e)
+	}
+	if _, e := c.Undo(); e != nil {
+		t.Fatalf("undoing amendment: %s", e)
+	}
+	if got := notes(); got != "n/a,n/a,n/a,n/a,n/a,n/a," {
+		t.Errorf("got notes %s after undoing both", got)
+	}
+
+	if _, e := c.Redo(); e != nil {
+		t.Fatalf("redoing amendment: %s", e)
+	}
+	if got := notes(); got != "kickoff,n/a,n/a,n/a,n/a,n/a," {
+		t.Errorf("got notes %s after redoing amendment", got)
+	}
+
+	// A new change can't be interleaved with the rest of the undone ones
+	if e := c.AmendNote("acme", epoch.Add(time.Hour), "standup"); e != nil {
+		t.Fatalf("amending note: %s", e)
+	}
+	if _, e := c.Redo(); e == nil {
+		t.Errorf("expected nothing to redo after a new change")
+	}
+
+	// Nor can a change be undone once its rows are changed some other way
+	if _, e := c.db.Exec(`UPDATE punchcard SET note = 'hand-edited' WHERE punch IS ?`,
+		epoch.Add(time.Hour).Unix()); e != nil {
+		t.Fatalf("hand-editing card: %s", e)
+	}
+	if _, e := c.Undo(); e == nil {
+		t.Errorf("expected undoing a since-changed row to fail")
+	}
+
+	if entries, e = c.Journal(0); e != nil {
+		t.Fatalf("reading journal: %s", e)
+	}
+	// 6 punches by pricingCard, then the amendment that was redone and the last
+	if len(entries) != 8 || entries[0].IsUndone || entries[1].IsUndone {
+		t.Errorf("got %d journal entries, latest: %+v", len(entries), entries[0])
+	}
+}
+
+func TestRevision(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	revision := func() string {
+		r, e := c.Revision()
+		if e != nil {
+			t.Fatalf("reading revision: %s", e)
+		}
+		return r
+	}
+	seen := map[string]bool{revision(): true}
+	expectNew := func(change string) {
+		r := revision()
+		if seen[r] {
+			t.Errorf("expected a new revision after %s, but got %s again", change, r)
+		}
+		seen[r] = true
+	}
+
+	if e := c.AmendNote("acme", epoch, "kickoff"); e != nil {
+		t.Fatalf("amending note: %s", e)
+	}
+	expectNew("amending")
+	if _, e := c.Undo(); e != nil {
+		t.Fatalf("undoing: %s", e)
+	}
+	expectNew("undoing")
+	if _, e := c.Undo(); e != nil {
+		t.Fatalf("undoing again: %s", e)
+	}
+	expectNew("undoing again")
+	if _, e := c.Redo(); e != nil {
+		t.Fatalf("redoing: %s", e)
+	}
+	if r := revision(); !seen[r] {
+		t.Errorf("expected redoing to return to a prior revision, got %s", r)
+	}
+}
@@ -0,0 +1,274 @@
+package punch
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Migration is one step in the evolution of a punch card's schema, taking a
+// card from Version-1 to Version.
+type Migration struct {
+	Version     int
+	Description string
+
+	statements []string
+}
+
+// Every schema change ever made, in the order they must be applied; a card's
+// version is the number of these it has had applied, per sqlite's
+// `PRAGMA user_version`.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create punchcard and paychecks tables",
+		statements: []string{`
+CREATE TABLE punchcard (
+  punch       INTEGER NOT NULL PRIMARY KEY,
+  status      INTEGER NOT NULL,
+  project     TEXT NOT NULL,
+  note        TEXT
+);`, `
+CREATE TABLE paychecks (
+  endclusive   INTEGER NOT NULL PRIMARY KEY,
+  startclusive INTEGER NOT NULL,
+  project      TEXT NOT NULL,
+  note         TEXT
+);`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "key punches and paychecks by client too, so clients may share a stamp",
+		statements: []string{`
+CREATE TABLE punchcard_v2 (
+  punch       INTEGER NOT NULL,
+  status      INTEGER NOT NULL,
+  project     TEXT NOT NULL,
+  note        TEXT,
+  PRIMARY KEY (punch, project)
+);`,
+			`INSERT INTO punchcard_v2 SELECT punch, status, project, note FROM punchcard;`,
+			`DROP TABLE punchcard;`,
+			`ALTER TABLE punchcard_v2 RENAME TO punchcard;`, `
+CREATE TABLE paychecks_v2 (
+  endclusive   INTEGER NOT NULL,
+  startclusive INTEGER NOT NULL,
+  project      TEXT NOT NULL,
+  note         TEXT,
+  PRIMARY KEY (endclusive, project)
+);`,
+			`INSERT INTO paychecks_v2 SELECT endclusive, startclusive, project, note FROM paychecks;`,
+			`DROP TABLE paychecks;`,
+			`ALTER TABLE paychecks_v2 RENAME TO paychecks;`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "add rates table, of each client's hourly rate over time",
+		statements: []string{`
+CREATE TABLE rates (
+  project       TEXT NOT NULL,
+  effective     INTEGER NOT NULL,
+  hourly_cents  INTEGER NOT NULL,
+  currency      TEXT NOT NULL,
+  round_minutes INTEGER NOT NULL,
+  per_session   INTEGER NOT NULL,
+  PRIMARY KEY (project, effective)
+);`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "add journal table, of changes made to the card for undo and redo",
+		statements: []string{`
+CREATE TABLE journal (
+  id      INTEGER PRIMARY KEY AUTOINCREMENT,
+  at      INTEGER NOT NULL,
+  command TEXT NOT NULL,
+  before  TEXT NOT NULL,
+  after   TEXT NOT NULL,
+  undone  INTEGER NOT NULL
+);`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "add tags table, of the tags on each session",
+		statements: []string{`
+CREATE TABLE tags (
+  punch   INTEGER NOT NULL,
+  project TEXT NOT NULL,
+  tag     TEXT NOT NULL,
+  PRIMARY KEY (punch, project, tag)
+);`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "add breaks table, of the pauses taken within each session",
+		statements: []string{`
+CREATE TABLE breaks (
+  punch   INTEGER NOT NULL,
+  project TEXT NOT NULL,
+  start   INTEGER NOT NULL,
+  stop    INTEGER,
+  PRIMARY KEY (punch, project, start)
+);`,
+		},
+	},
+}
+
+// Upgrade describes the migrations Open applied to bring a card up to date.
+type Upgrade struct {
+	From, To int
+
+	// Copy of the card as it was before any migrations ran.
+	BackupPath string
+}
+
+// LatestSchemaVersion is the version every card is migrated to on Open.
+func LatestSchemaVersion() int { return len(migrations) }
+
+// SchemaVersion reports how many migrations have been applied to the card.
+//
+// Cards created before versioning was introduced carry no version, but already
+// have the tables of the first migration, so are reported at version 1.
+func (c *Card) SchemaVersion() (int, error) {
+	var version int
+	if e := c.conn().QueryRow("PRAGMA user_version;").Scan(&version); e != nil {
+		return 0, fmt.Errorf("reading schema version: %s", e)
+	}
+	if version > 0 {
+		return version, nil
+	}
+
+	var tables int
+	if e := c.conn().QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type IS 'table' AND name IS 'punchcard';
+	`).Scan(&tables); e != nil {
+		return 0, fmt.Errorf("inspecting unversioned card: %s", e)
+	}
+	if tables > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// PendingMigrations lists, in order, the migrations that have yet to be applied
+// to the card.
+func (c *Card) PendingMigrations() ([]Migration, error) {
+	version, e := c.SchemaVersion()
+	if e != nil {
+		return nil, e
+	}
+	if version > LatestSchemaVersion() {
+		return nil, fmt.Errorf(
+			"card is at schema v%d, but this punch only knows up to v%d",
+			version, LatestSchemaVersion())
+	}
+	return migrations[version:], nil
+}
+
+// Migrate applies every pending migration, returning those applied. Each
+// migration is applied atomically, so a failure leaves the card at the version
+// of the last migration to succeed.
+func (c *Card) Migrate() ([]Migration, error) {
+	pending, e := c.PendingMigrations()
+	if e != nil {
+		return nil, e
+	}
+	return c.applyMigrations(pending)
+}
+
+func (c *Card) applyMigrations(pending []Migration) ([]Migration, error) {
+	for i, m := range pending {
+		if e := c.applyMigration(m); e != nil {
+			return pending[:i], fmt.Errorf(
+				"migrating to v%d (%s): %s", m.Version, m.Description, e)
+		}
+	}
+	return pending, nil
+}
+
+func (c *Card) applyMigration(m Migration) error {
+	tx, e := c.db.Begin()
+	if e != nil {
+		return e
+	}
+	for _, stmt := range m.statements {
+		if _, e := tx.Exec(stmt); e != nil {
+			tx.Rollback()
+			return e
+		}
+	}
+	if _, e := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d;", m.Version)); e != nil {
+		tx.Rollback()
+		return e
+	}
+	return tx.Commit()
+}
+
+// Backup copies the card's database file alongside itself, returning the path
+// of the copy. Existing backups are never overwritten.
+func (c *Card) Backup() (string, error) {
+	version, e := c.SchemaVersion()
+	if e != nil {
+		return "", e
+	}
+
+	src, e := os.Open(c.path)
+	if e != nil {
+		return "", fmt.Errorf("opening card for backup: %s", e)
+	}
+	defer src.Close()
+
+	base := fmt.Sprintf("%s.v%d.bak", c.path, version)
+	backupPath := base
+	var dst *os.File
+	for i := 1; ; i++ {
+		dst, e = os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if e == nil {
+			break
+		}
+		if !os.IsExist(e) {
+			return "", fmt.Errorf("creating backup: %s", e)
+		}
+		backupPath = fmt.Sprintf("%s.%d", base, i)
+	}
+
+	if _, e := io.Copy(dst, src); e != nil {
+		dst.Close()
+		return "", fmt.Errorf("writing backup '%s': %s", backupPath, e)
+	}
+	if e := dst.Close(); e != nil {
+		return "", fmt.Errorf("writing backup '%s': %s", backupPath, e)
+	}
+	return backupPath, nil
+}
+
+// Backs up then migrates the card, if it has any pending migrations.
+func (c *Card) upgrade() (*Upgrade, error) {
+	pending, e := c.PendingMigrations()
+	if e != nil {
+		return nil, e
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	from := pending[0].Version - 1
+	up := &Upgrade{From: from, To: from}
+	if from > 0 {
+		if up.BackupPath, e = c.Backup(); e != nil {
+			return nil, e
+		}
+	}
+
+	applied, e := c.applyMigrations(pending)
+	if len(applied) > 0 {
+		up.To = applied[len(applied)-1].Version
+	}
+	return up, e
+}
@@ -0,0 +1,257 @@
+package punch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Copies testdata/sample.card, a card from before schema versioning, into a
+// temporary directory.
+func copySampleCard(t *testing.T) (string, func()) {
+	dir, e := ioutil.TempDir("", "punch-migrate-test")
+	if e != nil {
+		t.Fatalf("creating temp dir: %s", e)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	contents, e := ioutil.ReadFile("testdata/sample.card")
+	if e != nil {
+		cleanup()
+		t.Fatalf("reading sample card: %s", e)
+	}
+	dbPath := filepath.Join(dir, "punchcard")
+	if e := ioutil.WriteFile(dbPath, contents, 0600); e != nil {
+		cleanup()
+		t.Fatalf("copying sample card: %s", e)
+	}
+	return dbPath, cleanup
+}
+
+func expectSchemaVersion(t *testing.T, c *Card, expected int) {
+	version, e := c.SchemaVersion()
+	if e != nil {
+		t.Fatalf("reading schema version: %s", e)
+	}
+	if version != expected {
+		t.Errorf("got schema v%d, expected v%d", version, expected)
+	}
+}
+
+// Appends extra to the known migrations until the returned func is called.
+func withMigrations(extra ...Migration) func() {
+	original := migrations
+	migrations = append(append([]Migration{}, original...), extra...)
+	return func() { migrations = original }
+}
+
+func TestCreateIsLatestSchema(t *testing.T) {
+	dir, e := ioutil.TempDir("", "punch-migrate-test")
+	if e != nil {
+		t.Fatalf("creating temp dir: %s", e)
+	}
+	defer os.RemoveAll(dir)
+
+	c, e := Create(filepath.Join(dir, "punchcard"))
+	if e != nil {
+		t.Fatalf("creating card: %s", e)
+	}
+	defer c.Close()
+
+	expectSchemaVersion(t, c, LatestSchemaVersion())
+	if pending, e := c.PendingMigrations(); e != nil || len(pending) != 0 {
+		t.Errorf("expected no pending migrations, got %d (error: %v)", len(pending), e)
+	}
+}
+
+func TestUnversionedCardIsFirstSchema(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+
+	c, e := OpenUnmigrated(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+	expectSchemaVersion(t, c, 1)
+}
+
+func TestOpenBacksUpAndMigrates(t *testing.T) {
+	defer withMigrations(Migration{
+		Version:     LatestSchemaVersion() + 1,
+		Description: "add a scratch table",
+		statements:  []string{"CREATE TABLE scratch (id INTEGER);"},
+	})()
+
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	original, e := ioutil.ReadFile(dbPath)
+	if e != nil {
+		t.Fatalf("reading card: %s", e)
+	}
+
+	c, e := Open(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+
+	expectSchemaVersion(t, c, LatestSchemaVersion())
+	up := c.Upgrade
+	if up == nil {
+		t.Fatalf("expected Open to report an upgrade")
+	}
+	if up.From != 1 || up.To != LatestSchemaVersion() {
+		t.Errorf("got upgrade v%d to v%d", up.From, up.To)
+	}
+	if up.BackupPath != dbPath+".v1.bak" {
+		t.Errorf("got backup path '%s'", up.BackupPath)
+	}
+	backup, e := ioutil.ReadFile(up.BackupPath)
+	if e != nil {
+		t.Fatalf("reading backup: %s", e)
+	}
+	if string(backup) != string(original) {
+		t.Errorf("backup differs from the card as it was before migrating")
+	}
+
+	c.Close()
+	if c, e = Open(dbPath); e != nil {
+		t.Fatalf("reopening card: %s", e)
+	}
+	if c.Upgrade != nil {
+		t.Errorf("expected no upgrade of an up to date card, got %+v", *c.Upgrade)
+	}
+}
+
+func TestBackupNeverOverwrites(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+
+	c, e := OpenUnmigrated(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+
+	for _, expected := range []string{".v1.bak", ".v1.bak.1", ".v1.bak.2"} {
+		backup, e := c.Backup()
+		if e != nil {
+			t.Fatalf("backing up: %s", e)
+		}
+		if backup != dbPath+expected {
+			t.Errorf("got backup '%s', expected suffix '%s'", backup, expected)
+		}
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	c, e := Open(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	c.Close()
+
+	v := LatestSchemaVersion()
+	defer withMigrations(
+		Migration{
+			Version:     v + 1,
+			Description: "good",
+			statements:  []string{"CREATE TABLE good (id INTEGER);"},
+		},
+		Migration{
+			Version:     v + 2,
+			Description: "bad",
+			statements: []string{
+				"CREATE TABLE half (id INTEGER);",
+				"CREATE TABLE good (id INTEGER);",
+			},
+		},
+	)()
+
+	if c, e = OpenUnmigrated(dbPath); e != nil {
+		t.Fatalf("reopening card: %s", e)
+	}
+	defer c.Close()
+
+	applied, e := c.Migrate()
+	if expected := fmt.Sprintf("migrating to v%d (bad)", v+2); e == nil ||
+		!strings.Contains(e.Error(), expected) {
+		t.Errorf("expected v%d to fail, got: %v", v+2, e)
+	}
+	if len(applied) != 1 || applied[0].Version != v+1 {
+		t.Errorf("expected only v%d to apply, got %d migrations", v+1, len(applied))
+	}
+	expectSchemaVersion(t, c, v+1)
+
+	var tables int
+	if e := c.db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE name IS 'half';",
+	).Scan(&tables); e != nil {
+		t.Fatalf("inspecting tables: %s", e)
+	}
+	if tables != 0 {
+		t.Errorf("expected failed migration's statements to be rolled back")
+	}
+}
+
+func TestNewerCardIsRefused(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	func() {
+		defer withMigrations(Migration{Version: LatestSchemaVersion() + 1})()
+		c, e := Open(dbPath)
+		if e != nil {
+			t.Fatalf("opening card: %s", e)
+		}
+		c.Close()
+	}()
+
+	if _, e := Open(dbPath); e == nil ||
+		!strings.Contains(e.Error(), "this punch only knows up to") {
+		t.Errorf("expected newer card to be refused, got: %v", e)
+	}
+}
+
+func TestClientsMayShareStamps(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	c, e := Open(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+
+	cards, e := c.Cards()
+	if e != nil {
+		t.Fatalf("listing cards: %s", e)
+	}
+	if len(cards) != 30 {
+		t.Errorf("expected migration to keep all 30 sample punches, got %d", len(cards))
+	}
+
+	// Shortly after the sample card's last punch
+	c.Clock = FixedClock(time.Unix(1491970000, 0))
+	at := time.Unix(1491969000, 0)
+	for _, client := range []string{"golangpunch", "spaceship"} {
+		if _, e := c.PunchAt(client, "", at); e != nil {
+			t.Errorf("punching %s in at shared stamp: %s", client, e)
+		}
+	}
+	for _, client := range []string{"golangpunch", "spaceship"} {
+		bill := &BillSchema{
+			Startclusive: time.Unix(1491960000, 0),
+			Endclusive:   at,
+			Project:      client,
+		}
+		if e := c.CreateBill(bill); e != nil {
+			t.Errorf("billing %s up to shared stamp: %s", client, e)
+		}
+	}
+}
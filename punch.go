@@ -0,0 +1,326 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ImpliedClient returns the one client currently punched into, failing if there
+// is not exactly one such client.
+func (c *Card) ImpliedClient() (string, error) {
+	open, e := c.OpenPunches()
+	if e != nil {
+		return "", fmt.Errorf("punch cards: %s", e)
+	}
+
+	errorMsgIntent := "implying one CLIENT is on clock"
+
+	switch len(open) {
+	case 0:
+		return "", fmt.Errorf("%s, but none are", errorMsgIntent)
+	case 1:
+		return open[0].Project, nil
+	default:
+		return "", fmt.Errorf("%s, but found 2: '%s' & '%s'",
+			errorMsgIntent, open[0].Project, open[1].Project)
+	}
+}
+
+// LastPunch returns the most recent punch on client, or nil if client has none.
+func (c *Card) LastPunch(client string) (*CardSchema, error) {
+	cards, e := c.queryCards(`
+		SELECT * FROM punchcard
+		WHERE project IS ?
+		ORDER BY punch DESC
+		LIMIT 1;
+	`, client)
+	if e != nil {
+		return nil, e
+	}
+	if len(cards) == 0 {
+		return nil, nil
+	}
+	return cards[0], nil
+}
+
+// Finds the punches at exactly `at` on client, or on any client if client is
+// empty.
+func (c *Card) punchesAt(client string, at time.Time) ([]*CardSchema, error) {
+	return c.queryCards(`
+		SELECT * FROM punchcard
+		WHERE punch IS ?
+		AND (? IS '' OR project IS ?)
+		ORDER BY project ASC;
+	`, at.Unix(), client, client)
+}
+
+// Picks the one punch in cards, failing if there are none or if several
+// clients' punches, described by `what`, are indistinguishable.
+func onePunch(cards []*CardSchema, what string) (*CardSchema, error) {
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("No punches found matching %s", what)
+	}
+	if len(cards) > 1 {
+		return nil, fmt.Errorf(
+			"ambiguous: clients %s all have punches matching %s; specify a CLIENT",
+			quotedClients(cards), what)
+	}
+	return cards[0], nil
+}
+
+// Lists the clients of cards, quoted, eg: "'acme' & 'beta'".
+func quotedClients(cards []*CardSchema) string {
+	clients := make([]string, len(cards))
+	for i, card := range cards {
+		clients[i] = fmt.Sprintf("'%s'", card.Project)
+	}
+	return strings.Join(clients, " & ")
+}
+
+func insertCard(tx *sql.Tx, card *CardSchemaSQL) error {
+	stmt, e := tx.Prepare(`
+		INSERT INTO
+		punchcard(punch, status, project, note)
+		VALUES (?, ?, ?, ?)
+	`)
+	if e != nil {
+		return e
+	}
+
+	_, e = stmt.Exec(card.Punch, card.Status, card.Project, card.Note)
+	return e
+}
+
+// Punch punches client in or out (whichever is due) and returns the resulting
+// record. An empty client implies punching out of the one client currently on
+// the clock.
+func (c *Card) Punch(client string, note string) (*CardSchema, error) {
+	return c.PunchAt(client, note, time.Time{})
+}
+
+// PunchAt is Punch, but stamped `at` rather than now, if `at` is not the zero
+// value. `at` cannot be in the future, nor at or before client's last punch.
+// Any tags are added to the session punched in to, or out of.
+func (c *Card) PunchAt(
+	client string, note string, at time.Time, tags ...string) (*CardSchema, error) {
+	var card *CardSchema
+	e := c.Transact(func() error {
+		var e error
+		card, e = c.punchAt(client, note, at, tags)
+		return e
+	})
+	return card, e
+}
+
+// PunchAt, but within a transaction, as it only decides whether to punch in or
+// out from client's last punch.
+func (c *Card) punchAt(
+	client string, note string, at time.Time, tags []string) (*CardSchema, error) {
+	tags, e := cleanTags(tags)
+	if e != nil {
+		return nil, e
+	}
+	if at, e = c.punchStamp(at); e != nil {
+		return nil, e
+	}
+
+	isImplicitPunchOut := len(client) == 0
+	if !isImplicitPunchOut && !IsValidClient(client) {
+		return nil, fmt.Errorf("invalid CLIENT, '%s'", client)
+	}
+	if isImplicitPunchOut {
+		implied, e := c.ImpliedClient()
+		if e != nil {
+			return nil, e
+		}
+		client = implied
+	}
+
+	p, e := c.planPunch(client, !isImplicitPunchOut, note, at, tags)
+	if e != nil {
+		return nil, e
+	}
+	if p.card.IsStart { // closing a session only ever shrinks its overlaps
+		if e := c.checkExclusive(client, at, time.Time{}, time.Time{}); e != nil {
+			return nil, e
+		}
+	}
+	summary := fmt.Sprintf("punch-%s '%s'", FromStatus(p.card.IsStart), client)
+	if e := c.journaled(summary, p.keys(nil), p.apply); e != nil {
+		return nil, e
+	}
+	return p.card, nil
+}
+
+// Resolves at, the stamp of a punch about to be made, to now if it's the zero
+// value, failing if it's in the future.
+func (c *Card) punchStamp(at time.Time) (time.Time, error) {
+	now := c.Clock.Now()
+	if at.IsZero() {
+		return time.Unix(now.Unix(), 0 /*nanoseconds*/), nil
+	}
+	if at.After(now) {
+		return at, fmt.Errorf(
+			"cannot punch in the future, %s from now", at.Sub(now).Truncate(time.Second))
+	}
+	return at, nil
+}
+
+// A punch about to be made.
+type punchPlan struct {
+	sqlCard *CardSchemaSQL
+	card    *CardSchema
+	session *CardSchema // punch-in of the session being punched in to, or out of
+	tags    []string
+	paused  *BreakSchema // break the session's still paused on, if punching out
+}
+
+// Plans punching client at `at`: in, if canPunchIn and it's due, otherwise
+// out, ending any break the session's paused on. Fails if at isn't after
+// client's last punch.
+func (c *Card) planPunch(
+	client string, canPunchIn bool, note string, at time.Time, tags []string) (*punchPlan, error) {
+	last, e := c.LastPunch(client)
+	if e != nil {
+		return nil, e
+	}
+	isPunchIn := canPunchIn && (last == nil || !last.IsStart)
+
+	if last != nil && !last.Punch.Before(at) {
+		return nil, fmt.Errorf(
+			"punch-%s at %s must come after '%s' punch-%s at %s",
+			FromStatus(isPunchIn),
+			at.Format(FormatDateTime),
+			client,
+			FromStatus(last.IsStart),
+			last.Punch.Format(FormatDateTime))
+	}
+
+	p := &punchPlan{sqlCard: buildCardSQL(isPunchIn, client, note, at), tags: tags}
+	p.card = p.sqlCard.ToCard()
+	p.session = last
+	if isPunchIn {
+		p.session = p.card
+		return p, nil
+	}
+
+	breaks, e := c.breaksOf(last)
+	if e != nil {
+		return nil, e
+	}
+	if p.paused, e = endingBreaks(client, "punch-out", breaks, at); e != nil {
+		return nil, e
+	}
+	return p, nil
+}
+
+// Appends the rows p touches to keys.
+func (p *punchPlan) keys(keys []rowKey) []rowKey {
+	return append(keys, punchKey(p.card), tagsKey(p.session), breaksKey(p.session))
+}
+
+func (p *punchPlan) apply(tx *sql.Tx) error {
+	if e := insertCard(tx, p.sqlCard); e != nil {
+		return e
+	}
+	if p.paused != nil {
+		if e := resumeBreak(tx, p.paused, p.card.Punch); e != nil {
+			return fmt.Errorf("ending session's break: %s", e)
+		}
+	}
+	for _, tag := range p.tags {
+		if e := insertTag(tx, (&TagSchema{p.session.Punch, p.card.Project, tag}).ToSQL()); e != nil {
+			return fmt.Errorf("tagging session: %s", e)
+		}
+	}
+	return nil
+}
+
+// Switch punches out of the one client currently on the clock and into client,
+// at the same instant, returning the punches made: any punch-outs, then the
+// punch-in. If more than one client is on the clock, it fails unless all is
+// set, to punch out of every one of them.
+func (c *Card) Switch(client string, note string, all bool) ([]*CardSchema, error) {
+	return c.SwitchAt(client, note, time.Time{}, all)
+}
+
+// SwitchAt is Switch, but stamped `at` rather than now, per PunchAt. Any tags
+// are added to the session switched to.
+func (c *Card) SwitchAt(
+	client string, note string, at time.Time, all bool, tags ...string) ([]*CardSchema, error) {
+	var cards []*CardSchema
+	e := c.Transact(func() error {
+		var e error
+		cards, e = c.switchAt(client, note, at, all, tags)
+		return e
+	})
+	return cards, e
+}
+
+func (c *Card) switchAt(
+	client string, note string, at time.Time, all bool, tags []string) ([]*CardSchema, error) {
+	tags, e := cleanTags(tags)
+	if e != nil {
+		return nil, e
+	}
+	if at, e = c.punchStamp(at); e != nil {
+		return nil, e
+	}
+	if !IsValidClient(client) {
+		return nil, fmt.Errorf("invalid CLIENT, '%s'", client)
+	}
+
+	open, e := c.OpenPunches()
+	if e != nil {
+		return nil, fmt.Errorf("punch cards: %s", e)
+	}
+	if len(open) > 1 && !all {
+		return nil, fmt.Errorf(
+			"switching from one CLIENT on clock, but found %d: %s (see --all)",
+			len(open), quotedClients(open))
+	}
+
+	var plans []*punchPlan
+	for _, card := range open {
+		if card.Project == client {
+			return nil, fmt.Errorf("already punched into '%s'", client)
+		}
+		p, e := c.planPunch(card.Project, false /*canPunchIn*/, "", at, nil)
+		if e != nil {
+			return nil, e
+		}
+		plans = append(plans, p)
+	}
+	in, e := c.planPunch(client, true /*canPunchIn*/, note, at, tags)
+	if e != nil {
+		return nil, e
+	}
+	if e := c.checkExclusive(client, at, time.Time{}, at /*openUntil*/); e != nil {
+		return nil, e
+	}
+	plans = append(plans, in)
+
+	summary := fmt.Sprintf("switch to '%s'", client)
+	if len(open) > 0 {
+		summary = fmt.Sprintf("switch from %s to '%s'", quotedClients(open), client)
+	}
+	var keys []rowKey
+	var cards []*CardSchema
+	for _, p := range plans {
+		keys = p.keys(keys)
+		cards = append(cards, p.card)
+	}
+	if e := c.journaled(summary, keys, func(tx *sql.Tx) error {
+		for _, p := range plans {
+			if e := p.apply(tx); e != nil {
+				return e
+			}
+		}
+		return nil
+	}); e != nil {
+		return nil, e
+	}
+	return cards, nil
+}
@@ -0,0 +1,202 @@
+package punch
+
+import (
+	"sort"
+	"time"
+)
+
+// Clients lists every client with records on the punch card.
+func (c *Card) Clients() ([]string, error) {
+	rows, e := c.conn().Query(`
+		SELECT DISTINCT(project) as project
+		FROM punchcard ORDER BY project ASC;
+	`)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var clients []string
+	for rows.Next() {
+		var client string
+		if e := rows.Scan(&client); e != nil {
+			return nil, e
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+// Cards returns every punch on the card, oldest first.
+func (c *Card) Cards() ([]*CardSchema, error) {
+	return c.queryCards(`SELECT * FROM punchcard ORDER BY punch ASC, project ASC;`)
+}
+
+// LastPunches returns the most recent punch for each client.
+func (c *Card) LastPunches() ([]*CardSchema, error) {
+	return c.queryCards(`
+		SELECT * FROM punchcard AS c
+		WHERE punch IS (
+			SELECT MAX(punch) FROM punchcard
+			WHERE project IS c.project
+		)
+		ORDER BY punch DESC, project ASC;
+	`)
+}
+
+// OpenPunches returns the punch-in of every session still on the clock.
+func (c *Card) OpenPunches() ([]*CardSchema, error) {
+	last, e := c.LastPunches()
+	if e != nil {
+		return nil, e
+	}
+
+	var open []*CardSchema
+	for _, card := range last {
+		if card.IsStart {
+			open = append(open, card)
+		}
+	}
+	return open, nil
+}
+
+// ClientReport is the sessions logged for one client.
+type ClientReport struct {
+	Client string
+	From   time.Time // zero value if all of history was considered
+
+	Sessions []*Session
+
+	// Punch-outs found with no punch-in preceding them.
+	Strays []*CardSchema
+
+	// Non-nil if Client is still punched in.
+	Open       *CardSchema
+	OpenTags   []string
+	OpenBreaks []*BreakSchema
+}
+
+// Total is the duration worked across Sessions, not including any Open session.
+func (r *ClientReport) Total() time.Duration {
+	var total time.Duration
+	for _, s := range r.Sessions {
+		total += s.Duration
+	}
+	return total
+}
+
+// Report pairs punches on client, since from (or all of history, if from is the
+// zero value), into sessions, keeping only those (and any Open session) tagged
+// with every one of tags.
+func (c *Card) Report(client string, from time.Time, tags ...string) (*ClientReport, error) {
+	var fromStamp int64
+	if !from.IsZero() {
+		fromStamp = from.Unix()
+	}
+
+	cards, e := c.queryCards(`
+		SELECT * FROM punchcard
+		WHERE project IS ?
+		AND punch >= ?
+		ORDER BY punch ASC;
+	`, client, fromStamp)
+	if e != nil {
+		return nil, e
+	}
+	sessionTags, e := c.clientTags(client)
+	if e != nil {
+		return nil, e
+	}
+	sessionBreaks, e := c.clientBreaks(client)
+	if e != nil {
+		return nil, e
+	}
+
+	report := &ClientReport{Client: client, From: from}
+	var punchIn *CardSchema
+	for i, card := range cards {
+		if card.IsStart {
+			punchIn = card
+			continue
+		}
+
+		if i == 0 && !from.IsZero() {
+			continue // closes a session that started before `from`
+		}
+		if punchIn == nil {
+			report.Strays = append(report.Strays, card)
+			continue
+		}
+		session := punchIn.ToSession(card)
+		session.Tags = sessionTags.Of(punchIn)
+		if session.HasTags(tags...) {
+			report.Sessions = append(report.Sessions, session)
+		}
+		punchIn = nil
+	}
+	if punchIn != nil && hasTags(sessionTags.Of(punchIn), tags) {
+		report.Open, report.OpenTags = punchIn, sessionTags.Of(punchIn)
+		report.OpenBreaks = sessionBreaks.Of(punchIn)
+	}
+
+	sessionBreaks.Take(client, report.Sessions)
+	if e := c.SplitSessions(report.Sessions); e != nil {
+		return nil, e
+	}
+	return report, nil
+}
+
+// ClientSummary is the sessions logged for one client across all of history.
+type ClientSummary struct {
+	Client   string
+	Sessions []*Session
+	Last     CardSchema // the last punch-in, if still open, else the empty card
+}
+
+func (s *ClientSummary) Total() time.Duration {
+	var total time.Duration
+	for _, session := range s.Sessions {
+		total += session.Duration
+	}
+	return total
+}
+
+func (s *ClientSummary) IsWorking() bool {
+	return !s.Last.IsEmptyCard() && s.Last.IsStart
+}
+
+// Summarize pairs `cards`, expected in chronological order, into sessions per
+// client, skipping stray punch-outs. Only clients with at least one full session
+// are included, ordered by client name.
+func Summarize(cards []*CardSchema) []*ClientSummary {
+	lastPunchInFor := make(map[string]CardSchema)
+	sessionsFor := make(map[string][]*Session)
+	for _, punch := range cards {
+		if punch.IsStart {
+			lastPunchInFor[punch.Project] = *punch
+		} else {
+			lastPunch := lastPunchInFor[punch.Project]
+			if lastPunch.IsEmptyCard() {
+				continue // no punch-in to pair with
+			}
+			sessionsFor[punch.Project] = append(
+				sessionsFor[punch.Project],
+				(&lastPunch).ToSession(punch))
+			lastPunchInFor[punch.Project] = CardSchema{}
+		}
+	}
+
+	var summaries []*ClientSummary
+	for project, sessions := range sessionsFor {
+		summaries = append(summaries, &ClientSummary{
+			Client:   project,
+			Sessions: sessions,
+			Last:     lastPunchInFor[project],
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Client < summaries[j].Client
+	})
+	return summaries
+}
@@ -0,0 +1,159 @@
+package punch
+
+import (
+	"fmt"
+	"time"
+)
+
+// Grouping is the span of calendar time a RangeReport totals sessions over.
+type Grouping string
+
+const (
+	GroupByDay   Grouping = "day"
+	GroupByWeek  Grouping = "week" // starting Mondays
+	GroupByMonth Grouping = "month"
+)
+
+func ParseGrouping(name string) (Grouping, error) {
+	switch g := Grouping(name); g {
+	case GroupByDay, GroupByWeek, GroupByMonth:
+		return g, nil
+	}
+	return GroupByDay, fmt.Errorf("expected day, week or month, but got '%s'", name)
+}
+
+// Start is the beginning of the day, week or month containing t, in t's
+// location.
+func (g Grouping) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch g {
+	case GroupByWeek:
+		sinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-sinceMonday, 0, 0, 0, 0, t.Location())
+	case GroupByMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// Label renders the group starting at start, eg: "2017-04-10" or "2017-04".
+func (g Grouping) Label(start time.Time) string {
+	if g == GroupByMonth {
+		return start.Format("2006-01")
+	}
+	return start.Format("2006-01-02")
+}
+
+// RangeGroup is the sessions one client worked within one day, week or month;
+// sessions spanning more than one are split between them.
+type RangeGroup struct {
+	Client   string
+	Start    time.Time // of the day, week or month
+	Sessions []*Session
+
+	punchIns []time.Time // of the session each of Sessions is a piece of
+}
+
+// SessionCount is how many sessions were worked within g, however many pieces
+// each was split into.
+func (g *RangeGroup) SessionCount() int { return len(g.punchIns) }
+
+func (g *RangeGroup) Total() time.Duration {
+	var total time.Duration
+	for _, s := range g.Sessions {
+		total += s.Duration
+	}
+	return total
+}
+
+// RangeReport is the work logged on every client within [From, To).
+type RangeReport struct {
+	From, To time.Time
+	Grouping Grouping
+
+	// By client, then Start
+	Groups []*RangeGroup
+
+	// Sessions still open that started before To; none of their time is counted
+	// in Groups.
+	Open []*CardSchema
+}
+
+// SessionCount is how many of client's sessions (or every client's, if client
+// is empty) were worked within the range, each counted once, however many
+// groups it was split between.
+func (r *RangeReport) SessionCount(client string) int {
+	counted := make(map[sessionKey]bool)
+	for _, g := range r.Groups {
+		if len(client) > 0 && g.Client != client {
+			continue
+		}
+		for _, punchIn := range g.punchIns {
+			counted[sessionKey{punchIn.Unix(), g.Client}] = true
+		}
+	}
+	return len(counted)
+}
+
+// Total is the duration worked across all Groups.
+func (r *RangeReport) Total() time.Duration {
+	var total time.Duration
+	for _, g := range r.Groups {
+		total += g.Total()
+	}
+	return total
+}
+
+// ReportRange totals every client's sessions within [from, to), clipping those
+// that straddle either end, grouped by the day, week or month they were worked
+// in (per the location of from). Only sessions tagged with every one of tags are
+// included.
+func (c *Card) ReportRange(
+	from, to time.Time, grouping Grouping, tags ...string) (*RangeReport, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("expected FROM to be older stamp than TO")
+	}
+
+	clients, e := c.Clients()
+	if e != nil {
+		return nil, e
+	}
+
+	report := &RangeReport{From: from, To: to, Grouping: grouping}
+	for _, client := range clients {
+		clientReport, e := c.Report(client, time.Time{}, tags...)
+		if e != nil {
+			return nil, fmt.Errorf("reporting on '%s' sessions: %s", client, e)
+		}
+
+		var group *RangeGroup
+		for _, s := range clientReport.Sessions {
+			clipped := s.Clip(from, to)
+			if clipped == nil {
+				continue
+			}
+			var piece *Session // of s, within group
+			for _, day := range clipped.In(from.Location()).SplitDays() {
+				start := grouping.Start(day.StartAt)
+				if group == nil || !group.Start.Equal(start) {
+					group = &RangeGroup{Client: client, Start: start}
+					report.Groups = append(report.Groups, group)
+					piece = nil
+				}
+				if piece != nil { // the same week or month as its previous day
+					piece.StopAt = day.StopAt
+					piece.Duration += day.Duration
+					continue
+				}
+				piece = day
+				group.Sessions = append(group.Sessions, piece)
+				group.punchIns = append(group.punchIns, s.StartAt)
+			}
+		}
+
+		if open := clientReport.Open; open != nil && open.Punch.Before(to) {
+			report.Open = append(report.Open, open)
+		}
+	}
+	return report, nil
+}
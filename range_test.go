@@ -0,0 +1,137 @@
+package punch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupingStart(t *testing.T) {
+	// A Sunday afternoon
+	at := time.Date(2017, 4, 9, 15, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		grouping Grouping
+		expected time.Time
+		label    string
+	}{
+		{GroupByDay, time.Date(2017, 4, 9, 0, 0, 0, 0, time.UTC), "2017-04-09"},
+		{GroupByWeek, time.Date(2017, 4, 3, 0, 0, 0, 0, time.UTC), "2017-04-03"},
+		{GroupByMonth, time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC), "2017-04"},
+	} {
+		start := tt.grouping.Start(at)
+		if !start.Equal(tt.expected) || tt.grouping.Label(start) != tt.label {
+			t.Errorf("%s: got start %s (%s), expected %s (%s)",
+				tt.grouping, start, tt.grouping.Label(start), tt.expected, tt.label)
+		}
+	}
+
+	// Mondays start their own week, and month boundaries roll back a year
+	monday := time.Date(2017, 1, 2, 9, 0, 0, 0, time.UTC)
+	if start := GroupByWeek.Start(monday); !start.Equal(monday.Add(-time.Hour * 9)) {
+		t.Errorf("got week of a monday starting %s", start)
+	}
+	if start := GroupByWeek.Start(monday.AddDate(0, 0, -1)); start.Year() != 2016 {
+		t.Errorf("got week of new year's day starting %s", start)
+	}
+
+	if _, e := ParseGrouping("year"); e == nil {
+		t.Errorf("expected unknown grouping to be an error")
+	}
+}
+
+func TestReportRange(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	c, e := Open(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+	c.Clock = FixedClock(time.Unix(1491970000, 0))
+
+	// Still open, and within the range below
+	if _, e := c.PunchAt("golangpunch", "", time.Unix(1491969000, 0)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+
+	// 2017-04-09 to 2017-04-12 (UTC); clips a session at either end
+	report, e := c.ReportRange(
+		time.Unix(1491696000, 0), time.Unix(1491955200, 0), GroupByMonth)
+	if e != nil {
+		t.Fatalf("reporting range: %s", e)
+	}
+
+	worked := make(map[string]time.Duration)
+	sessions := make(map[string]int)
+	for _, g := range report.Groups {
+		worked[g.Client] += g.Total()
+		sessions[g.Client] += len(g.Sessions)
+	}
+	for client, expected := range map[string]struct {
+		sessions int
+		worked   time.Duration
+	}{
+		"golangpunch": {3, time.Second * 636},
+		"spaceship":   {6, time.Second * 17754},
+	} {
+		if sessions[client] != expected.sessions || worked[client] != expected.worked {
+			t.Errorf("%s: got %d sessions totaling %s, expected %d totaling %s",
+				client, sessions[client], worked[client], expected.sessions, expected.worked)
+		}
+	}
+	if report.Total() != time.Second*18390 {
+		t.Errorf("got total %s", report.Total())
+	}
+	if len(report.Open) != 0 {
+		t.Errorf("expected session opened after the range to be ignored, got %d", len(report.Open))
+	}
+
+	if report, e = c.ReportRange(
+		time.Unix(1491696000, 0), time.Unix(1491970000, 0), GroupByDay); e != nil {
+		t.Fatalf("reporting range: %s", e)
+	}
+	if len(report.Open) != 1 || report.Open[0].Project != "golangpunch" {
+		t.Errorf("expected golangpunch's open session to be reported, got %v", report.Open)
+	}
+
+	if _, e := c.ReportRange(
+		time.Unix(1491955200, 0), time.Unix(1491696000, 0), GroupByDay); e == nil {
+		t.Errorf("expected backwards range to be an error")
+	}
+}
+
+func TestReportRangeAcrossMidnight(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	// 2017-03-31 23:50 to 2017-04-01 00:10 (UTC)
+	for _, punch := range []time.Duration{time.Minute * 70, time.Minute * 90} {
+		if _, e := c.PunchAt("beta", "", epoch.Add(punch)); e != nil {
+			t.Fatalf("punching at +%s: %s", punch, e)
+		}
+	}
+
+	from := epoch.In(time.UTC)
+	report, e := c.ReportRange(from, from.Add(time.Hour*5), GroupByDay)
+	if e != nil {
+		t.Fatalf("reporting range: %s", e)
+	}
+	var days int
+	for _, g := range report.Groups {
+		if g.Client != "beta" {
+			continue
+		}
+		days++
+		if g.SessionCount() != 1 || g.Total() != time.Minute*10 {
+			t.Errorf("%s: got %d sessions totaling %s, expected 1 totaling 10m",
+				g.Start, g.SessionCount(), g.Total())
+		}
+	}
+	if days != 2 {
+		t.Errorf("expected beta's session split between 2 days, got %d", days)
+	}
+	if n := report.SessionCount("beta"); n != 1 {
+		t.Errorf("expected beta's session counted once, got %d", n)
+	}
+	if n := report.SessionCount(""); n != 4 {
+		t.Errorf("expected 4 sessions across clients, got %d", n)
+	}
+}
@@ -0,0 +1,185 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Money is an amount in hundredths (eg: cents) of Currency.
+type Money struct {
+	Cents    int64
+	Currency string // ISO-4217 code, eg: "USD"
+}
+
+// Renders m as eg: "1234.50 USD"
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", m.Decimal(), m.Currency)
+}
+
+// Decimal renders m without its currency, eg: "1234.50"
+func (m Money) Decimal() string {
+	sign := ""
+	cents := m.Cents
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+var amountRegexp *regexp.Regexp = regexp.MustCompile(`^(\d+)(\.(\d{1,2}))?$`)
+
+var currencyRegexp *regexp.Regexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ParseMoney reads a non-negative amount, like "85" or "85.5", of currency.
+func ParseMoney(amount, currency string) (Money, error) {
+	m := Money{Currency: strings.ToUpper(strings.TrimSpace(currency))}
+	if !currencyRegexp.MatchString(m.Currency) {
+		return m, fmt.Errorf(
+			"expected a three-letter currency code, like USD, but got '%s'", currency)
+	}
+
+	match := amountRegexp.FindStringSubmatch(strings.TrimSpace(amount))
+	if match == nil {
+		return m, fmt.Errorf("expected an amount like 85 or 85.50, but got '%s'", amount)
+	}
+	whole, e := strconv.ParseInt(match[1], 10, 64)
+	if e != nil {
+		return m, fmt.Errorf("amount '%s' out of range: %s", amount, e)
+	}
+	var cents int64
+	if fraction := match[3]; len(fraction) > 0 {
+		if len(fraction) == 1 {
+			fraction += "0"
+		}
+		cents, _ = strconv.ParseInt(fraction, 10, 64)
+	}
+	m.Cents = whole*100 + cents
+	return m, nil
+}
+
+// Rates lists client's rates, oldest first.
+func (c *Card) Rates(client string) ([]*RateSchema, error) {
+	return c.queryRates(`
+		SELECT * FROM rates
+		WHERE project IS ?
+		ORDER BY effective ASC;
+	`, client)
+}
+
+// AllRates lists every client's rates, by client, oldest first.
+func (c *Card) AllRates() ([]*RateSchema, error) {
+	return c.queryRates(`SELECT * FROM rates ORDER BY project ASC, effective ASC;`)
+}
+
+// SetRate records rate, replacing any rate on its client with the same
+// Effective time.
+func (c *Card) SetRate(rate *RateSchema) error {
+	if !IsValidClient(rate.Project) {
+		return fmt.Errorf("invalid client: '%s'", rate.Project)
+	}
+	if rate.Hourly.Cents < 0 {
+		return fmt.Errorf("rate cannot be negative, got %s", rate.Hourly)
+	}
+	if !currencyRegexp.MatchString(rate.Hourly.Currency) {
+		return fmt.Errorf("invalid currency code: '%s'", rate.Hourly.Currency)
+	}
+	if rate.Round < 0 || rate.Round%time.Minute != 0 {
+		return fmt.Errorf("rounding must be whole minutes, got %s", rate.Round)
+	}
+
+	summary := fmt.Sprintf("rate '%s'", rate.Project)
+	return c.journaled(summary, []rowKey{rateKey(rate)}, func(tx *sql.Tx) error {
+		stmt, e := tx.Prepare(`
+			INSERT OR REPLACE INTO
+			rates(project, effective, hourly_cents, currency, round_minutes, per_session)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`)
+		if e != nil {
+			return fmt.Errorf("preparing rate insertion: %s", e)
+		}
+		raw := rate.ToSQL()
+		if _, e := stmt.Exec(
+			raw.Project, raw.Effective, raw.HourlyCents,
+			raw.Currency, raw.RoundMinutes, raw.PerSession); e != nil {
+			return fmt.Errorf("recording rate: %s", e)
+		}
+		return nil
+	})
+}
+
+// The rate in effect at `at`, per rates (as from Rates), or nil if none yet.
+func rateAt(rates []*RateSchema, at time.Time) *RateSchema {
+	var effective *RateSchema
+	for _, r := range rates {
+		if r.Effective.After(at) {
+			break
+		}
+		effective = r
+	}
+	return effective
+}
+
+// Rounds d up to a multiple of increment, if increment is non-zero.
+func roundUp(d, increment time.Duration) time.Duration {
+	if increment <= 0 || d%increment == 0 {
+		return d
+	}
+	return (d/increment + 1) * increment
+}
+
+// Prices d at hourly, rounding to the nearest hundredth.
+func priceDuration(d time.Duration, hourly Money) Money {
+	seconds := int64(d / time.Second)
+	return Money{
+		Cents:    (seconds*hourly.Cents + 1800) / 3600,
+		Currency: hourly.Currency,
+	}
+}
+
+// Prices the sessions of r per rates, each session at the rate in effect when it
+// started.
+func (r *BillReport) price(rates []*RateSchema) error {
+	var order []*RateSchema
+	billable := make(map[*RateSchema]time.Duration)
+	for _, s := range r.Sessions {
+		rate := rateAt(rates, s.StartAt)
+		if rate == nil {
+			r.Unpriced += s.Duration
+			continue
+		}
+		if _, ok := billable[rate]; !ok {
+			order = append(order, rate)
+		}
+		if rate.PerSession {
+			billable[rate] += roundUp(s.Duration, rate.Round)
+		} else {
+			billable[rate] += s.Duration
+		}
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	amount := Money{Currency: order[0].Hourly.Currency}
+	for _, rate := range order {
+		if rate.Hourly.Currency != amount.Currency {
+			return fmt.Errorf(
+				"'%s' pay period ending %s spans rates in both %s and %s",
+				r.Bill.Project, r.Bill.Endclusive.Format(FormatDateTime),
+				amount.Currency, rate.Hourly.Currency)
+		}
+		d := billable[rate]
+		if !rate.PerSession {
+			d = roundUp(d, rate.Round)
+		}
+		r.Billable += d
+		amount.Cents += priceDuration(d, rate.Hourly).Cents
+	}
+	r.Amount = &amount
+	r.Rates = order
+	return nil
+}
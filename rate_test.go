@@ -0,0 +1,197 @@
+package punch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMoney(t *testing.T) {
+	for _, tt := range []struct {
+		amount, currency string
+		expected         Money
+	}{
+		{"85", "USD", Money{8500, "USD"}},
+		{"85.5", "usd", Money{8550, "USD"}},
+		{" 85.05 ", "EUR", Money{8505, "EUR"}},
+		{"0", "JPY", Money{0, "JPY"}},
+	} {
+		m, e := ParseMoney(tt.amount, tt.currency)
+		if e != nil {
+			t.Errorf("ParseMoney(%q, %q): unexpected error: %s", tt.amount, tt.currency, e)
+			continue
+		}
+		if m != tt.expected {
+			t.Errorf("ParseMoney(%q, %q): got %s, expected %s",
+				tt.amount, tt.currency, m, tt.expected)
+		}
+	}
+
+	for _, tt := range [][2]string{
+		{"85", "dollars"},
+		{"85", ""},
+		{"-85", "USD"},
+		{"85.505", "USD"},
+		{"eighty", "USD"},
+	} {
+		if m, e := ParseMoney(tt[0], tt[1]); e == nil {
+			t.Errorf("ParseMoney(%q, %q): expected error, got %s", tt[0], tt[1], m)
+		}
+	}
+}
+
+// A new card with three "acme" sessions, starting at epoch, of 10m, 20m and
+// 50m, each an hour after the previous started.
+func pricingCard(t *testing.T) (*Card, time.Time, func()) {
+	dir, e := ioutil.TempDir("", "punch-rate-test")
+	if e != nil {
+		t.Fatalf("creating temp dir: %s", e)
+	}
+	c, e := Create(filepath.Join(dir, "punchcard"))
+	if e != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("creating card: %s", e)
+	}
+	cleanup := func() { c.Close(); os.RemoveAll(dir) }
+
+	epoch := time.Unix(1491000000, 0)
+	c.Clock = FixedClock(epoch.Add(time.Hour * 5))
+	for _, punch := range []time.Duration{
+		0, time.Minute * 10,
+		time.Hour, time.Hour + time.Minute*20,
+		time.Hour * 2, time.Hour*2 + time.Minute*50,
+	} {
+		if _, e := c.PunchAt("acme", "", epoch.Add(punch)); e != nil {
+			cleanup()
+			t.Fatalf("punching at +%s: %s", punch, e)
+		}
+	}
+	return c, epoch, cleanup
+}
+
+func priceAcme(c *Card, epoch time.Time) (*BillReport, error) {
+	reports, e := c.ReportBills([]*BillSchema{{
+		Startclusive: epoch,
+		Endclusive:   epoch.Add(time.Hour * 3),
+		Project:      "acme",
+	}})
+	if e != nil {
+		return nil, e
+	}
+	return reports[0], nil
+}
+
+func TestPriceRoundsPerRate(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	for _, rate := range []*RateSchema{
+		{
+			Project:    "acme",
+			Effective:  time.Unix(0, 0),
+			Hourly:     Money{6000, "USD"},
+			Round:      time.Minute * 15,
+			PerSession: true,
+		},
+		// Changes before the third session; rounds its 50m up to 60m
+		{
+			Project:   "acme",
+			Effective: epoch.Add(time.Minute * 90),
+			Hourly:    Money{12000, "USD"},
+			Round:     time.Minute * 30,
+		},
+	} {
+		if e := c.SetRate(rate); e != nil {
+			t.Fatalf("setting rate: %s", e)
+		}
+	}
+
+	r, e := priceAcme(c, epoch)
+	if e != nil {
+		t.Fatalf("pricing: %s", e)
+	}
+	// 15m + 30m at 60.00/h, then 60m at 120.00/h
+	if r.Billable != time.Minute*105 || r.Amount == nil ||
+		*r.Amount != (Money{16500, "USD"}) || r.Unpriced != 0 {
+		t.Errorf("got %s billable, worth %v, with %s unpriced",
+			r.Billable, r.Amount, r.Unpriced)
+	}
+}
+
+func TestPriceWithoutRates(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	r, e := priceAcme(c, epoch)
+	if e != nil {
+		t.Fatalf("pricing: %s", e)
+	}
+	if r.Amount != nil || r.Billable != 0 || r.Unpriced != time.Minute*80 {
+		t.Errorf("got %s billable, worth %v, with %s unpriced",
+			r.Billable, r.Amount, r.Unpriced)
+	}
+
+	// Takes effect after the first session started
+	if e := c.SetRate(&RateSchema{
+		Project:   "acme",
+		Effective: epoch.Add(time.Minute),
+		Hourly:    Money{6000, "USD"},
+	}); e != nil {
+		t.Fatalf("setting rate: %s", e)
+	}
+	if r, e = priceAcme(c, epoch); e != nil {
+		t.Fatalf("pricing: %s", e)
+	}
+	if r.Billable != time.Minute*70 || r.Amount == nil ||
+		*r.Amount != (Money{7000, "USD"}) || r.Unpriced != time.Minute*10 {
+		t.Errorf("got %s billable, worth %v, with %s unpriced",
+			r.Billable, r.Amount, r.Unpriced)
+	}
+}
+
+func TestPriceRefusesMixedCurrencies(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	for _, rate := range []*RateSchema{
+		{Project: "acme", Effective: time.Unix(0, 0), Hourly: Money{6000, "USD"}},
+		{Project: "acme", Effective: epoch.Add(time.Minute * 90), Hourly: Money{6000, "EUR"}},
+	} {
+		if e := c.SetRate(rate); e != nil {
+			t.Fatalf("setting rate: %s", e)
+		}
+	}
+	if _, e := priceAcme(c, epoch); e == nil ||
+		!strings.Contains(e.Error(), "spans rates in both USD and EUR") {
+		t.Errorf("expected mixed currencies to be refused, got: %v", e)
+	}
+}
+
+func TestSetRateReplacesSameEffective(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	for _, cents := range []int64{6000, 7500} {
+		if e := c.SetRate(&RateSchema{
+			Project: "acme", Effective: epoch, Hourly: Money{cents, "USD"},
+		}); e != nil {
+			t.Fatalf("setting rate: %s", e)
+		}
+	}
+	rates, e := c.Rates("acme")
+	if e != nil {
+		t.Fatalf("listing rates: %s", e)
+	}
+	if len(rates) != 1 || rates[0].Hourly.Cents != 7500 {
+		t.Errorf("expected one replaced rate, got %d", len(rates))
+	}
+
+	if e := c.SetRate(&RateSchema{
+		Project: "acme", Effective: epoch, Hourly: Money{6000, "USD"}, Round: time.Second,
+	}); e == nil {
+		t.Errorf("expected rounding to whole minutes to be enforced")
+	}
+}
@@ -0,0 +1,396 @@
+package punch
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// see: https://marcesher.com/2014/10/13/go-working-effectively-with-database-nulls/
+func toNullString(optional string) sql.NullString {
+	value := strings.TrimSpace(optional) // pertinent specifically to punch schemas
+	return sql.NullString{String: value, Valid: len(value) != 0}
+}
+
+// see: https://marcesher.com/2014/10/13/go-working-effectively-with-database-nulls/
+func fromNullString(optional sql.NullString) string {
+	if !optional.Valid {
+		return "" // golang zero-value
+	}
+	return optional.String
+}
+
+type BillSchemaSQL struct {
+	Endclusive   int // primary key
+	Startclusive int
+	Project      string
+	Note         sql.NullString
+}
+
+func (b *BillSchemaSQL) ToBill() *BillSchema {
+	return &BillSchema{
+		Endclusive:   time.Unix(int64(b.Endclusive), 0 /*nanoseconds*/),
+		Startclusive: time.Unix(int64(b.Startclusive), 0 /*nanoseconds*/),
+		Project:      b.Project,
+		Note:         fromNullString(b.Note),
+	}
+}
+
+func (b *BillSchema) ToSQL() *BillSchemaSQL {
+	return &BillSchemaSQL{
+		Endclusive:   int(b.Endclusive.Unix()),
+		Startclusive: int(b.Startclusive.Unix()),
+		Project:      b.Project,
+		Note:         toNullString(b.Note),
+	}
+}
+
+func (b *BillSchema) String(showTimezone bool) string {
+	var start, end string
+
+	if showTimezone {
+		start = b.Startclusive.Format(FormatDateTime)
+		end = b.Endclusive.String() // unnecessary twice
+	} else {
+		start = b.Startclusive.Format(FormatDateTime)
+		end = b.Endclusive.Format(FormatDateTime)
+	}
+
+	return fmt.Sprintf("%s, %s, %s, %s",
+		b.Project,
+		start, end,
+		FromNote(b.Note))
+}
+
+type BillSchema struct {
+	Endclusive   time.Time
+	Startclusive time.Time
+	Project      string
+	Note         string //optional
+}
+
+type RateSchemaSQL struct {
+	Project      string
+	Effective    int // unix stamp seconds
+	HourlyCents  int64
+	Currency     string
+	RoundMinutes int
+	PerSession   int // (pseudo-boolean) 1,0
+}
+
+// RateSchema is a client's hourly rate, from Effective until the client's next
+// rate takes effect.
+type RateSchema struct {
+	Project   string
+	Effective time.Time
+	Hourly    Money
+
+	// Billable time is rounded up to a multiple of Round, if non-zero, either
+	// per-session or for the total time worked in a pay period.
+	Round      time.Duration
+	PerSession bool
+}
+
+func (raw *RateSchemaSQL) ToRate() *RateSchema {
+	return &RateSchema{
+		Project:    raw.Project,
+		Effective:  time.Unix(int64(raw.Effective), 0 /*nanoseconds*/),
+		Hourly:     Money{Cents: raw.HourlyCents, Currency: raw.Currency},
+		Round:      time.Duration(raw.RoundMinutes) * time.Minute,
+		PerSession: raw.PerSession == 1,
+	}
+}
+
+func (r *RateSchema) ToSQL() *RateSchemaSQL {
+	var perSession int
+	if r.PerSession {
+		perSession = 1
+	}
+	return &RateSchemaSQL{
+		Project:      r.Project,
+		Effective:    int(r.Effective.Unix()),
+		HourlyCents:  r.Hourly.Cents,
+		Currency:     r.Hourly.Currency,
+		RoundMinutes: int(r.Round / time.Minute),
+		PerSession:   perSession,
+	}
+}
+
+// Describes r's rounding, eg: "15m/session", or "none".
+func (r *RateSchema) RoundingString() string {
+	if r.Round == 0 {
+		return "none"
+	}
+	per := "period"
+	if r.PerSession {
+		per = "session"
+	}
+	return fmt.Sprintf("%dm/%s", int(r.Round/time.Minute), per)
+}
+
+type TagSchemaSQL struct {
+	Punch   int // unix stamp seconds, of the session's punch-in
+	Project string
+	Tag     string
+}
+
+// TagSchema is one tag on the session client punched in to at Punch.
+type TagSchema struct {
+	Punch   time.Time
+	Project string
+	Tag     string
+}
+
+func (raw *TagSchemaSQL) ToTag() *TagSchema {
+	return &TagSchema{
+		Punch:   time.Unix(int64(raw.Punch), 0 /*nanoseconds*/),
+		Project: raw.Project,
+		Tag:     raw.Tag,
+	}
+}
+
+func (t *TagSchema) ToSQL() *TagSchemaSQL {
+	return &TagSchemaSQL{
+		Punch:   int(t.Punch.Unix()),
+		Project: t.Project,
+		Tag:     t.Tag,
+	}
+}
+
+type BreakSchemaSQL struct {
+	Punch   int // unix stamp seconds, of the session's punch-in
+	Project string
+	Start   int           // unix stamp seconds, of the pause
+	Stop    sql.NullInt64 // unix stamp seconds, of the resume; null while paused
+}
+
+// BreakSchema is one pause taken within the session client punched in to at
+// Punch.
+type BreakSchema struct {
+	Punch   time.Time
+	Project string
+	Start   time.Time
+	Stop    time.Time // zero value while still paused
+}
+
+func (raw *BreakSchemaSQL) ToBreak() *BreakSchema {
+	b := &BreakSchema{
+		Punch:   time.Unix(int64(raw.Punch), 0 /*nanoseconds*/),
+		Project: raw.Project,
+		Start:   time.Unix(int64(raw.Start), 0 /*nanoseconds*/),
+	}
+	if raw.Stop.Valid {
+		b.Stop = time.Unix(raw.Stop.Int64, 0 /*nanoseconds*/)
+	}
+	return b
+}
+
+func (b *BreakSchema) ToSQL() *BreakSchemaSQL {
+	raw := &BreakSchemaSQL{
+		Punch:   int(b.Punch.Unix()),
+		Project: b.Project,
+		Start:   int(b.Start.Unix()),
+	}
+	if !b.Stop.IsZero() {
+		raw.Stop = sql.NullInt64{Int64: b.Stop.Unix(), Valid: true}
+	}
+	return raw
+}
+
+// IsPaused reports whether b has yet to be resumed.
+func (b *BreakSchema) IsPaused() bool { return b.Stop.IsZero() }
+
+type CardSchemaSQL struct {
+	Punch   int // unix stamp seconds; primary key
+	Status  int // (pseudo-boolean) 1,0
+	Project string
+	Note    sql.NullString
+}
+
+type CardSchema struct {
+	Punch   time.Time
+	IsStart bool
+	Project string
+	Note    string
+}
+
+func buildCardSQL(isPunchIn bool, client string, note string, at time.Time) *CardSchemaSQL {
+	if len(client) < 1 {
+		panic("tried to build CardSchemaSQL object without required Project field")
+	}
+
+	punchAsInt := 0
+	if isPunchIn {
+		punchAsInt = 1
+	}
+	return &CardSchemaSQL{
+		Punch:   int(at.Unix()),
+		Status:  punchAsInt,
+		Project: client,
+		Note:    toNullString(note),
+	}
+}
+
+func (c *CardSchema) IsEmptyCard() bool {
+	return *c == CardSchema{}
+}
+
+func (raw *CardSchemaSQL) ToCard() *CardSchema {
+	return &CardSchema{
+		Punch:   time.Unix(int64(raw.Punch), 0 /*nanoseconds*/),
+		IsStart: raw.Status == 1,
+		Project: raw.Project,
+		Note:    strings.TrimSpace(fromNullString(raw.Note)),
+	}
+}
+
+func (card *CardSchema) ToSQL() *CardSchemaSQL {
+	var statusNum int
+	if card.IsStart {
+		statusNum = 1
+	}
+
+	return &CardSchemaSQL{
+		Punch:   int(card.Punch.Unix()),
+		Status:  statusNum,
+		Project: card.Project,
+		Note:    toNullString(strings.TrimSpace(card.Note)),
+	}
+}
+
+type Session struct {
+	Client    string
+	StartAt   time.Time
+	StopAt    time.Time
+	Duration  time.Duration
+	NoteStart string
+	NoteStop  string
+	Tags      []string // sorted
+
+	// Pauses taken within the session, by start; Duration excludes them.
+	Breaks []*BreakSchema
+
+	// Other clients' sessions this one shares time with, if Split.
+	overlaps []*Overlap
+}
+
+func (from *CardSchema) ToSession(to *CardSchema) *Session {
+	return &Session{
+		Client:    from.Project,
+		StartAt:   from.Punch,
+		StopAt:    to.Punch,
+		Duration:  to.Punch.Sub(from.Punch),
+		NoteStart: from.Note,
+		NoteStop:  to.Note,
+	}
+}
+
+// Clip returns the part of s that falls within [from, to], or nil if none of it
+// does.
+func (s *Session) Clip(from, to time.Time) *Session {
+	clipped := *s
+	if clipped.StartAt.Before(from) {
+		clipped.StartAt = from
+	}
+	if clipped.StopAt.After(to) {
+		clipped.StopAt = to
+	}
+	if !clipped.StopAt.After(clipped.StartAt) {
+		return nil
+	}
+	clipped.Duration = clipped.StopAt.Sub(clipped.StartAt) -
+		clipped.BreakTime() - clipped.splitOff()
+	return &clipped
+}
+
+// In returns a copy of s with its stamps in loc.
+func (s *Session) In(loc *time.Location) *Session {
+	moved := *s
+	moved.StartAt = s.StartAt.In(loc)
+	moved.StopAt = s.StopAt.In(loc)
+	return &moved
+}
+
+// SplitDays splits s at each midnight it spans, in the location of its StartAt.
+func (s *Session) SplitDays() []*Session {
+	var days []*Session
+	for from := s.StartAt; from.Before(s.StopAt); {
+		midnight := GroupByDay.Start(from).AddDate(0, 0, 1)
+		if day := s.Clip(from, midnight); day != nil {
+			days = append(days, day)
+		}
+		from = midnight
+	}
+	if len(days) == 0 {
+		return []*Session{s} // zero-length
+	}
+	return days
+}
+
+func (s *Session) DurationToStr() string {
+	return DurationToStr(s.Duration)
+}
+
+func (s *Session) String() string {
+	format := fmt.Sprintf("%s%d%s", "%", DurationToStrMaxLen, "s from %s to %s%s")
+
+	outPunchFormat := "15:04:05.9999"
+	if !GroupByDay.Start(s.StopAt).Equal(GroupByDay.Start(s.StartAt)) {
+		outPunchFormat = "01-02 " + outPunchFormat // stops on a later day
+	}
+
+	var notes string
+	if paused := s.BreakTime(); paused > 0 {
+		notes = fmt.Sprintf(" (%s gross, less %s paused)",
+			DurationToStr(s.Gross()), DurationToStr(paused))
+	}
+	if len(s.NoteStart) > 0 {
+		notes += fmt.Sprintf(" %s", s.NoteStart)
+	}
+	if len(s.NoteStop) > 0 {
+		var separator string
+		if len(s.NoteStart) > 0 {
+			separator = ";"
+		}
+		notes += fmt.Sprintf("%s %s", separator, s.NoteStop)
+	}
+	for _, tag := range s.Tags {
+		notes += fmt.Sprintf(" #%s", tag)
+	}
+
+	return fmt.Sprintf(format,
+		s.DurationToStr(),
+		s.StartAt.Format(FormatDateTime),
+		s.StopAt.Format(outPunchFormat),
+		notes)
+}
+
+type JournalEntrySQL struct {
+	ID      int64
+	At      int // unix stamp seconds
+	Command string
+	Before  string // JSON of a journalImage
+	After   string // JSON of a journalImage
+	Undone  int    // (pseudo-boolean) 1,0
+}
+
+func (raw *JournalEntrySQL) ToEntry() (*JournalEntry, error) {
+	entry := &JournalEntry{
+		ID:       raw.ID,
+		At:       time.Unix(int64(raw.At), 0 /*nanoseconds*/),
+		Command:  raw.Command,
+		IsUndone: raw.Undone == 1,
+		before:   &journalImage{},
+		after:    &journalImage{},
+	}
+	if e := json.Unmarshal([]byte(raw.Before), entry.before); e != nil {
+		return nil, fmt.Errorf("parsing journal #%d: %s", raw.ID, e)
+	}
+	if e := json.Unmarshal([]byte(raw.After), entry.after); e != nil {
+		return nil, fmt.Errorf("parsing journal #%d: %s", raw.ID, e)
+	}
+	entry.Before, entry.After = entry.before.toImage(), entry.after.toImage()
+	return entry, nil
+}
@@ -0,0 +1,119 @@
+package punch
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionClip(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2017, 4, 11, hour, 0, 0, 0, time.UTC) }
+	session := (&CardSchema{Punch: at(9), IsStart: true}).ToSession(&CardSchema{Punch: at(17)})
+
+	for _, tt := range []struct {
+		from, to time.Time
+		expected *Session // only StartAt, StopAt & Duration are compared
+	}{
+		{at(8), at(18), &Session{StartAt: at(9), StopAt: at(17), Duration: time.Hour * 8}},
+		{at(9), at(17), &Session{StartAt: at(9), StopAt: at(17), Duration: time.Hour * 8}},
+		{at(12), at(18), &Session{StartAt: at(12), StopAt: at(17), Duration: time.Hour * 5}},
+		{at(8), at(10), &Session{StartAt: at(9), StopAt: at(10), Duration: time.Hour}},
+		{at(10), at(11), &Session{StartAt: at(10), StopAt: at(11), Duration: time.Hour}},
+		{at(17), at(18), nil},
+		{at(6), at(9), nil},
+		{at(18), at(20), nil},
+	} {
+		actual := session.Clip(tt.from, tt.to)
+		if tt.expected == nil {
+			if actual != nil {
+				t.Errorf("Clip(%s, %s): expected nil, got %s", tt.from, tt.to, actual)
+			}
+			continue
+		}
+		if actual == nil ||
+			!actual.StartAt.Equal(tt.expected.StartAt) ||
+			!actual.StopAt.Equal(tt.expected.StopAt) ||
+			actual.Duration != tt.expected.Duration {
+			t.Errorf("Clip(%s, %s): got %v, expected %v", tt.from, tt.to, actual, tt.expected)
+		}
+	}
+	if session.Duration != time.Hour*8 {
+		t.Errorf("Clip modified the original session")
+	}
+}
+
+func TestSessionSplitDays(t *testing.T) {
+	zone := time.FixedZone("EDT", -4*60*60)
+	at := func(day, hour int) time.Time { return time.Date(2017, 4, day, hour, 0, 0, 0, zone) }
+	session := func(from, to time.Time) *Session {
+		return (&CardSchema{Punch: from, IsStart: true}).ToSession(&CardSchema{Punch: to})
+	}
+
+	for _, tt := range []struct {
+		session  *Session
+		expected []time.Duration
+	}{
+		{session(at(11, 9), at(11, 17)), []time.Duration{time.Hour * 8}},
+		{session(at(11, 21), at(12, 2)), []time.Duration{time.Hour * 3, time.Hour * 2}},
+		{session(at(11, 0), at(12, 0)), []time.Duration{time.Hour * 24}},
+		{session(at(10, 12), at(12, 12)), []time.Duration{
+			time.Hour * 12, time.Hour * 24, time.Hour * 12}},
+		{session(at(11, 9), at(11, 9)), []time.Duration{0}},
+	} {
+		days := tt.session.SplitDays()
+		if len(days) != len(tt.expected) {
+			t.Errorf("SplitDays(%s): got %d days, expected %d", tt.session, len(days), len(tt.expected))
+			continue
+		}
+		for i, day := range days {
+			if day.Duration != tt.expected[i] {
+				t.Errorf("SplitDays(%s): got day %d of %s, expected %s",
+					tt.session, i, day.Duration, tt.expected[i])
+			}
+			if i > 0 && (day.StartAt.Hour() != 0 || !day.StartAt.Equal(days[i-1].StopAt)) {
+				t.Errorf("SplitDays(%s): day %d starts at %s", tt.session, i, day.StartAt)
+			}
+		}
+	}
+}
+
+func TestSessionSplitDaysAcrossDST(t *testing.T) {
+	nyc, e := time.LoadLocation("America/New_York")
+	if e != nil {
+		t.Skipf("no timezone data: %s", e)
+	}
+	// Clocks sprang forward at 2am on 2017-03-12, so that day was 23h long
+	from := time.Date(2017, 3, 11, 22, 0, 0, 0, nyc)
+	to := time.Date(2017, 3, 13, 2, 0, 0, 0, nyc)
+	session := (&CardSchema{Punch: from, IsStart: true}).ToSession(&CardSchema{Punch: to})
+
+	days := session.SplitDays()
+	if len(days) != 3 {
+		t.Fatalf("expected 3 days, got %d", len(days))
+	}
+	for i, expected := range []time.Duration{time.Hour * 2, time.Hour * 23, time.Hour * 2} {
+		if days[i].Duration != expected {
+			t.Errorf("day %d: got %s, expected %s", i, days[i].Duration, expected)
+		}
+	}
+	if days[2].StartAt.Hour() != 0 || days[2].StartAt.Day() != 13 {
+		t.Errorf("expected last day to start at midnight, got %s", days[2].StartAt)
+	}
+}
+
+func TestSessionStringShowsStopDay(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2017, 4, day, hour, 0, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		from, to time.Time
+		expected string
+	}{
+		{at(11, 9), at(11, 17), "from 2017-04-11 09:00:00 to 17:00:00"},
+		{at(11, 21), at(12, 2), "from 2017-04-11 21:00:00 to 04-12 02:00:00"},
+		{at(11, 9), at(12, 8), "from 2017-04-11 09:00:00 to 04-12 08:00:00"},
+	} {
+		session := (&CardSchema{Punch: tt.from, IsStart: true}).ToSession(&CardSchema{Punch: tt.to})
+		if s := session.String(); !strings.HasSuffix(s, tt.expected) {
+			t.Errorf("got '%s', expected it to end with '%s'", s, tt.expected)
+		}
+	}
+}
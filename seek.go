@@ -0,0 +1,186 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SeekPlan describes moving the close of a session to a new time.
+type SeekPlan struct {
+	PunchIn *CardSchema
+
+	// The existing punch-out being moved; nil when closing a still-open session.
+	PunchOut *CardSchema
+
+	To time.Time
+
+	breaks []*BreakSchema // within the session
+	paused *BreakSchema   // break the session's still paused on, if closing it
+}
+
+func (s *SeekPlan) IsClose() bool { return s.PunchOut == nil }
+
+// Closing is the punch-out that will exist once the plan is carried out.
+func (s *SeekPlan) Closing() *CardSchema {
+	closing := *s.PunchIn
+	if !s.IsClose() {
+		closing = *s.PunchOut
+	} else {
+		closing.Note = "" // TODO(zacsh) add CLI flag to accept note
+	}
+	closing.IsStart = false
+	closing.Punch = s.To
+	return &closing
+}
+
+// Session is the session that will exist once the plan is carried out.
+func (s *SeekPlan) Session() *Session {
+	session := s.PunchIn.ToSession(s.Closing())
+	session.Breaks = s.breaks
+	session.Duration -= session.BreakTime()
+	return session
+}
+
+// Offset is how far the plan moves an existing punch-out; negative for rewinds.
+func (s *SeekPlan) Offset() time.Duration {
+	if s.IsClose() {
+		return 0
+	}
+	return s.To.Sub(s.PunchOut.Punch)
+}
+
+// Keeps just the punch-ins of cards, or just the punch-outs.
+func filterStatus(cards []*CardSchema, isStart bool) []*CardSchema {
+	var filtered []*CardSchema
+	for _, card := range cards {
+		if card.IsStart == isStart {
+			filtered = append(filtered, card)
+		}
+	}
+	return filtered
+}
+
+// PlanSeekClose plans closing the still-open session that started at stillOpen,
+// with a punch-out at `to`. An empty client matches a session of any client.
+func (c *Card) PlanSeekClose(client string, stillOpen, to time.Time) (*SeekPlan, error) {
+	if to.Before(stillOpen) {
+		return nil, fmt.Errorf("SEEK_TO <= STILL_OPEN creates empty session")
+	}
+	cards, e := c.punchesAt(client, stillOpen)
+	if e != nil {
+		return nil, fmt.Errorf("querying STILL_OPEN punch: %s", e)
+	}
+	punchIn, e := onePunch(filterStatus(cards, true /*isStart*/), "STILL_OPEN")
+	if e != nil {
+		return nil, e
+	}
+
+	plan := &SeekPlan{PunchIn: punchIn, To: to}
+	if plan.breaks, e = c.breaksOf(punchIn); e != nil {
+		return nil, e
+	}
+	if plan.paused, e = endingBreaks(punchIn.Project, "SEEK_TO", plan.breaks, to); e != nil {
+		return nil, e
+	}
+	return plan, nil
+}
+
+// PlanSeekPunchOut plans moving the existing punch-out at faulty to `to`. An
+// empty client matches a punch-out of any client.
+func (c *Card) PlanSeekPunchOut(client string, faulty, to time.Time) (*SeekPlan, error) {
+	if to.Equal(faulty) {
+		return nil, fmt.Errorf("no effective change requested: FAULTY_STAMP equals SEEK_TO")
+	}
+
+	cards, e := c.punchesAt(client, faulty)
+	if e != nil {
+		return nil, fmt.Errorf("querying for FAULTY_STAMP: %s", e)
+	}
+	origClose, e := onePunch(filterStatus(cards, false /*isStart*/), "FAULTY_STAMP")
+	if e != nil {
+		return nil, e
+	}
+
+	cards, e = c.queryCards(`
+		SELECT * FROM punchcard
+		WHERE punch < ?
+		AND project IS ?
+		AND status IS 1
+		ORDER BY punch DESC
+		LIMIT 1
+	`, faulty.Unix(), origClose.Project)
+	if e != nil {
+		return nil, fmt.Errorf("querying for FAULTY_STAMP's opening punch: %s", e)
+	}
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("bad data state: no open punch to FAULTY_STAMP's close")
+	}
+	punchIn := cards[0]
+
+	if !punchIn.Punch.Before(to) {
+		return nil, fmt.Errorf(
+			"SEEK_TO will rewind sesion-close to %s BEFORE session's start",
+			punchIn.Punch.Sub(to))
+	}
+	if to.After(faulty) {
+		if e := c.checkExclusive(origClose.Project, faulty, to, time.Time{}); e != nil {
+			return nil, e
+		}
+	}
+
+	plan := &SeekPlan{PunchIn: punchIn, PunchOut: origClose, To: to}
+	if plan.breaks, e = c.breaksOf(punchIn); e != nil {
+		return nil, e
+	}
+	if _, e := endingBreaks(punchIn.Project, "SEEK_TO", plan.breaks, to); e != nil {
+		return nil, e
+	}
+	return plan, nil
+}
+
+// Seek carries out a plan from PlanSeekClose or PlanSeekPunchOut.
+func (c *Card) Seek(plan *SeekPlan) error {
+	closing := plan.Closing()
+	summary := fmt.Sprintf("seek '%s' session close", closing.Project)
+	if plan.IsClose() {
+		keys := []rowKey{punchKey(closing), breaksKey(plan.PunchIn)}
+		return c.journaled(summary, keys, func(tx *sql.Tx) error {
+			if e := insertCard(tx, closing.ToSQL()); e != nil {
+				return fmt.Errorf("closing session: %s", e)
+			}
+			if plan.paused != nil {
+				if e := resumeBreak(tx, plan.paused, closing.Punch); e != nil {
+					return fmt.Errorf("ending session's break: %s", e)
+				}
+			}
+			return nil
+		})
+	}
+
+	keys := []rowKey{punchKey(plan.PunchOut), punchKey(closing)}
+	return c.journaled(summary, keys, func(tx *sql.Tx) error {
+		return movePunchOut(tx, plan.PunchOut, closing)
+	})
+}
+
+// Moves punchOut to closing's stamp.
+func movePunchOut(tx *sql.Tx, punchOut, closing *CardSchema) error {
+	stmt, e := tx.Prepare(`
+		UPDATE punchcard
+		SET punch = ?
+		WHERE punch IS ?
+		AND project IS ?
+	`)
+	if e != nil {
+		return fmt.Errorf("building UPDATE query: %s", e)
+	}
+
+	if _, e := stmt.Exec(
+		closing.Punch.Unix(),
+		punchOut.Punch.Unix(),
+		closing.Project); e != nil {
+		return fmt.Errorf("running UPDATE query: %s", e)
+	}
+	return nil
+}
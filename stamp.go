@@ -0,0 +1,245 @@
+package punch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts of absolute times accepted by ParseStamp; any without a zone are taken
+// to be in the local zone of `now`.
+var isoLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+var unixStampRegexp = regexp.MustCompile(`^@?([[:digit:]]+)$`)
+
+var clockRegexp = regexp.MustCompile(
+	`^([[:digit:]]{1,2})(:([[:digit:]]{2}))?(:([[:digit:]]{2}))?(am|pm)?$`)
+
+var agoRegexp = regexp.MustCompile(`^(.+)\s+ago$`)
+
+var relativeUnitRegexp = regexp.MustCompile(
+	`([[:digit:]]+)\s*(seconds?|secs?|s|minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|w)`)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// ParseStamp reads a human-readable time expression, relative to `now`. Any one
+// of the following are accepted:
+//   - unix timestamps in seconds, optionally prefixed with '@', eg: "@1492214400"
+//   - ISO-8601 dates and times, eg: "2017-04-14", "2017-04-14T20:00",
+//     "2017-04-14 20:00:00" or "2017-04-14T20:00:00-04:00"
+//   - "now"
+//   - a time of day, today, eg: "17:30", "9am", "5:15pm"
+//   - offsets from now, eg: "-15m", "-1h30m", "2h ago", "90 minutes ago"
+//   - a day, optionally followed by a time of day, where a day is one of: "today",
+//     "yesterday", a weekday (eg: "friday", the most recent one including today),
+//     or "last" followed by a weekday (the most recent one before today), eg:
+//     "yesterday 17:30", "last friday 9am"
+func ParseStamp(expr string, now time.Time) (time.Time, error) {
+	raw := strings.TrimSpace(expr)
+	if len(raw) == 0 {
+		return time.Time{}, fmt.Errorf("empty time expression")
+	}
+	normal := strings.ToLower(strings.Join(strings.Fields(raw), " "))
+
+	if normal == "now" {
+		return now, nil
+	}
+
+	if m := unixStampRegexp.FindStringSubmatch(normal); m != nil {
+		stamp, e := strconv.ParseInt(m[1], 10, 64)
+		if e != nil {
+			return time.Time{}, fmt.Errorf("unix timestamp '%s': %s", raw, e)
+		}
+		return time.Unix(stamp, 0 /*nanoseconds*/), nil
+	}
+
+	for _, layout := range isoLayouts {
+		if t, e := time.ParseInLocation(layout, raw, now.Location()); e == nil {
+			return t, nil
+		}
+	}
+
+	if strings.HasPrefix(normal, "-") || strings.HasPrefix(normal, "+") {
+		offset, e := parseRelative(normal[1:])
+		if e != nil {
+			return time.Time{}, fmt.Errorf("offset '%s': %s", raw, e)
+		}
+		if normal[0] == '-' {
+			return now.Add(-offset), nil
+		}
+		return now.Add(offset), nil
+	}
+
+	if m := agoRegexp.FindStringSubmatch(normal); m != nil {
+		offset, e := parseRelative(m[1])
+		if e != nil {
+			return time.Time{}, fmt.Errorf("offset '%s': %s", raw, e)
+		}
+		return now.Add(-offset), nil
+	}
+
+	if t, isClock, e := parseClock(strings.Replace(normal, " ", "", -1), now); isClock {
+		if e != nil {
+			return time.Time{}, fmt.Errorf("time of day '%s': %s", raw, e)
+		}
+		return t, nil
+	}
+
+	day, rest, e := parseDay(strings.Fields(normal), now)
+	if e != nil {
+		return time.Time{}, fmt.Errorf("unrecognized time expression '%s': %s", raw, e)
+	}
+	if len(rest) == 0 {
+		return day, nil
+	}
+
+	t, isClock, e := parseClock(strings.Join(rest, ""), day)
+	if !isClock {
+		return time.Time{}, fmt.Errorf(
+			"unrecognized time of day in '%s', at '%s'", raw, strings.Join(rest, " "))
+	}
+	if e != nil {
+		return time.Time{}, fmt.Errorf("time of day '%s': %s", raw, e)
+	}
+	return t, nil
+}
+
+// Parses an unsigned duration, either as time.ParseDuration would, or as a
+// sequence of numbers each followed by a unit, eg: "1 hour 30 minutes".
+func parseRelative(expr string) (time.Duration, error) {
+	expr = strings.TrimSpace(expr)
+	if d, e := time.ParseDuration(expr); e == nil {
+		if d < 0 {
+			return 0, fmt.Errorf("expected an unsigned duration")
+		}
+		return d, nil
+	}
+
+	matches := relativeUnitRegexp.FindAllStringSubmatchIndex(expr, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("expected durations like 15m or '2 hours'")
+	}
+
+	var total time.Duration
+	var parsed string
+	for _, m := range matches {
+		parsed += expr[m[0]:m[1]]
+		quantity, e := strconv.Atoi(expr[m[2]:m[3]])
+		if e != nil {
+			return 0, e
+		}
+
+		var unit time.Duration
+		switch expr[m[4]] {
+		case 's':
+			unit = time.Second
+		case 'm':
+			unit = time.Minute
+		case 'h':
+			unit = time.Hour
+		case 'd':
+			unit = time.Hour * 24
+		case 'w':
+			unit = time.Hour * 24 * 7
+		}
+		total += time.Duration(quantity) * unit
+	}
+
+	spaceless := func(s string) string { return strings.Replace(s, " ", "", -1) }
+	if spaceless(parsed) != spaceless(expr) {
+		return 0, fmt.Errorf("unrecognized units in '%s'", expr)
+	}
+	return total, nil
+}
+
+// Reads a time of day, eg: "17:30", "9am", on the date of `day`. isClock
+// indicates whether `expr` looked like a time of day at all.
+func parseClock(expr string, day time.Time) (_ time.Time, isClock bool, _ error) {
+	m := clockRegexp.FindStringSubmatch(expr)
+	if m == nil {
+		return time.Time{}, false, nil
+	}
+
+	hasMinutes := len(m[2]) > 0
+	meridiem := m[6]
+	if !hasMinutes && len(meridiem) == 0 {
+		return time.Time{}, false, nil // bare number, not a time of day
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	var minute, second int
+	if hasMinutes {
+		minute, _ = strconv.Atoi(m[3])
+	}
+	if len(m[5]) > 0 {
+		second, _ = strconv.Atoi(m[5])
+	}
+
+	if len(meridiem) > 0 {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, true, fmt.Errorf("hour must be 1-12 with %s", meridiem)
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, true, fmt.Errorf("out of range")
+	}
+
+	y, mo, d := day.Date()
+	return time.Date(y, mo, d, hour, minute, second, 0, day.Location()), true, nil
+}
+
+// Reads a leading day from `fields`, returning midnight of that day and any
+// fields remaining.
+func parseDay(fields []string, now time.Time) (time.Time, []string, error) {
+	if len(fields) == 0 {
+		return time.Time{}, nil, fmt.Errorf("expected a day")
+	}
+
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+
+	switch fields[0] {
+	case "today":
+		return today, fields[1:], nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), fields[1:], nil
+	case "last":
+		if len(fields) < 2 {
+			return time.Time{}, nil, fmt.Errorf("expected a weekday after 'last'")
+		}
+		weekday, ok := weekdays[fields[1]]
+		if !ok {
+			return time.Time{}, nil, fmt.Errorf("unrecognized weekday '%s'", fields[1])
+		}
+		daysBack := (int(today.Weekday())-int(weekday)+6)%7 + 1
+		return today.AddDate(0, 0, -daysBack), fields[2:], nil
+	}
+
+	weekday, ok := weekdays[fields[0]]
+	if !ok {
+		return time.Time{}, nil, fmt.Errorf("unrecognized day '%s'", fields[0])
+	}
+	daysBack := (int(today.Weekday()) - int(weekday) + 7) % 7
+	return today.AddDate(0, 0, -daysBack), fields[1:], nil
+}
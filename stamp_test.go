@@ -0,0 +1,109 @@
+package punch
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStamp(t *testing.T) {
+	est, e := time.LoadLocation("America/New_York")
+	if e != nil {
+		t.Fatalf("loading test timezone: %s", e)
+	}
+	now := time.Date(2017, 4, 11, 8, 58, 26, 0, est) // a Tuesday
+	at := func(month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(2017, month, day, hour, min, sec, 0, est)
+	}
+
+	for _, tt := range []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"@1492214400", time.Unix(1492214400, 0)},
+		{"1492214400", time.Unix(1492214400, 0)},
+		{"  @1492214400 ", time.Unix(1492214400, 0)},
+		{"2017-04-14", at(4, 14, 0, 0, 0)},
+		{"2017-04-14T20:00", at(4, 14, 20, 0, 0)},
+		{"2017-04-14T20:00:05", at(4, 14, 20, 0, 5)},
+		{"2017-04-14 20:00", at(4, 14, 20, 0, 0)},
+		{"2017-04-14 20:00:05", at(4, 14, 20, 0, 5)},
+		{"2017-04-15T00:00:00Z", time.Unix(1492214400, 0)},
+		{"2017-04-14T20:00:00-04:00", time.Unix(1492214400, 0)},
+		{"now", now},
+		{"NOW", now},
+		{"17:30", at(4, 11, 17, 30, 0)},
+		{"07:05:09", at(4, 11, 7, 5, 9)},
+		{"9am", at(4, 11, 9, 0, 0)},
+		{"12am", at(4, 11, 0, 0, 0)},
+		{"12pm", at(4, 11, 12, 0, 0)},
+		{"5:15pm", at(4, 11, 17, 15, 0)},
+		{"5:15 PM", at(4, 11, 17, 15, 0)},
+		{"-15m", now.Add(-time.Minute * 15)},
+		{"-1h30m", now.Add(-time.Minute * 90)},
+		{"+1h", now.Add(time.Hour)},
+		{"-2 hours", now.Add(-time.Hour * 2)},
+		{"2h ago", now.Add(-time.Hour * 2)},
+		{"90 minutes ago", now.Add(-time.Minute * 90)},
+		{"1 hour 30 mins ago", now.Add(-time.Minute * 90)},
+		{"3 days ago", now.AddDate(0, 0, -3)},
+		{"1 week ago", now.AddDate(0, 0, -7)},
+		{"today", at(4, 11, 0, 0, 0)},
+		{"today 9am", at(4, 11, 9, 0, 0)},
+		{"yesterday", at(4, 10, 0, 0, 0)},
+		{"yesterday 17:30", at(4, 10, 17, 30, 0)},
+		{"Yesterday 5:30 pm", at(4, 10, 17, 30, 0)},
+		{"tuesday", at(4, 11, 0, 0, 0)},
+		{"monday 8:00", at(4, 10, 8, 0, 0)},
+		{"wed", at(4, 5, 0, 0, 0)},
+		{"friday", at(4, 7, 0, 0, 0)},
+		{"last friday 9am", at(4, 7, 9, 0, 0)},
+		{"last tuesday", at(4, 4, 0, 0, 0)},
+		{"last monday", at(4, 10, 0, 0, 0)},
+	} {
+		actual, e := ParseStamp(tt.expr, now)
+		if e != nil {
+			t.Errorf("ParseStamp('%s'): unexpected error: %s", tt.expr, e)
+			continue
+		}
+		if !actual.Equal(tt.expected) {
+			t.Errorf("ParseStamp('%s'): got %s, expected %s", tt.expr, actual, tt.expected)
+		}
+	}
+}
+
+func TestParseStampErrors(t *testing.T) {
+	now := time.Date(2017, 4, 11, 8, 58, 26, 0, time.UTC)
+	for _, tt := range []struct {
+		expr          string
+		errorContains string
+	}{
+		{"", "empty time expression"},
+		{"   ", "empty time expression"},
+		{"@", "unrecognized day"},
+		{"-15", "expected durations like"},
+		{"-15 parsecs", "expected durations like"},
+		{"-15 minutes flat", "unrecognized units"},
+		{"2 months ago", "unrecognized units"},
+		{"13pm", "hour must be 1-12"},
+		{"0am", "hour must be 1-12"},
+		{"25:00", "out of range"},
+		{"12:60", "out of range"},
+		{"yesterday noon", "unrecognized time of day"},
+		{"last", "expected a weekday"},
+		{"last month", "unrecognized weekday"},
+		{"tomorrow", "unrecognized day"},
+		{"2017-13-01", "unrecognized"},
+	} {
+		_, e := ParseStamp(tt.expr, now)
+		if e == nil {
+			t.Errorf("ParseStamp('%s'): expected error, got none", tt.expr)
+			continue
+		}
+		if !strings.Contains(e.Error(), tt.errorContains) {
+			t.Errorf(
+				"ParseStamp('%s'): expected error containing '%s', got: %s",
+				tt.expr, tt.errorContains, e)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package punch
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func insertTag(tx *sql.Tx, tag *TagSchemaSQL) error {
+	_, e := tx.Exec(`
+		INSERT OR IGNORE INTO
+		tags(punch, project, tag)
+		VALUES (?, ?, ?)
+	`, tag.Punch, tag.Project, tag.Tag)
+	return e
+}
+
+// Deletes every tag on the session punchIn opened.
+func deleteTags(tx *sql.Tx, punchIn *CardSchema) error {
+	if _, e := tx.Exec(fmt.Sprintf(
+		"DELETE FROM tags WHERE %s;", rowKeyWhere["tags"]),
+		punchIn.Punch.Unix(), punchIn.Project); e != nil {
+		return fmt.Errorf("deleting session's tags: %s", e)
+	}
+	return nil
+}
+
+// Trims each of tags, failing on any that isn't valid per IsValidTag.
+func cleanTags(tags []string) ([]string, error) {
+	var cleaned []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if !IsValidTag(tag) {
+			return nil, fmt.Errorf("invalid TAG, '%s'", tag)
+		}
+		cleaned = append(cleaned, tag)
+	}
+	return cleaned, nil
+}
+
+// SessionTags is the tags on each session, by its punch-in.
+type SessionTags map[sessionKey][]string
+
+type sessionKey struct {
+	punch   int64
+	project string
+}
+
+// Of lists the tags, sorted, on the session punchIn opened.
+func (t SessionTags) Of(punchIn *CardSchema) []string {
+	return t[sessionKey{punchIn.Punch.Unix(), punchIn.Project}]
+}
+
+// Tag sets the Tags of each of client's sessions.
+func (t SessionTags) Tag(client string, sessions []*Session) {
+	for _, s := range sessions {
+		s.Tags = t[sessionKey{s.StartAt.Unix(), client}]
+	}
+}
+
+// Filter keeps just the punches of cards, expected in chronological order,
+// belonging to sessions tagged with every one of tags: their punch-ins, and the
+// punch-outs closing them.
+func (t SessionTags) Filter(cards []*CardSchema, tags []string) []*CardSchema {
+	if len(tags) == 0 {
+		return cards
+	}
+	isKept := make(map[string]bool) // whether each client's last punch-in was kept
+	var kept []*CardSchema
+	for _, card := range cards {
+		if card.IsStart {
+			isKept[card.Project] = hasTags(t.Of(card), tags)
+		}
+		if isKept[card.Project] {
+			kept = append(kept, card)
+		}
+		if !card.IsStart {
+			isKept[card.Project] = false
+		}
+	}
+	return kept
+}
+
+// AllTags reads the tags on every session of the card.
+func (c *Card) AllTags() (SessionTags, error) {
+	return c.clientTags("")
+}
+
+// Reads the tags on each of client's sessions, or every client's, if empty.
+func (c *Card) clientTags(client string) (SessionTags, error) {
+	rows, e := c.conn().Query(`
+		SELECT * FROM tags
+		WHERE (? IS '' OR project IS ?)
+		ORDER BY punch ASC, project ASC, tag ASC;
+	`, client, client)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	tags := make(SessionTags)
+	for rows.Next() {
+		raw := &TagSchemaSQL{}
+		if e := rows.Scan(&raw.Punch, &raw.Project, &raw.Tag); e != nil {
+			return nil, e
+		}
+		key := sessionKey{int64(raw.Punch), raw.Project}
+		tags[key] = append(tags[key], raw.Tag)
+	}
+	return tags, rows.Err()
+}
+
+// Whether has includes every one of want.
+func hasTags(has []string, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range has {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// HasTags reports whether s is tagged with every one of tags.
+func (s *Session) HasTags(tags ...string) bool { return hasTags(s.Tags, tags) }
+
+// TagTotal is the time worked across the sessions carrying one tag.
+type TagTotal struct {
+	Tag      string // empty for untagged sessions
+	Sessions int
+	Duration time.Duration
+}
+
+// TotalByTag totals sessions by each tag on them, sorted by tag, then untagged
+// sessions last. Sessions with several tags count towards each.
+func TotalByTag(sessions []*Session) []*TagTotal {
+	totalFor := make(map[string]*TagTotal)
+	add := func(tag string, s *Session) {
+		total, ok := totalFor[tag]
+		if !ok {
+			total = &TagTotal{Tag: tag}
+			totalFor[tag] = total
+		}
+		total.Sessions++
+		total.Duration += s.Duration
+	}
+	for _, s := range sessions {
+		if len(s.Tags) == 0 {
+			add("", s)
+		}
+		for _, tag := range s.Tags {
+			add(tag, s)
+		}
+	}
+
+	var totals []*TagTotal
+	for _, total := range totalFor {
+		totals = append(totals, total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if (totals[i].Tag == "") != (totals[j].Tag == "") {
+			return totals[j].Tag == ""
+		}
+		return totals[i].Tag < totals[j].Tag
+	})
+	return totals
+}
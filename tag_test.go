@@ -0,0 +1,87 @@
+package punch
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTags(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+
+	for _, punch := range []struct {
+		at   time.Duration
+		tags []string
+	}{
+		{time.Hour * 3, []string{"meeting"}},
+		{time.Hour*3 + time.Minute*30, []string{"billable", "meeting"}},
+		{time.Hour * 4, []string{"dev", "billable"}},
+		{time.Hour*4 + time.Minute*45, nil},
+	} {
+		if _, e := c.PunchAt("acme", "", epoch.Add(punch.at), punch.tags...); e != nil {
+			t.Fatalf("punching at +%s: %s", punch.at, e)
+		}
+	}
+	if _, e := c.PunchAt("acme", "", time.Time{}, "not a tag"); e == nil ||
+		!strings.Contains(e.Error(), "invalid TAG") {
+		t.Errorf("expected invalid TAG error, got: %v", e)
+	}
+
+	report, e := c.Report("acme", time.Time{})
+	if e != nil {
+		t.Fatalf("reporting: %s", e)
+	}
+	var tagged []string
+	for _, s := range report.Sessions {
+		tagged = append(tagged, strings.Join(s.Tags, "+"))
+	}
+	if got := strings.Join(tagged, ","); got != ",,,billable+meeting,billable+dev" {
+		t.Errorf("got session tags %s", got)
+	}
+
+	var totals []string
+	for _, total := range TotalByTag(report.Sessions) {
+		totals = append(totals, total.Tag+":"+total.Duration.String())
+	}
+	if got := strings.Join(totals, ","); got != "billable:1h15m0s,dev:45m0s,meeting:30m0s,:1h20m0s" {
+		t.Errorf("got totals by tag %s", got)
+	}
+
+	if report, e = c.Report("acme", time.Time{}, "billable", "meeting"); e != nil {
+		t.Fatalf("reporting on tags: %s", e)
+	}
+	if len(report.Sessions) != 1 || report.Total() != time.Minute*30 {
+		t.Errorf("expected only the 30m meeting, got %d sessions over %s",
+			len(report.Sessions), report.Total())
+	}
+
+	tags, e := c.AllTags()
+	if e != nil {
+		t.Fatalf("listing tags: %s", e)
+	}
+	cards, e := c.Cards()
+	if e != nil {
+		t.Fatalf("listing punches: %s", e)
+	}
+	if kept := tags.Filter(cards, []string{"billable"}); len(kept) != 4 {
+		t.Errorf("expected 2 billable sessions' punches, got %d", len(kept))
+	}
+
+	deletion, e := c.PlanPunchDeletion("acme", epoch.Add(time.Hour*4))
+	if e != nil {
+		t.Fatalf("planning deletion: %s", e)
+	}
+	if e := c.DeletePunches(deletion); e != nil {
+		t.Fatalf("deleting session: %s", e)
+	}
+	if tags, e = c.AllTags(); e != nil || len(tags) != 1 {
+		t.Errorf("expected deleted session's tags gone, got %v (error: %v)", tags, e)
+	}
+	if _, e := c.Undo(); e != nil {
+		t.Fatalf("undoing deletion: %s", e)
+	}
+	if tags, e = c.AllTags(); e != nil || len(tags) != 2 {
+		t.Errorf("expected undone deletion's tags back, got %v (error: %v)", tags, e)
+	}
+}
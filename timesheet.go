@@ -0,0 +1,126 @@
+package punch
+
+import "time"
+
+// Timesheet is a grid of time worked: a row per client, and a column per day of
+// a week (or per week of a month).
+type Timesheet struct {
+	From, To time.Time
+	Grouping Grouping // of columns: GroupByDay or GroupByWeek
+
+	// Start of each column, which ends where the next starts (or at To).
+	Columns []time.Time
+
+	// By client; only clients who worked within [From, To).
+	Rows []*TimesheetRow
+
+	// Sessions still open that started before To; none of their time is counted
+	// in Rows.
+	Open []*CardSchema
+}
+
+type TimesheetRow struct {
+	Client string
+	Cells  []time.Duration // per Timesheet.Columns
+}
+
+func (r *TimesheetRow) Total() time.Duration {
+	var total time.Duration
+	for _, cell := range r.Cells {
+		total += cell
+	}
+	return total
+}
+
+// ColumnTotal is the time worked by every client in column i.
+func (t *Timesheet) ColumnTotal(i int) time.Duration {
+	var total time.Duration
+	for _, row := range t.Rows {
+		total += row.Cells[i]
+	}
+	return total
+}
+
+// ColumnEnd is where column i ends.
+func (t *Timesheet) ColumnEnd(i int) time.Time {
+	if i+1 < len(t.Columns) {
+		return t.Columns[i+1]
+	}
+	return t.To
+}
+
+// WeekTimesheet totals each day, Monday to Sunday, of the week containing day.
+func (c *Card) WeekTimesheet(day time.Time) (*Timesheet, error) {
+	from := GroupByWeek.Start(day)
+	return c.timesheet(from, from.AddDate(0, 0, 7), GroupByDay)
+}
+
+// MonthTimesheet totals each week (starting Mondays, but clipped to the month)
+// of the month containing day.
+func (c *Card) MonthTimesheet(day time.Time) (*Timesheet, error) {
+	from := GroupByMonth.Start(day)
+	return c.timesheet(from, from.AddDate(0, 1, 0), GroupByWeek)
+}
+
+func (c *Card) timesheet(from, to time.Time, grouping Grouping) (*Timesheet, error) {
+	sheet := &Timesheet{From: from, To: to, Grouping: grouping}
+	for column := from; column.Before(to); {
+		sheet.Columns = append(sheet.Columns, column)
+		if grouping == GroupByWeek {
+			column = GroupByWeek.Start(column).AddDate(0, 0, 7)
+		} else {
+			column = column.AddDate(0, 0, 1)
+		}
+	}
+
+	cards, e := c.Cards()
+	if e != nil {
+		return nil, e
+	}
+	sessionBreaks, e := c.AllBreaks()
+	if e != nil {
+		return nil, e
+	}
+	for _, summary := range Summarize(cards) {
+		sessionBreaks.Take(summary.Client, summary.Sessions)
+		if e := c.SplitSessions(summary.Sessions); e != nil {
+			return nil, e
+		}
+		row := &TimesheetRow{
+			Client: summary.Client,
+			Cells:  make([]time.Duration, len(sheet.Columns)),
+		}
+		for _, session := range summary.Sessions {
+			for _, day := range session.In(from.Location()).SplitDays() {
+				day = day.Clip(from, to)
+				if day == nil {
+					continue
+				}
+				row.Cells[sheet.column(day.StartAt)] += day.Duration
+			}
+		}
+		if row.Total() > 0 {
+			sheet.Rows = append(sheet.Rows, row)
+		}
+	}
+
+	open, e := c.OpenPunches()
+	if e != nil {
+		return nil, e
+	}
+	for _, punchIn := range open {
+		if punchIn.Punch.Before(to) {
+			sheet.Open = append(sheet.Open, punchIn)
+		}
+	}
+	return sheet, nil
+}
+
+// The index of the column containing at, which must be within [From, To).
+func (t *Timesheet) column(at time.Time) int {
+	i := len(t.Columns) - 1
+	for i > 0 && at.Before(t.Columns[i]) {
+		i--
+	}
+	return i
+}
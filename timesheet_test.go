@@ -0,0 +1,96 @@
+package punch
+
+import (
+	"testing"
+	"time"
+)
+
+// Runs f with the local timezone as UTC, so days start when the sample card's
+// readers would expect.
+func inUTC(f func()) {
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+	f()
+}
+
+func TestWeekTimesheet(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	c, e := Open(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+
+	inUTC(func() {
+		// Wednesday; spaceship's 21:31:38 to 02:22:37 session spans midnight into
+		// Wednesday the 12th
+		sheet, e := c.WeekTimesheet(time.Unix(1491970000, 0))
+		if e != nil {
+			t.Fatalf("building timesheet: %s", e)
+		}
+		if len(sheet.Columns) != 7 || sheet.Columns[0].Weekday() != time.Monday ||
+			!sheet.Columns[0].Equal(time.Date(2017, 4, 10, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("expected columns for Monday the 10th onward, got %v", sheet.Columns)
+		}
+		if len(sheet.Rows) != 1 || sheet.Rows[0].Client != "spaceship" {
+			t.Fatalf("expected only a spaceship row, got %d rows", len(sheet.Rows))
+		}
+		cells := sheet.Rows[0].Cells
+		for i, expected := range []time.Duration{
+			0,
+			time.Second * (3 + 124 + 4004 + 8902), // Tuesday, to midnight
+			time.Second * 8557,                    // Wednesday, from midnight
+			0, 0, 0, 0,
+		} {
+			if cells[i] != expected {
+				t.Errorf("column %d: got %s, expected %s", i, cells[i], expected)
+			}
+		}
+		if sheet.ColumnTotal(1) != cells[1] || !sheet.ColumnEnd(6).Equal(sheet.To) {
+			t.Errorf("got column 1 total %s, and column 6 ending %s",
+				sheet.ColumnTotal(1), sheet.ColumnEnd(6))
+		}
+	})
+}
+
+func TestMonthTimesheet(t *testing.T) {
+	dbPath, cleanup := copySampleCard(t)
+	defer cleanup()
+	c, e := Open(dbPath)
+	if e != nil {
+		t.Fatalf("opening card: %s", e)
+	}
+	defer c.Close()
+	c.Clock = FixedClock(time.Unix(1491970000, 0))
+	if _, e := c.PunchAt("golangpunch", "", time.Unix(1491969000, 0)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+
+	inUTC(func() {
+		sheet, e := c.MonthTimesheet(time.Unix(1491970000, 0))
+		if e != nil {
+			t.Fatalf("building timesheet: %s", e)
+		}
+		// Saturday the 1st, then each Monday
+		var days []int
+		for _, column := range sheet.Columns {
+			days = append(days, column.Day())
+		}
+		if len(days) != 5 || days[0] != 1 || days[1] != 3 || days[4] != 24 {
+			t.Errorf("got columns starting on days %v", days)
+		}
+		for i, expected := range map[int]time.Duration{
+			0: time.Second * 8519,  // golangpunch, all of history
+			1: time.Second * 26662, // spaceship, all of history
+		} {
+			if total := sheet.Rows[i].Total(); total != expected {
+				t.Errorf("%s: got %s, expected %s", sheet.Rows[i].Client, total, expected)
+			}
+		}
+		if len(sheet.Open) != 1 || sheet.Open[0].Project != "golangpunch" {
+			t.Errorf("expected golangpunch's open session to be reported, got %v", sheet.Open)
+		}
+	})
+}
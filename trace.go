@@ -0,0 +1,183 @@
+package punch
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Trace, if set, is where every SQL statement run on cards opened after is
+// logged: with its bound parameters, how long it took, and either the rows it
+// affected and last id it inserted, or the rows it returned.
+var Trace io.Writer
+
+// The sqlite3 driver, logging to Trace.
+const traceDriverName string = "sqlite3-trace"
+
+func init() {
+	sql.Register(traceDriverName, traceDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// Logs one statement, that took from start until now.
+func trace(start time.Time, query string, args []driver.Value, outcome string) {
+	if Trace == nil {
+		return
+	}
+	params := make([]string, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			params[i] = fmt.Sprintf("%q", v)
+		case []byte:
+			params[i] = fmt.Sprintf("%q", string(v))
+		default:
+			params[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	fmt.Fprintf(Trace, "sql: %s [%s] (%s): %s\n",
+		strings.Join(strings.Fields(query), " "),
+		strings.Join(params, ", "),
+		time.Since(start), outcome)
+}
+
+// Describes the result of an Exec, or the error it failed with.
+func execOutcome(r driver.Result, e error) string {
+	if e != nil {
+		return fmt.Sprintf("failed: %s", e)
+	}
+	affected, e := r.RowsAffected()
+	if e != nil {
+		return fmt.Sprintf("unknown rows affected: %s", e)
+	}
+	id, e := r.LastInsertId()
+	if e != nil {
+		return fmt.Sprintf("%d row(s) affected", affected)
+	}
+	return fmt.Sprintf("%d row(s) affected, last insert id %d", affected, id)
+}
+
+type traceDriver struct{ driver.Driver }
+
+func (d traceDriver) Open(dsn string) (driver.Conn, error) {
+	c, e := d.Driver.Open(dsn)
+	if e != nil {
+		return nil, e
+	}
+	return traceConn{c, beginStatement(dsn)}, nil
+}
+
+// The statement the driver begins transactions with, per dsn's _txlock.
+func beginStatement(dsn string) string {
+	pos := strings.IndexRune(dsn, '?')
+	if pos < 0 {
+		return "BEGIN"
+	}
+	params, e := url.ParseQuery(dsn[pos+1:])
+	if e != nil {
+		return "BEGIN"
+	}
+	switch params.Get("_txlock") {
+	case "immediate":
+		return "BEGIN IMMEDIATE"
+	case "exclusive":
+		return "BEGIN EXCLUSIVE"
+	}
+	return "BEGIN"
+}
+
+type traceConn struct {
+	driver.Conn
+	begin string // as the driver runs it, eg: "BEGIN IMMEDIATE"
+}
+
+func (c traceConn) Prepare(query string) (driver.Stmt, error) {
+	s, e := c.Conn.Prepare(query)
+	if e != nil {
+		return nil, e
+	}
+	return traceStmt{s, query}, nil
+}
+
+func (c traceConn) Begin() (driver.Tx, error) {
+	start := time.Now()
+	tx, e := c.Conn.Begin()
+	if e != nil {
+		trace(start, c.begin, nil, fmt.Sprintf("failed: %s", e))
+		return nil, e
+	}
+	trace(start, c.begin, nil, "ok")
+	return traceTx{tx}, nil
+}
+
+type traceTx struct{ driver.Tx }
+
+func (t traceTx) Commit() error {
+	start := time.Now()
+	e := t.Tx.Commit()
+	trace(start, "COMMIT", nil, errOutcome(e))
+	return e
+}
+
+func (t traceTx) Rollback() error {
+	start := time.Now()
+	e := t.Tx.Rollback()
+	trace(start, "ROLLBACK", nil, errOutcome(e))
+	return e
+}
+
+func errOutcome(e error) string {
+	if e != nil {
+		return fmt.Sprintf("failed: %s", e)
+	}
+	return "ok"
+}
+
+type traceStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s traceStmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	r, e := s.Stmt.Exec(args)
+	trace(start, s.query, args, execOutcome(r, e))
+	return r, e
+}
+
+func (s traceStmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, e := s.Stmt.Query(args)
+	if e != nil {
+		trace(start, s.query, args, fmt.Sprintf("failed: %s", e))
+		return nil, e
+	}
+	return &traceRows{Rows: rows, query: s.query, args: args, start: start}, nil
+}
+
+// Logs its query once closed, having counted the rows it returned.
+type traceRows struct {
+	driver.Rows
+	query string
+	args  []driver.Value
+	start time.Time
+	count int
+}
+
+func (r *traceRows) Next(dest []driver.Value) error {
+	e := r.Rows.Next(dest)
+	if e == nil {
+		r.count++
+	}
+	return e
+}
+
+func (r *traceRows) Close() error {
+	e := r.Rows.Close()
+	trace(r.start, r.query, r.args, fmt.Sprintf("%d row(s) returned", r.count))
+	return e
+}
@@ -0,0 +1,187 @@
+package punch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ClientSeparator joins a client's name to its sub-clients', eg: "acme/api/auth"
+// is a sub-client of "acme/api", itself a sub-client of "acme".
+const ClientSeparator string = "/"
+
+// ClientParent is the client that client is a sub-client of, or empty if it's
+// a whole client.
+func ClientParent(client string) string {
+	i := strings.LastIndex(client, ClientSeparator)
+	if i < 0 {
+		return ""
+	}
+	return client[:i]
+}
+
+// ClientAncestors lists the clients that client is within, outermost first, eg:
+// "acme" then "acme/api" for "acme/api/auth".
+func ClientAncestors(client string) []string {
+	var ancestors []string
+	for parent := ClientParent(client); len(parent) > 0; parent = ClientParent(parent) {
+		ancestors = append([]string{parent}, ancestors...)
+	}
+	return ancestors
+}
+
+// IsWithinClient reports whether client is root, or one of its sub-clients.
+func IsWithinClient(client, root string) bool {
+	return client == root || strings.HasPrefix(client, root+ClientSeparator)
+}
+
+// SQL matching projects within a client, per IsWithinClient, given withinArgs.
+const withinClause string = "(project IS ? OR substr(project, 1, ?) IS ?)"
+
+func withinArgs(root string) []interface{} {
+	return []interface{}{root, len(root) + len(ClientSeparator), root + ClientSeparator}
+}
+
+// SortClients orders clients as a tree: each client followed by its
+// sub-clients, and siblings by name.
+func SortClients(clients []string) {
+	sort.Slice(clients, func(i, j int) bool {
+		a := strings.Split(clients[i], ClientSeparator)
+		b := strings.Split(clients[j], ClientSeparator)
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+// ClientNode is one client in a tree of them.
+type ClientNode struct {
+	Client   string // full name, eg: "acme/api"
+	Children []*ClientNode
+}
+
+// Name is the last part of the client's full name, eg: "api" of "acme/api".
+func (n *ClientNode) Name() string {
+	return n.Client[strings.LastIndex(n.Client, ClientSeparator)+1:]
+}
+
+// ClientTree arranges clients into trees, one per whole client, adding any
+// ancestors not among clients themselves.
+func ClientTree(clients []string) []*ClientNode {
+	nodes := make(map[string]*ClientNode)
+	var roots []*ClientNode
+	var add func(client string) *ClientNode
+	add = func(client string) *ClientNode {
+		if node, ok := nodes[client]; ok {
+			return node
+		}
+		node := &ClientNode{Client: client}
+		nodes[client] = node
+		if parent := ClientParent(client); len(parent) > 0 {
+			parentNode := add(parent)
+			parentNode.Children = append(parentNode.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+		return node
+	}
+
+	sorted := append([]string(nil), clients...)
+	SortClients(sorted)
+	for _, client := range sorted {
+		add(client)
+	}
+	return roots
+}
+
+// ClientTotal is the time worked on a client, including all its sub-clients.
+type ClientTotal struct {
+	Client    string
+	Sessions  int
+	Duration  time.Duration
+	IsWorking bool // if punched in to the client, or any of its sub-clients
+}
+
+// RollUp totals each client's sessions onto it and every one of its
+// ancestors, ordered per SortClients.
+func RollUp(sessionsOf map[string][]*Session, working ...string) []*ClientTotal {
+	totalFor := make(map[string]*ClientTotal)
+	totalOf := func(client string) *ClientTotal {
+		total, ok := totalFor[client]
+		if !ok {
+			total = &ClientTotal{Client: client}
+			totalFor[client] = total
+		}
+		return total
+	}
+	for client, sessions := range sessionsOf {
+		for _, c := range append(ClientAncestors(client), client) {
+			total := totalOf(c)
+			total.Sessions += len(sessions)
+			for _, s := range sessions {
+				total.Duration += s.Duration
+			}
+		}
+	}
+	for _, client := range working {
+		for _, c := range append(ClientAncestors(client), client) {
+			totalOf(c).IsWorking = true
+		}
+	}
+
+	var clients []string
+	for client := range totalFor {
+		clients = append(clients, client)
+	}
+	SortClients(clients)
+	totals := make([]*ClientTotal, len(clients))
+	for i, client := range clients {
+		totals[i] = totalFor[client]
+	}
+	return totals
+}
+
+// SubClients lists root, if it has punches, and every sub-client of it with
+// punches, ordered per SortClients.
+func (c *Card) SubClients(root string) ([]string, error) {
+	rows, e := c.conn().Query(fmt.Sprintf(`
+		SELECT DISTINCT(project) FROM punchcard
+		WHERE %s;
+	`, withinClause), withinArgs(root)...)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+
+	var clients []string
+	for rows.Next() {
+		var client string
+		if e := rows.Scan(&client); e != nil {
+			return nil, e
+		}
+		clients = append(clients, client)
+	}
+	SortClients(clients)
+	return clients, rows.Err()
+}
+
+// ReportTree is Report, on root and each of its sub-clients with punches.
+func (c *Card) ReportTree(root string, from time.Time, tags ...string) ([]*ClientReport, error) {
+	clients, e := c.SubClients(root)
+	if e != nil {
+		return nil, e
+	}
+	var reports []*ClientReport
+	for _, client := range clients {
+		report, e := c.Report(client, from, tags...)
+		if e != nil {
+			return nil, fmt.Errorf("reporting on '%s' sessions: %s", client, e)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
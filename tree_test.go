@@ -0,0 +1,144 @@
+package punch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientAncestors(t *testing.T) {
+	for _, tt := range []struct {
+		client, ancestors string
+	}{
+		{"acme", ""},
+		{"acme/api", "acme"},
+		{"acme/api/auth", "acme,acme/api"},
+	} {
+		if got := strings.Join(ClientAncestors(tt.client), ","); got != tt.ancestors {
+			t.Errorf("ClientAncestors('%s'): got '%s', expected '%s'", tt.client, got, tt.ancestors)
+		}
+	}
+	if !IsWithinClient("acme/api", "acme") || IsWithinClient("acme-corp", "acme") {
+		t.Errorf("IsWithinClient: expected only acme/api within acme")
+	}
+}
+
+func TestClientTree(t *testing.T) {
+	clients := []string{"acme-corp", "acme/web", "acme/api/auth", "beta"}
+	SortClients(clients)
+	if got := strings.Join(clients, ","); got != "acme/api/auth,acme/web,acme-corp,beta" {
+		t.Errorf("SortClients: got %s", got)
+	}
+
+	var rendered []string
+	var render func(nodes []*ClientNode, depth int)
+	render = func(nodes []*ClientNode, depth int) {
+		for _, node := range nodes {
+			rendered = append(rendered, strings.Repeat(".", depth)+node.Name())
+			render(node.Children, depth+1)
+		}
+	}
+	render(ClientTree(clients), 0)
+	if got := strings.Join(rendered, ","); got != "acme,.api,..auth,.web,acme-corp,beta" {
+		t.Errorf("ClientTree: got %s", got)
+	}
+}
+
+func TestRollUp(t *testing.T) {
+	hour := &Session{Duration: time.Hour}
+	totals := RollUp(map[string][]*Session{
+		"acme":          {hour},
+		"acme/api/auth": {hour, hour},
+		"acme/web":      {hour},
+	}, "acme/web")
+
+	var got []string
+	for _, total := range totals {
+		got = append(got, fmt.Sprintf(
+			"%s:%d:%s:%t", total.Client, total.Sessions, total.Duration, total.IsWorking))
+	}
+	if expected := "acme:4:4h0m0s:true,acme/api:2:2h0m0s:false," +
+		"acme/api/auth:2:2h0m0s:false,acme/web:1:1h0m0s:true"; strings.Join(got, ",") != expected {
+		t.Errorf("RollUp: got %s, expected %s", strings.Join(got, ","), expected)
+	}
+}
+
+func TestSubClientBills(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	if _, e := c.PunchAt("acme/api", "", epoch.Add(time.Hour*3)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+	if _, e := c.PunchAt("acme/api", "", epoch.Add(time.Hour*4)); e != nil {
+		t.Fatalf("punching out: %s", e)
+	}
+
+	if e := c.CreateBill(&BillSchema{
+		Startclusive: epoch.Add(time.Hour * 3),
+		Endclusive:   epoch.Add(time.Hour * 4),
+		Project:      "acme/api",
+	}); e != nil {
+		t.Fatalf("billing sub-client: %s", e)
+	}
+
+	start, e := c.ImpliedBillStart("acme")
+	if e != nil || !start.Equal(epoch) {
+		t.Errorf("expected acme's bill to imply starting at its first punch, got %s (error: %v)",
+			start, e)
+	}
+	end, e := c.ImpliedBillEnd("acme")
+	if e != nil || !end.Equal(epoch.Add(time.Hour*4)) {
+		t.Errorf("expected acme's bill to imply ending at acme/api's punch-out, got %s (error: %v)",
+			end, e)
+	}
+	if e := c.CreateBill(&BillSchema{Startclusive: start, Endclusive: end, Project: "acme"}); e == nil ||
+		!strings.Contains(e.Error(), "bill the same time twice") {
+		t.Errorf("expected overlap with acme/api's pay period to be refused, got: %v", e)
+	}
+
+	bill := &BillSchema{Startclusive: start, Endclusive: epoch.Add(time.Hour * 3), Project: "acme"}
+	if e := c.CreateBill(bill); e != nil {
+		t.Fatalf("billing acme up to acme/api's pay period: %s", e)
+	}
+	reports, e := c.ReportBills([]*BillSchema{bill})
+	if e != nil {
+		t.Fatalf("reporting bill: %s", e)
+	}
+	if total := reports[0].Total(); total != time.Minute*80 {
+		t.Errorf("expected acme's 80m billed, got %s", total)
+	}
+
+	unbilled, e := c.Unbilled("acme")
+	if e != nil {
+		t.Fatalf("reporting unbilled: %s", e)
+	}
+	if total := unbilled.Total(); total != 0 {
+		t.Errorf("expected nothing unbilled across acme, got %s", total)
+	}
+}
+
+func TestSubClientUnbilledOpen(t *testing.T) {
+	c, epoch, cleanup := pricingCard(t)
+	defer cleanup()
+	if _, e := c.PunchAt("acme/api", "", epoch.Add(time.Hour*3)); e != nil {
+		t.Fatalf("punching in: %s", e)
+	}
+	for _, bill := range []*BillSchema{
+		{Startclusive: epoch, Endclusive: epoch.Add(time.Hour * 2), Project: "acme"},
+		{Startclusive: epoch.Add(time.Hour * 3), Endclusive: epoch.Add(time.Hour * 4), Project: "acme/api"},
+	} {
+		if e := c.CreateBill(bill); e != nil {
+			t.Fatalf("billing '%s': %s", bill.Project, e)
+		}
+	}
+
+	unbilled, e := c.Unbilled("acme")
+	if e != nil {
+		t.Fatalf("reporting unbilled: %s", e)
+	}
+	// acme's last session, and acme/api's open one since its own bill ended
+	if total := unbilled.Total(); total != time.Minute*(50+60) {
+		t.Errorf("expected 1h50m unbilled across acme, got %s", total)
+	}
+}